@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"data-aggregator/domain"
+)
+
+// agingFaixa é uma faixa de atraso: dias entre data_vencimento e a data de referência.
+// Limites em semLimite deixam a faixa aberta naquela ponta.
+type agingFaixa struct {
+	chave     string
+	descricao string
+	diasMin   int
+	diasMax   int
+}
+
+const semLimite = -1 << 31
+
+// agingFaixas são as faixas clássicas de aging, na ordem do relatório
+var agingFaixas = []agingFaixa{
+	{chave: "a_vencer", descricao: "A vencer", diasMin: semLimite, diasMax: 0},
+	{chave: "vencido_1_30", descricao: "Vencido de 1 a 30 dias", diasMin: 1, diasMax: 30},
+	{chave: "vencido_31_60", descricao: "Vencido de 31 a 60 dias", diasMin: 31, diasMax: 60},
+	{chave: "vencido_61_90", descricao: "Vencido de 61 a 90 dias", diasMin: 61, diasMax: 90},
+	{chave: "vencido_90_mais", descricao: "Vencido há mais de 90 dias", diasMin: 91, diasMax: semLimite},
+}
+
+// datas converte a faixa no intervalo inclusivo de data_vencimento para a referência;
+// pontas abertas voltam vazias
+func (f agingFaixa) datas(referencia time.Time) (de, ate string) {
+	if f.diasMax != semLimite {
+		de = referencia.AddDate(0, 0, -f.diasMax).Format(domain.LayoutData)
+	}
+	if f.diasMin != semLimite {
+		ate = referencia.AddDate(0, 0, -f.diasMin).Format(domain.LayoutData)
+	}
+	return de, ate
+}
+
+// newAgingBuckets cria as faixas vazias para a data de referência
+func newAgingBuckets(referencia time.Time) []AgingBucket {
+	buckets := make([]AgingBucket, len(agingFaixas))
+	for i, faixa := range agingFaixas {
+		de, ate := faixa.datas(referencia)
+		buckets[i] = AgingBucket{Faixa: faixa.chave, Descricao: faixa.descricao, DataDe: de, DataAte: ate}
+	}
+	return buckets
+}
+
+// AgingReport é o relatório de aging de um cliente, ou da carteira inteira se CodigoCliente
+// vier vazio: as faixas de atraso e o total geral
+type AgingReport struct {
+	CodigoCliente  string        `json:"codigo_cliente,omitempty"`
+	DataReferencia string        `json:"data_referencia"`
+	Faixas         []AgingBucket `json:"faixas"`
+	Totais         BalanceTotals `json:"totais"`
+}
+
+// AgingReport monta o relatório de aging: as faixas de Aging mais a soma de todas elas
+func (be *BalanceEngine) AgingReport(ctx context.Context, q AgingQuery) (*AgingReport, error) {
+	if q.DataReferencia == "" {
+		q.DataReferencia = time.Now().Format(domain.LayoutData)
+	}
+	faixas, err := be.Aging(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AgingReport{
+		CodigoCliente:  q.CodigoCliente,
+		DataReferencia: q.DataReferencia,
+		Faixas:         faixas,
+	}
+	for _, faixa := range faixas {
+		report.Totais.TotalRecebiveis += faixa.Totais.TotalRecebiveis
+		report.Totais.ValorOriginal += faixa.Totais.ValorOriginal
+		report.Totais.TotalCancelado += faixa.Totais.TotalCancelado
+		report.Totais.TotalNegociado += faixa.Totais.TotalNegociado
+		report.Totais.Saldo += faixa.Totais.Saldo
+	}
+	return report, nil
+}
+
+// Aging monta o aging de um cliente, ou da carteira se codigo_cliente vier vazio. Sem
+// data de referência o atraso é contado a partir de hoje.
+func (be *BalanceEngine) Aging(ctx context.Context, q AgingQuery) ([]AgingBucket, error) {
+	if q.DataReferencia == "" {
+		q.DataReferencia = time.Now().Format(domain.LayoutData)
+	}
+	if _, err := time.Parse(domain.LayoutData, q.DataReferencia); err != nil {
+		return nil, newAppError(ErrCodeInvalidArgument, "data de referência inválida '%s': use o formato yyyy-MM-dd", q.DataReferencia)
+	}
+	return be.store.Aging(ctx, q)
+}
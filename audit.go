@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"sort"
+	"sync"
+
+	"data-aggregator/domain"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// auditContextKey guarda no context o autor e a origem das escritas
+type auditContextKey struct{}
+
+// auditSource identifica quem escreve e por onde (domain.Origem*)
+type auditSource struct {
+	Ator   string
+	Origem string
+}
+
+// withAuditSource marca as escritas feitas com o context
+func withAuditSource(ctx context.Context, ator, origem string) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, auditSource{Ator: ator, Origem: origem})
+}
+
+// auditSourceFrom retorna o autor e a origem do context; escritas sem marcação aparecem
+// como desconhecidas na auditoria, nunca deixam de ser registradas
+func auditSourceFrom(ctx context.Context) auditSource {
+	if source, ok := ctx.Value(auditContextKey{}).(auditSource); ok {
+		return source
+	}
+	return auditSource{Ator: "desconhecido", Origem: "desconhecida"}
+}
+
+// auditMiddleware marca as requisições do handler com a origem informada. O autor é o
+// principal autenticado; com a autenticação desativada, o endereço de quem fez a requisição.
+func auditMiddleware(origem string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ator string
+		if principal, ok := principalFrom(r.Context()); ok {
+			ator = principal.Ator()
+		} else {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			ator = "anonimo@" + host
+		}
+		ctx := withAuditSource(r.Context(), ator, origem)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// cliActor é o autor das escritas feitas pelos subcomandos: o usuário do sistema
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli:" + os.Getenv("USER")
+}
+
+// auditLog grava registros no índice de auditoria
+type auditLog interface {
+	RecordAudit(ctx context.Context, registro domain.RegistroAuditoria) error
+}
+
+// audit registra uma escrita já feita. A falha ao registrar não desfaz a escrita nem
+// chega ao cliente: o registro completo vai para o log, de onde pode ser reprocessado.
+func audit(ctx context.Context, al auditLog, operacao, indice, documentoID, routing string, antes, depois json.RawMessage) {
+	source := auditSourceFrom(ctx)
+	registro := domain.NovoRegistroAuditoria(source.Ator, source.Origem, operacao, indice, documentoID, routing, antes, depois)
+
+	// A escrita já aconteceu: o registro é gravado mesmo que o cliente tenha desistido
+	if err := al.RecordAudit(context.WithoutCancel(ctx), registro); err != nil {
+		data, _ := json.Marshal(registro)
+		log.Printf("❌ auditoria não gravada (%v): %s", err, data)
+	}
+}
+
+// snapshotOf serializa um documento para o registro de auditoria; nil fica nil
+func snapshotOf(document interface{}) json.RawMessage {
+	if document == nil {
+		return nil
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// AuditTrailQuery pede os registros de auditoria de um recebível em ordem de escrita,
+// depois do registro After (vazio para o primeiro)
+type AuditTrailQuery struct {
+	DocumentoID string
+	After       string
+	Size        int
+}
+
+// AuditEntryEdge liga um registro ao cursor da sua posição (o id_registro)
+type AuditEntryEdge struct {
+	Cursor string                    `json:"cursor"`
+	Node   *domain.RegistroAuditoria `json:"node"`
+}
+
+// AuditEntryPage é uma página da trilha de auditoria no formato de connection do Relay
+type AuditEntryPage struct {
+	Edges    []AuditEntryEdge `json:"edges"`
+	PageInfo PageInfo         `json:"pageInfo"`
+}
+
+// auditTrailPage pagina a trilha de auditoria de um recebível. O cursor guarda o
+// id_registro (UUIDv7) do último registro da página, que já é a ordem de escrita.
+func auditTrailPage(ctx context.Context, store ReceivableStore, id string, page PageRequest) (*AuditEntryPage, error) {
+	if id == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "id é obrigatório")
+	}
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, err
+	}
+	query := AuditTrailQuery{DocumentoID: id, Size: page.First + 1}
+	if len(cursor.After) > 0 {
+		query.After, _ = cursor.After[0].(string)
+	}
+
+	registros, err := store.AuditTrail(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AuditEntryPage{Edges: []AuditEntryEdge{}}
+	result.PageInfo.HasPreviousPage = page.After != ""
+	if len(registros) > page.First {
+		registros = registros[:page.First]
+		result.PageInfo.HasNextPage = true
+	}
+	for i := range registros {
+		result.Edges = append(result.Edges, AuditEntryEdge{
+			Cursor: encodeCursor(pageCursor{After: []interface{}{registros[i].IDRegistro}}),
+			Node:   &registros[i],
+		})
+	}
+	if len(result.Edges) > 0 {
+		result.PageInfo.StartCursor = result.Edges[0].Cursor
+		result.PageInfo.EndCursor = result.Edges[len(result.Edges)-1].Cursor
+	}
+	return result, nil
+}
+
+// esAuditIndex é o índice de auditoria, criado com domain.AuditMapping na primeira escrita
+type esAuditIndex struct {
+	name  string
+	mu    sync.Mutex
+	ready bool
+}
+
+// RecordAudit acrescenta o registro ao índice de auditoria. O op_type create com o
+// id_registro como _id garante que um registro nunca sobrescreve outro.
+func (ec *ElasticsearchClient) RecordAudit(ctx context.Context, registro domain.RegistroAuditoria) error {
+	if err := ec.ensureAuditIndex(ctx); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(registro); err != nil {
+		return wrapAppError(ErrCodeInternal, err, "erro ao codificar registro de auditoria")
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ec.audit.name,
+		DocumentID: registro.IDRegistro,
+		Body:       &buf,
+		OpType:     "create",
+	}
+	res, err := req.Do(ctx, ec.client)
+	if err != nil {
+		return transportError(err, "gravar auditoria")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return decodeError(res, "gravar auditoria")
+	}
+	return nil
+}
+
+// ensureAuditIndex cria o índice de auditoria se ele ainda não existir. Não passa por
+// CreateIndex, que também é auditado.
+func (ec *ElasticsearchClient) ensureAuditIndex(ctx context.Context) error {
+	ec.audit.mu.Lock()
+	defer ec.audit.mu.Unlock()
+	if ec.audit.ready {
+		return nil
+	}
+
+	exists, err := esapi.IndicesExistsRequest{Index: []string{ec.audit.name}}.Do(ctx, ec.client)
+	if err != nil {
+		return transportError(err, "verificar índice de auditoria")
+	}
+	exists.Body.Close()
+
+	if exists.StatusCode == http.StatusNotFound {
+		body, err := json.Marshal(domain.AuditMapping())
+		if err != nil {
+			return wrapAppError(ErrCodeInternal, err, "erro ao codificar mapping de auditoria")
+		}
+		res, err := esapi.IndicesCreateRequest{Index: ec.audit.name, Body: bytes.NewReader(body)}.Do(ctx, ec.client)
+		if err != nil {
+			return transportError(err, "criar índice de auditoria")
+		}
+		defer res.Body.Close()
+		// 400 aqui é outra instância que criou o índice primeiro
+		if res.IsError() && res.StatusCode != http.StatusBadRequest {
+			return decodeError(res, "criar índice de auditoria")
+		}
+		log.Printf("✅ Índice de auditoria '%s' criado\n", ec.audit.name)
+	} else if exists.IsError() {
+		return newAppError(ErrCodeBackendError, "erro ao verificar índice de auditoria: [%d]", exists.StatusCode)
+	}
+
+	ec.audit.ready = true
+	return nil
+}
+
+// snapshot lê o documento atual para o campo antes da auditoria, com a versão lida; nil se
+// ele não existir. Uma escrita condicionada a essa versão garante que o antes é exatamente
+// o documento substituído. Outras falhas da leitura são devolvidas, para a escrita não
+// seguir sem condição e com o antes vazio.
+func (ec *ElasticsearchClient) snapshot(ctx context.Context, indexName, docID, routing string) (json.RawMessage, *domain.Versao, error) {
+	if docID == "" {
+		return nil, nil, nil
+	}
+	res, err := esapi.GetRequest{Index: indexName, DocumentID: docID, Routing: routing}.Do(ctx, ec.client)
+	if err != nil {
+		return nil, nil, transportError(err, "ler documento para auditoria")
+	}
+	defer res.Body.Close()
+
+	var doc GetResponse
+	if err := decodeResponse(res, "ler documento para auditoria", &doc); err != nil {
+		if errorCode(err) == ErrCodeNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return doc.Source, doc.versao(), nil
+}
+
+// targetsAuditIndex diz se o índice de uma operação do /query alcança o índice de
+// auditoria, direto ou por um alias, padrão ou lista (ver targetsIndex)
+func (ec *ElasticsearchClient) targetsAuditIndex(ctx context.Context, indexName string) (bool, error) {
+	return ec.targetsIndex(ctx, indexName, ec.audit.name)
+}
+
+// documentRef identifica um documento pelo _id e pela chave de routing
+type documentRef struct {
+	ID      string `json:"_id"`
+	Routing string `json:"routing,omitempty"`
+}
+
+// snapshots lê com um _mget os documentos atuais, com a versão, na ordem de refs; os que
+// não existem voltam com Found=false
+func (ec *ElasticsearchClient) snapshots(ctx context.Context, indexName string, refs []documentRef) ([]GetResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"docs": refs})
+	if err != nil {
+		return nil, wrapAppError(ErrCodeInternal, err, "erro ao codificar _mget")
+	}
+	res, err := esapi.MgetRequest{Index: indexName, Body: bytes.NewReader(body)}.Do(ctx, ec.client)
+	if err != nil {
+		return nil, transportError(err, "ler documentos para auditoria")
+	}
+	defer res.Body.Close()
+
+	var docs MgetResponse
+	if err := decodeResponse(res, "ler documentos para auditoria", &docs); err != nil {
+		return nil, err
+	}
+	if len(docs.Docs) != len(refs) {
+		return nil, newAppError(ErrCodeMalformedResponse, "_mget devolveu %d documentos para %d ids", len(docs.Docs), len(refs))
+	}
+	return docs.Docs, nil
+}
+
+// AuditTrail busca os registros de auditoria de um recebível em ordem de id_registro. O
+// filtro é só o documento_id: o indice gravado é o nome usado na escrita, que pode ser o
+// índice concreto ou um alias, e o recebível continua o mesmo depois de um reindex.
+func (ec *ElasticsearchClient) AuditTrail(ctx context.Context, q AuditTrailQuery) ([]domain.RegistroAuditoria, error) {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"documento_id": q.DocumentoID}},
+				},
+			},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"id_registro": "asc"},
+		},
+		"size": q.Size,
+	}
+	if q.After != "" {
+		body["search_after"] = []interface{}{q.After}
+	}
+
+	result, err := ec.searchIndex(ctx, ec.audit.name, "", body)
+	if errorCode(err) == ErrCodeNotFound {
+		// Índice de auditoria ainda não criado: nenhuma escrita foi feita
+		return []domain.RegistroAuditoria{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	registros := make([]domain.RegistroAuditoria, len(result.Hits.Hits))
+	for i, hit := range result.Hits.Hits {
+		if err := json.Unmarshal(hit.Source, &registros[i]); err != nil {
+			return nil, wrapAppError(ErrCodeMalformedResponse, err, "registro de auditoria '%s' inválido", hit.ID)
+		}
+	}
+	return registros, nil
+}
+
+// RecordAudit acrescenta o registro à trilha em memória
+func (ms *MemoryStore) RecordAudit(ctx context.Context, registro domain.RegistroAuditoria) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.audit = append(ms.audit, registro)
+	return nil
+}
+
+// AuditTrail filtra a trilha em memória pelo recebível, em ordem de id_registro
+func (ms *MemoryStore) AuditTrail(ctx context.Context, q AuditTrailQuery) ([]domain.RegistroAuditoria, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	registros := []domain.RegistroAuditoria{}
+	for _, registro := range ms.audit {
+		if registro.DocumentoID == q.DocumentoID && registro.IDRegistro > q.After {
+			registros = append(registros, registro)
+		}
+	}
+	sort.Slice(registros, func(i, j int) bool {
+		return registros[i].IDRegistro < registros[j].IDRegistro
+	})
+	if len(registros) > q.Size {
+		registros = registros[:q.Size]
+	}
+	return registros, nil
+}
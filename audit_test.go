@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"data-aggregator/domain"
+)
+
+// auditoriaTeste é o índice de auditoria do Elasticsearch falso
+const auditoriaTeste = "auditoria-teste"
+
+// operacoes devolve "operacao:documento_id" de cada registro da página
+func operacoes(page *AuditEntryPage) []string {
+	result := []string{}
+	for _, edge := range page.Edges {
+		result = append(result, edge.Node.Operacao+":"+edge.Node.DocumentoID)
+	}
+	return result
+}
+
+// TestAuditTrailMemoria confere a trilha de um recebível no store em memória: só os
+// registros dele, em ordem de escrita, paginados pelo cursor, com antes e depois
+func TestAuditTrailMemoria(t *testing.T) {
+	ctx := withAuditSource(context.Background(), "teste", domain.OrigemGraphQL)
+	store := newMemoryStoreTeste(t)
+
+	novo := domain.Recebivel{IDRecebivel: "rec-9", IDPagamento: "pag-9", CodigoCliente: "CLI-A", ValorOriginal: domain.Reais(10), DataVencimento: "2025-05-10"}
+	if err := store.CreateReceivable(ctx, &novo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AppendCancelamento(ctx, "rec-1", "pag-1", domain.Cancelamento{IDCancelamento: "can-9", DataCancelamento: "2025-01-06", ValorCancelado: domain.Reais(1)}); err != nil {
+		t.Fatal(err)
+	}
+	novo.ValorOriginal = domain.Reais(20)
+	if err := store.ReplaceReceivable(ctx, &novo); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := auditTrailPage(ctx, store, "rec-9", PageRequest{First: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := operacoes(first); !reflect.DeepEqual(got, []string{"create:rec-9"}) || !first.PageInfo.HasNextPage {
+		t.Fatalf("primeira página = %v (próxima %v)", got, first.PageInfo.HasNextPage)
+	}
+	second, err := auditTrailPage(ctx, store, "rec-9", PageRequest{First: 10, After: first.PageInfo.EndCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := operacoes(second); !reflect.DeepEqual(got, []string{"index:rec-9"}) || second.PageInfo.HasNextPage {
+		t.Fatalf("segunda página = %v (próxima %v)", got, second.PageInfo.HasNextPage)
+	}
+
+	registro := second.Edges[0].Node
+	var antes, depois domain.Recebivel
+	if err := json.Unmarshal(registro.Antes, &antes); err != nil {
+		t.Fatalf("antes %s: %v", registro.Antes, err)
+	}
+	if err := json.Unmarshal(registro.Depois, &depois); err != nil {
+		t.Fatalf("depois %s: %v", registro.Depois, err)
+	}
+	if antes.ValorOriginal != domain.Reais(10) || depois.ValorOriginal != domain.Reais(20) {
+		t.Errorf("valor_original antes %s e depois %s, esperado 10.00 e 20.00", antes.ValorOriginal, depois.ValorOriginal)
+	}
+	if registro.Ator != "teste" || registro.Origem != domain.OrigemGraphQL || registro.Indice != memoryAuditIndex || registro.Routing != "pag-9" {
+		t.Errorf("registro = %+v", registro)
+	}
+
+	if _, err := auditTrailPage(ctx, store, "", PageRequest{First: 1}); errorCode(err) != ErrCodeInvalidArgument {
+		t.Errorf("id vazio: erro %v, esperado %s", err, ErrCodeInvalidArgument)
+	}
+}
+
+// TestAuditTrailElasticsearch confere a busca da trilha: só pelo documento_id (o indice
+// gravado pode ser um alias ou o índice concreto), em ordem de id_registro, depois do cursor
+func TestAuditTrailElasticsearch(t *testing.T) {
+	var query json.RawMessage
+	existe := true
+	client, _ := newFakeElasticsearch(t, ElasticsearchConfig{AuditIndex: auditoriaTeste}, func(method, path string, body []byte) (int, interface{}) {
+		if path != "/"+auditoriaTeste+"/_search" {
+			return esError(http.StatusBadRequest, "illegal_argument_exception")
+		}
+		if !existe {
+			return esError(http.StatusNotFound, "index_not_found_exception")
+		}
+		query = body
+		return http.StatusOK, map[string]interface{}{
+			"hits": map[string]interface{}{
+				"hits": []interface{}{
+					map[string]interface{}{"_id": "r-2", "_source": map[string]interface{}{"id_registro": "r-2", "operacao": "update", "indice": "recebiveis", "documento_id": "rec-1"}},
+					map[string]interface{}{"_id": "r-3", "_source": map[string]interface{}{"id_registro": "r-3", "operacao": "index", "indice": indiceTeste, "documento_id": "rec-1"}},
+				},
+			},
+		}
+	})
+
+	registros, err := client.AuditTrail(context.Background(), AuditTrailQuery{DocumentoID: "rec-1", After: "r-1", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registros) != 2 || registros[0].IDRegistro != "r-2" || registros[1].Indice != indiceTeste {
+		t.Errorf("registros = %+v", registros)
+	}
+	want := `{"query":{"bool":{"filter":[{"term":{"documento_id":"rec-1"}}]}},"search_after":["r-1"],"size":2,"sort":[{"id_registro":"asc"}]}`
+	var got, esperado interface{}
+	json.Unmarshal(query, &got)
+	json.Unmarshal([]byte(want), &esperado)
+	if !reflect.DeepEqual(got, esperado) {
+		t.Errorf("query = %s\nesperado %s", query, want)
+	}
+
+	existe = false
+	registros, err = client.AuditTrail(context.Background(), AuditTrailQuery{DocumentoID: "rec-1", Size: 2})
+	if err != nil || len(registros) != 0 {
+		t.Errorf("sem índice de auditoria: %v, %v; esperado trilha vazia", registros, err)
+	}
+}
+
+// bulkFake guarda os recebíveis do Elasticsearch falso com a versão e responde ao _mget e
+// aos _bulk dos recebíveis e da auditoria
+type bulkFake struct {
+	mu        sync.Mutex
+	docs      map[string]json.RawMessage
+	seqNo     map[string]int
+	acoes     []map[string]interface{} // metadados de cada ação do _bulk de recebíveis
+	registros []domain.RegistroAuditoria
+}
+
+func (f *bulkFake) handle(method, path string, body []byte) (int, interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch path {
+	case "/" + auditoriaTeste:
+		return http.StatusOK, map[string]interface{}{}
+	case "/" + indiceTeste + "/_mget":
+		var req struct {
+			Docs []documentRef `json:"docs"`
+		}
+		json.Unmarshal(body, &req)
+		docs := []interface{}{}
+		for _, ref := range req.Docs {
+			doc := map[string]interface{}{"_index": indiceTeste, "_id": ref.ID, "found": false}
+			if source, ok := f.docs[ref.ID]; ok {
+				doc["found"] = true
+				doc["_source"] = source
+				doc["_seq_no"] = f.seqNo[ref.ID]
+				doc["_primary_term"] = 1
+			}
+			docs = append(docs, doc)
+		}
+		return http.StatusOK, map[string]interface{}{"docs": docs}
+	case "/" + indiceTeste + "/_bulk":
+		items := []interface{}{}
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 1<<20), 1<<20)
+		for scanner.Scan() {
+			var meta map[string]map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &meta)
+			scanner.Scan()
+			source := json.RawMessage(append([]byte(nil), scanner.Bytes()...))
+			for acao, campos := range meta {
+				campos["acao"] = acao
+				f.acoes = append(f.acoes, campos)
+				id := campos["_id"].(string)
+				item := map[string]interface{}{"_index": indiceTeste, "_id": id}
+				_, existe := f.docs[id]
+				switch {
+				case acao == "create" && existe, acao == "index" && campos["if_seq_no"] != float64(f.seqNo[id]):
+					item["status"] = http.StatusConflict
+					item["error"] = map[string]interface{}{"type": "version_conflict_engine_exception", "reason": "versão diferente"}
+				default:
+					f.docs[id] = source
+					f.seqNo[id]++
+					item["status"] = http.StatusOK
+					item["result"] = map[bool]string{true: BulkUpdated, false: BulkCreated}[existe]
+					item["_seq_no"] = f.seqNo[id]
+					item["_primary_term"] = 1
+				}
+				items = append(items, map[string]interface{}{acao: item})
+			}
+		}
+		return http.StatusOK, map[string]interface{}{"errors": false, "items": items}
+	case "/" + auditoriaTeste + "/_bulk":
+		items := []interface{}{}
+		for _, linha := range bytes.Split(bytes.TrimSpace(body), []byte("\n"))[1:] {
+			var registro domain.RegistroAuditoria
+			if json.Unmarshal(linha, &registro) == nil && registro.IDRegistro != "" {
+				f.registros = append(f.registros, registro)
+				items = append(items, map[string]interface{}{"create": map[string]interface{}{"_id": registro.IDRegistro, "status": http.StatusCreated}})
+			}
+		}
+		return http.StatusOK, map[string]interface{}{"errors": false, "items": items}
+	}
+	return esError(http.StatusBadRequest, "illegal_argument_exception")
+}
+
+// TestBulkSnapshotsElasticsearch confere a carga em lote contra o Elasticsearch: o _mget lê
+// os documentos atuais, cada linha vai condicionada à versão lida (ou como create) e o
+// registro de auditoria leva o documento substituído; um id repetido espera a gravação
+// da linha anterior e é condicionado à versão que ela gravou
+func TestBulkSnapshotsElasticsearch(t *testing.T) {
+	fake := &bulkFake{
+		docs:  map[string]json.RawMessage{"rec-1": json.RawMessage(`{"id_recebivel":"rec-1","id_pagamento":"pag-1","valor_original":1}`)},
+		seqNo: map[string]int{"rec-1": 7},
+	}
+	client, es := newFakeElasticsearch(t, ElasticsearchConfig{AuditIndex: auditoriaTeste}, fake.handle)
+
+	ndjson := strings.Join([]string{
+		`{"id_recebivel":"rec-1","id_pagamento":"pag-1","codigo_cliente":"CLI-A","valor_original":10,"data_vencimento":"2025-01-10"}`,
+		`{"id_recebivel":"rec-2","id_pagamento":"pag-2","codigo_cliente":"CLI-B","valor_original":20,"data_vencimento":"2025-01-10"}`,
+		`{"id_recebivel":"rec-1","id_pagamento":"pag-1","codigo_cliente":"CLI-A","valor_original":30,"data_vencimento":"2025-01-10"}`,
+	}, "\n")
+	ctx := withAuditSource(context.Background(), "teste", domain.OrigemBulk)
+	report, err := ingestBulk(ctx, client, &deadLetter{path: t.TempDir() + "/dead-letter.ndjson"}, bufio.NewReader(strings.NewReader(ndjson)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultados := []string{}
+	for _, linha := range report.Linhas {
+		resultados = append(resultados, linha.ID+":"+linha.Resultado)
+	}
+	if want := []string{"rec-1:updated", "rec-2:created", "rec-1:updated"}; !reflect.DeepEqual(resultados, want) || report.Gravados != 3 {
+		t.Fatalf("relatório = %v (%d gravados), esperado %v", resultados, report.Gravados, want)
+	}
+	if n := es.count("POST /" + indiceTeste + "/_mget"); n != 2 {
+		t.Errorf("%d _mget, esperado 2 (o id repetido esvazia o indexer antes de ler de novo)", n)
+	}
+
+	acoes := []string{}
+	for _, acao := range fake.acoes {
+		acoes = append(acoes, strings.TrimSuffix(acao["acao"].(string)+" "+acao["_id"].(string)+" "+stringify(acao["if_seq_no"]), " "))
+	}
+	if want := []string{"index rec-1 7", "create rec-2", "index rec-1 8"}; !reflect.DeepEqual(acoes, want) {
+		t.Errorf("ações do _bulk = %v, esperado %v", acoes, want)
+	}
+
+	antes := map[string][]string{}
+	for _, registro := range fake.registros {
+		var doc struct {
+			ValorOriginal domain.Money `json:"valor_original"`
+		}
+		valor := "nil"
+		if registro.Antes != nil && json.Unmarshal(registro.Antes, &doc) == nil {
+			valor = doc.ValorOriginal.String()
+		}
+		antes[registro.DocumentoID] = append(antes[registro.DocumentoID], valor)
+	}
+	if want := map[string][]string{"rec-1": {"1.00", "10.00"}, "rec-2": {"nil"}}; !reflect.DeepEqual(antes, want) {
+		t.Errorf("antes dos registros de auditoria = %v, esperado %v", antes, want)
+	}
+}
+
+// stringify formata um número do JSON sem casas decimais; nil fica vazio
+func stringify(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// updateFake responde ao GET da auditoria e ao _update de rec-1: conflitos é quantos
+// _update devolvem 409 antes de passar, e getStatus o status do GET
+type updateFake struct {
+	conflitos int
+	getStatus int
+	updates   []string // if_seq_no de cada _update
+}
+
+func (f *updateFake) handle(method, path string, body []byte) (int, interface{}) {
+	switch {
+	case path == "/"+auditoriaTeste:
+		return http.StatusOK, map[string]interface{}{}
+	case strings.HasPrefix(path, "/"+auditoriaTeste+"/_create/"):
+		return http.StatusCreated, map[string]interface{}{"result": "created"}
+	case path == "/"+indiceTeste+"/_doc/rec-1":
+		if f.getStatus != http.StatusOK {
+			return esError(f.getStatus, "search_phase_execution_exception")
+		}
+		return http.StatusOK, map[string]interface{}{
+			"_index": indiceTeste, "_id": "rec-1", "found": true, "_seq_no": 3 + len(f.updates), "_primary_term": 1,
+			"_source": map[string]interface{}{"id_recebivel": "rec-1", "id_pagamento": "pag-1", "valor_original": 10},
+		}
+	case path == "/"+indiceTeste+"/_update/rec-1":
+		f.updates = append(f.updates, stringify(3+len(f.updates)))
+		if len(f.updates) <= f.conflitos {
+			return esError(http.StatusConflict, "version_conflict_engine_exception")
+		}
+		return http.StatusOK, map[string]interface{}{
+			"_index": indiceTeste, "_id": "rec-1", "result": "updated", "_seq_no": 10, "_primary_term": 1,
+			"get": map[string]interface{}{"_source": map[string]interface{}{"id_recebivel": "rec-1", "id_pagamento": "pag-1", "valor_original": 10}},
+		}
+	}
+	return esError(http.StatusBadRequest, "illegal_argument_exception")
+}
+
+// TestUpdateDocumentConflitoDaAuditoria confere que um update sem versão do chamador, que
+// só é condicionado à versão lida para a auditoria, relê e reaplica no conflito, e que um
+// update com versão do chamador devolve o conflito
+func TestUpdateDocumentConflitoDaAuditoria(t *testing.T) {
+	ctx := context.Background()
+	fake := &updateFake{conflitos: 2, getStatus: http.StatusOK}
+	client, _ := newFakeElasticsearch(t, ElasticsearchConfig{AuditIndex: auditoriaTeste}, fake.handle)
+
+	updated, err := client.UpdateDocument(ctx, indiceTeste, "rec-1", "pag-1", map[string]interface{}{"valor_original": 10}, UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.SeqNo != 10 || !reflect.DeepEqual(fake.updates, []string{"3", "4", "5"}) {
+		t.Errorf("seq_no %d após updates condicionados a %v, esperado 10 após [3 4 5]", updated.SeqNo, fake.updates)
+	}
+
+	fake.updates = nil
+	_, err = client.UpdateDocument(ctx, indiceTeste, "rec-1", "pag-1", map[string]interface{}{"valor_original": 10}, UpdateOptions{Versao: &domain.Versao{SeqNo: 3, PrimaryTerm: 1}})
+	if errorCode(err) != ErrCodeConflict || len(fake.updates) != 1 {
+		t.Errorf("com versão: erro %v após %d updates, esperado %s após 1", err, len(fake.updates), ErrCodeConflict)
+	}
+}
+
+// TestSnapshotComErro confere que uma falha ao ler o documento para a auditoria impede a
+// escrita, em vez de ela seguir sem condição e com o antes vazio
+func TestSnapshotComErro(t *testing.T) {
+	ctx := context.Background()
+	fake := &updateFake{getStatus: http.StatusInternalServerError}
+	client, _ := newFakeElasticsearch(t, ElasticsearchConfig{AuditIndex: auditoriaTeste}, fake.handle)
+
+	if _, err := client.UpdateDocument(ctx, indiceTeste, "rec-1", "pag-1", map[string]interface{}{"valor_original": 10}, UpdateOptions{}); err == nil {
+		t.Error("UpdateDocument: esperado o erro da leitura")
+	}
+	if _, err := client.AppendCancelamento(ctx, "rec-1", "pag-1", domain.Cancelamento{IDCancelamento: "can-1", DataCancelamento: "2025-01-05", ValorCancelado: domain.Reais(1)}); err == nil {
+		t.Error("AppendCancelamento: esperado o erro da leitura")
+	}
+	if err := client.IndexDocument(ctx, indiceTeste, "rec-1", "pag-1", map[string]interface{}{"id_recebivel": "rec-1"}); err == nil {
+		t.Error("IndexDocument: esperado o erro da leitura")
+	}
+	if len(fake.updates) != 0 {
+		t.Errorf("%d updates enviados apesar do erro da leitura", len(fake.updates))
+	}
+
+	// Documento inexistente não é erro: a escrita segue sem antes
+	fake.getStatus = http.StatusNotFound
+	if _, _, err := client.snapshot(ctx, indiceTeste, "rec-1", "pag-1"); err != nil {
+		t.Errorf("snapshot de documento inexistente: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Métodos de autenticação de um Principal
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// jwtLeeway tolera a diferença de relógio entre o emissor do token e o servidor
+const jwtLeeway = 30 * time.Second
+
+// Principal é o cliente autenticado de uma requisição
+type Principal struct {
+	Nome   string                 // nome da API key ou subject (sub) do JWT
+	Metodo string                 // AuthMethodAPIKey ou AuthMethodJWT
+	Claims map[string]interface{} // claims do JWT; nil para API key
+}
+
+// Ator identifica o principal nos registros de auditoria, ex.: "jwt:operador@empresa"
+func (p *Principal) Ator() string {
+	return p.Metodo + ":" + p.Nome
+}
+
+// principalContextKey guarda no context o principal autenticado
+type principalContextKey struct{}
+
+// withPrincipal coloca o principal no context da requisição
+func withPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// principalFrom retorna o principal autenticado; false com a autenticação desativada
+func principalFrom(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// Authenticator valida as credenciais de uma requisição. Retorna nil, nil quando a
+// requisição não traz o tipo de credencial que ele trata, para o próximo tentar.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// authChain tenta os authenticators em ordem; a primeira credencial reconhecida decide
+type authChain []Authenticator
+
+func (chain authChain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, auth := range chain {
+		principal, err := auth.Authenticate(r)
+		if err != nil || principal != nil {
+			return principal, err
+		}
+	}
+	return nil, nil
+}
+
+// newAuthenticator monta os authenticators configurados. Sem nenhum, só retorna nil (rotas
+// abertas) se a autenticação foi desativada explicitamente com auth.disabled.
+func newAuthenticator(cfg AuthConfig) (Authenticator, error) {
+	var chain authChain
+	if len(cfg.APIKeys) > 0 {
+		chain = append(chain, newAPIKeyAuthenticator(cfg.APIKeys))
+	}
+	if cfg.JWTKeyFile != "" {
+		auth, err := newJWTAuthenticator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, auth)
+	}
+	if len(chain) == 0 {
+		if !cfg.Disabled {
+			return nil, fmt.Errorf("nenhuma autenticação configurada: configure auth.api_keys ou auth.jwt_key_file, ou use auth.disabled: true (-auth-disabled) para subir com as rotas abertas")
+		}
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// authMiddleware exige credenciais válidas e coloca o principal no context. Sem
+// authenticator (auth.disabled) as requisições passam sem principal.
+func authMiddleware(auth Authenticator, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.Authenticate(r)
+		if err == nil && principal == nil {
+			err = newAppError(ErrCodeUnauthenticated, "credenciais ausentes: use o header X-API-Key ou Authorization: Bearer <jwt>")
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="data-aggregator"`)
+			writeAppError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+// apiKeyAuthenticator valida API keys estáticas do header X-API-Key ou de
+// Authorization: ApiKey <chave>. Guarda só o hash das chaves.
+type apiKeyAuthenticator struct {
+	keys []apiKeyHash
+}
+
+type apiKeyHash struct {
+	nome string
+	hash [sha256.Size]byte
+}
+
+func newAPIKeyAuthenticator(keys []APIKeyConfig) *apiKeyAuthenticator {
+	auth := &apiKeyAuthenticator{}
+	for _, key := range keys {
+		auth.keys = append(auth.keys, apiKeyHash{nome: key.Name, hash: sha256.Sum256([]byte(key.Key))})
+	}
+	return auth
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key, _ = authorizationValue(r, "ApiKey")
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	// Compara com todas as chaves em tempo constante, para o tempo não revelar qual casou
+	hash := sha256.Sum256([]byte(key))
+	nome := ""
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+			nome = k.nome
+		}
+	}
+	if nome == "" {
+		return nil, newAppError(ErrCodeUnauthenticated, "API key inválida")
+	}
+	return &Principal{Nome: nome, Metodo: AuthMethodAPIKey}, nil
+}
+
+// jwtAuthenticator valida JWTs de Authorization: Bearer <token>, assinados com HS256
+// (segredo compartilhado) ou RS256 (chave pública do emissor), lidos do arquivo de chave
+type jwtAuthenticator struct {
+	algorithm string
+	key       interface{}
+	parser    *jwt.Parser
+}
+
+func newJWTAuthenticator(cfg AuthConfig) (*jwtAuthenticator, error) {
+	data, err := os.ReadFile(cfg.JWTKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("auth.jwt_key_file: %w", err)
+	}
+
+	auth := &jwtAuthenticator{algorithm: cfg.JWTAlgorithm}
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("auth.jwt_key_file: o segredo HS256 deve ter pelo menos 32 bytes")
+		}
+		auth.key = secret
+	case "RS256":
+		auth.key, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth.jwt_key_file: chave pública RS256 inválida: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("auth.jwt_algorithm inválido '%s': use HS256 ou RS256", cfg.JWTAlgorithm)
+	}
+
+	// Só o algoritmo configurado é aceito: um token com alg none ou HS256 assinado com a
+	// chave pública RS256 é recusado antes da verificação
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.JWTAlgorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.JWTIssuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(cfg.JWTAudience))
+	}
+	auth.parser = jwt.NewParser(options...)
+	return auth, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := authorizationValue(r, "Bearer")
+	if !ok {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return a.key, nil
+	})
+	if err != nil {
+		return nil, wrapAppError(ErrCodeUnauthenticated, err, "JWT inválido")
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, newAppError(ErrCodeUnauthenticated, "JWT inválido: claim sub é obrigatória")
+	}
+	return &Principal{Nome: subject, Metodo: AuthMethodJWT, Claims: claims}, nil
+}
+
+// authorizationValue lê a credencial do header Authorization com o esquema informado
+func authorizationValue(r *http.Request, scheme string) (string, bool) {
+	header := r.Header.Get("Authorization")
+	prefix := scheme + " "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// segredoTeste é o segredo HS256 dos testes, com os 32 bytes mínimos
+const segredoTeste = "segredo-hs256-dos-testes-32-bytes"
+
+// chaveTeste é uma API key com o tamanho mínimo
+const chaveTeste = "chave-do-painel-financeiro"
+
+// writeKeyFile grava o arquivo de chave num diretório temporário
+func writeKeyFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwt.key")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// requestWith monta uma requisição com o header informado
+func requestWith(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+// assinar gera o token com o método e a chave informados
+func assinar(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, key interface{}) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	auth, err := newAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{{Name: "painel", Key: chaveTeste}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantNome string
+		wantCode string
+	}{
+		{name: "X-API-Key", header: "X-API-Key", value: chaveTeste, wantNome: "painel"},
+		{name: "Authorization ApiKey", header: "Authorization", value: "ApiKey " + chaveTeste, wantNome: "painel"},
+		{name: "chave inválida", header: "X-API-Key", value: chaveTeste + "x", wantCode: ErrCodeUnauthenticated},
+		{name: "sem credencial", wantCode: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := auth.Authenticate(requestWith(tt.header, tt.value))
+			if tt.wantCode != "" {
+				if err == nil || errorCode(err) != tt.wantCode {
+					t.Fatalf("erro = %v, esperado %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNome == "" {
+				if principal != nil {
+					t.Fatalf("principal = %+v, esperado nenhum", principal)
+				}
+				return
+			}
+			if principal == nil || principal.Nome != tt.wantNome || principal.Metodo != AuthMethodAPIKey {
+				t.Fatalf("principal = %+v, esperado %s", principal, tt.wantNome)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	privada, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&privada.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publica := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	hs256, err := newAuthenticator(AuthConfig{JWTKeyFile: writeKeyFile(t, []byte(segredoTeste+"\n")), JWTAlgorithm: "HS256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256, err := newAuthenticator(AuthConfig{JWTKeyFile: writeKeyFile(t, publica), JWTAlgorithm: "RS256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validas := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "operador@empresa", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	semExp, semSub, expirado := validas(), validas(), validas()
+	delete(semExp, "exp")
+	delete(semSub, "sub")
+	expirado["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		auth   Authenticator
+		token  string
+		valido bool
+	}{
+		{name: "HS256", auth: hs256, token: assinar(t, jwt.SigningMethodHS256, validas(), []byte(segredoTeste)), valido: true},
+		{name: "RS256", auth: rs256, token: assinar(t, jwt.SigningMethodRS256, validas(), privada), valido: true},
+		{name: "HS256 com outro segredo", auth: hs256, token: assinar(t, jwt.SigningMethodHS256, validas(), []byte(segredoTeste+"x"))},
+		// Confusão de algoritmo: HS256 assinado com a chave pública RS256 como segredo
+		{name: "HS256 contra chave RS256", auth: rs256, token: assinar(t, jwt.SigningMethodHS256, validas(), publica)},
+		{name: "RS256 contra segredo HS256", auth: hs256, token: assinar(t, jwt.SigningMethodRS256, validas(), privada)},
+		{name: "alg none", auth: hs256, token: assinar(t, jwt.SigningMethodNone, validas(), jwt.UnsafeAllowNoneSignatureType)},
+		{name: "alg none contra RS256", auth: rs256, token: assinar(t, jwt.SigningMethodNone, validas(), jwt.UnsafeAllowNoneSignatureType)},
+		{name: "expirado", auth: hs256, token: assinar(t, jwt.SigningMethodHS256, expirado, []byte(segredoTeste))},
+		{name: "sem exp", auth: hs256, token: assinar(t, jwt.SigningMethodHS256, semExp, []byte(segredoTeste))},
+		{name: "sem sub", auth: rs256, token: assinar(t, jwt.SigningMethodRS256, semSub, privada)},
+		{name: "malformado", auth: hs256, token: "nao.e.jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := tt.auth.Authenticate(requestWith("Authorization", "Bearer "+tt.token))
+			if !tt.valido {
+				if err == nil || errorCode(err) != ErrCodeUnauthenticated {
+					t.Fatalf("principal = %+v, erro = %v; esperado %s", principal, err, ErrCodeUnauthenticated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Ator() != "jwt:operador@empresa" || principal.Claims["sub"] != "operador@empresa" {
+				t.Fatalf("principal = %+v", principal)
+			}
+		})
+	}
+}
+
+// TestNewAuthenticatorSemConfiguracao confere que o servidor não sobe sem autenticação, a
+// não ser com auth.disabled
+func TestNewAuthenticatorSemConfiguracao(t *testing.T) {
+	if auth, err := newAuthenticator(AuthConfig{JWTAlgorithm: "HS256"}); err == nil {
+		t.Errorf("sem autenticação configurada: %v, esperado erro", auth)
+	}
+	if auth, err := newAuthenticator(AuthConfig{Disabled: true}); auth != nil || err != nil {
+		t.Errorf("com auth.disabled: %v, %v; esperado nenhum authenticator", auth, err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Auth = AuthConfig{Disabled: true, APIKeys: []APIKeyConfig{{Name: "painel", Key: chaveTeste}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "auth.disabled") {
+		t.Errorf("auth.disabled com api_keys: erro %v", err)
+	}
+}
+
+// TestAPIKeysEnvMalformado confere que um item sem nome:chave não aparece na mensagem de
+// erro, já que pode ser a própria chave
+func TestAPIKeysEnvMalformado(t *testing.T) {
+	t.Setenv("AGGREGATOR_API_KEYS", "painel:"+chaveTeste+",chave-sem-nome-muito-secreta")
+	_, err := LoadConfig(nil)
+	if err == nil {
+		t.Fatal("AGGREGATOR_API_KEYS malformado: esperado erro")
+	}
+	if strings.Contains(err.Error(), "chave-sem-nome-muito-secreta") {
+		t.Errorf("o erro expõe a chave: %v", err)
+	}
+	if !strings.Contains(err.Error(), "item 2") {
+		t.Errorf("erro = %v, esperado a posição do item", err)
+	}
+}
+
+// TestServeMuxAutenticacao confere que as rotas exigem credenciais, exceto /health, e que o
+// principal chega ao handler
+func TestServeMuxAutenticacao(t *testing.T) {
+	auth, err := newAuthenticator(AuthConfig{APIKeys: []APIKeyConfig{{Name: "painel", Key: chaveTeste}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphql := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := auditSourceFrom(r.Context())
+		json.NewEncoder(w).Encode(map[string]string{"ator": source.Ator})
+	})
+	server := httptest.NewServer(newServeMux(DefaultConfig(), newMemoryStoreTeste(t), auth, graphql))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/health sem credenciais: status %d, esperado 200", resp.StatusCode)
+	}
+
+	for _, rota := range []string{"/graphql", "/saldo-cliente", "/export/receivables", "/receivables/_bulk"} {
+		resp, err := http.Post(server.URL+rota, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || body.Code != ErrCodeUnauthenticated || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s sem credenciais: status %d, code %q", rota, resp.StatusCode, body.Code)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/graphql", strings.NewReader("{}"))
+	req.Header.Set("X-API-Key", chaveTeste)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Ator string `json:"ator"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK || body.Ator != "api_key:painel" {
+		t.Errorf("/graphql com API key: status %d, ator %q", resp.StatusCode, body.Ator)
+	}
+}
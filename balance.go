@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"data-aggregator/domain"
+)
+
+// BalanceEngine é o único ponto de cálculo de saldo da aplicação: o endpoint REST
+// /saldo-cliente e todos os campos de saldo do GraphQL passam por ele.
+//
+// Fórmula, sempre em centavos (domain.Money):
+//
+//	saldo do recebível = valor_original - Σ cancelamentos.valor_cancelado - Σ negociacoes.valor_negociado
+//	saldo do cliente   = Σ saldo dos recebíveis do cliente com data_vencimento no período
+//
+// O cliente é filtrado por igualdade exata em codigo_cliente (term no campo keyword)
+// e o período é inclusivo nas duas pontas; data_inicio e data_fim vazias não limitam.
+// Um saldo negativo (cancelamentos acima do valor original) é devolvido como está.
+type BalanceEngine struct {
+	store ReceivableStore
+}
+
+// NewBalanceEngine cria o motor de saldo sobre o store informado
+func NewBalanceEngine(store ReceivableStore) *BalanceEngine {
+	return &BalanceEngine{store: store}
+}
+
+// BalanceQuery identifica o cliente e o período de vencimento de um saldo. Com AsOf o saldo
+// é reconstruído naquela data: só cancelamentos e negociações datados até ela são descontados.
+type BalanceQuery struct {
+	CodigoCliente string `json:"codigo_cliente"`
+	DataInicio    string `json:"data_inicio"`
+	DataFim       string `json:"data_fim"`
+	AsOf          string `json:"as_of,omitempty"`
+}
+
+// Validate verifica o cliente e o formato das datas do período e do as_of
+func (q BalanceQuery) Validate() error {
+	if q.CodigoCliente == "" {
+		return newAppError(ErrCodeInvalidArgument, "codigo_cliente é obrigatório")
+	}
+	if err := validarAsOf(q.AsOf); err != nil {
+		return err
+	}
+	for _, data := range []string{q.DataInicio, q.DataFim} {
+		if data == "" {
+			continue
+		}
+		if _, err := time.Parse(domain.LayoutData, data); err != nil {
+			return newAppError(ErrCodeInvalidArgument, "data inválida '%s': use o formato yyyy-MM-dd", data)
+		}
+	}
+	if q.DataInicio != "" && q.DataFim != "" && q.DataInicio > q.DataFim {
+		return newAppError(ErrCodeInvalidArgument, "data_inicio '%s' é posterior a data_fim '%s'", q.DataInicio, q.DataFim)
+	}
+	return nil
+}
+
+// validarAsOf verifica o formato da data de reconstrução do saldo (opcional)
+func validarAsOf(asOf string) error {
+	if asOf == "" {
+		return nil
+	}
+	if _, err := time.Parse(domain.LayoutData, asOf); err != nil {
+		return newAppError(ErrCodeInvalidArgument, "as_of inválido '%s': use o formato yyyy-MM-dd", asOf)
+	}
+	return nil
+}
+
+// Periodo representa o intervalo de vencimento considerado em um saldo
+type Periodo struct {
+	Inicio string `json:"inicio"`
+	Fim    string `json:"fim"`
+}
+
+// CustomerBalanceReport é o saldo de um cliente no formato devolvido pela API
+type CustomerBalanceReport struct {
+	CodigoCliente   string       `json:"codigo_cliente"`
+	Periodo         Periodo      `json:"periodo"`
+	AsOf            string       `json:"as_of,omitempty"`
+	TotalRecebiveis int          `json:"total_recebiveis"`
+	ValorOriginal   domain.Money `json:"valor_original"`
+	TotalCancelado  domain.Money `json:"total_cancelado"`
+	TotalNegociado  domain.Money `json:"total_negociado"`
+	SaldoTotal      domain.Money `json:"saldo_total"`
+	SaldoFormatado  string       `json:"saldo_formatado"`
+}
+
+// CustomerBalance calcula o saldo de um cliente no período
+func (be *BalanceEngine) CustomerBalance(ctx context.Context, query BalanceQuery) (*CustomerBalanceReport, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	balance, err := be.store.CustomerBalance(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	saldo := balance.Saldo()
+	return &CustomerBalanceReport{
+		CodigoCliente:   query.CodigoCliente,
+		Periodo:         Periodo{Inicio: query.DataInicio, Fim: query.DataFim},
+		AsOf:            query.AsOf,
+		TotalRecebiveis: balance.TotalRecebiveis,
+		ValorOriginal:   balance.ValorOriginal,
+		TotalCancelado:  balance.TotalCancelado,
+		TotalNegociado:  balance.TotalNegociado,
+		SaldoTotal:      saldo,
+		SaldoFormatado:  saldo.Format(),
+	}, nil
+}
+
+// ReceivableBalance busca um recebível para expor seu saldo; o saldo_disponivel é
+// calculado por domain.Recebivel.Saldo, a mesma fórmula somada em CustomerBalance.
+// Com asOf o recebível volta como estava naquela data (domain.Recebivel.AsOf).
+func (be *BalanceEngine) ReceivableBalance(ctx context.Context, id, idPagamento, asOf string) (*domain.Recebivel, error) {
+	if id == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "id é obrigatório")
+	}
+	if err := validarAsOf(asOf); err != nil {
+		return nil, err
+	}
+
+	recebivel, err := be.store.GetReceivable(ctx, id, idPagamento)
+	if err != nil || asOf == "" {
+		return recebivel, err
+	}
+	return recebivel.AsOf(asOf), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-aggregator/domain"
+	"github.com/graphql-go/graphql"
+)
+
+// saldoGolden é o saldo de CLI-GOLD em testdata/saldo_cliente.ndjson:
+// (1000.10 - 0.10 - 0.20 - 333.33) + 250.05 + (0.30 - 0.10)
+const saldoGolden = domain.Money(91672)
+
+// idsGolden são os recebíveis de CLI-GOLD no fixture
+var idsGolden = []string{"gold-1", "gold-2", "gold-3"}
+
+// executarGraphQL roda a query no schema e decodifica o campo data em out
+func executarGraphQL(t *testing.T, schema graphql.Schema, query string, out interface{}) {
+	t.Helper()
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: query})
+	if result.HasErrors() {
+		t.Fatalf("graphql %s: %v", query, result.Errors)
+	}
+	raw, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		t.Fatalf("decodificar %s: %v", raw, err)
+	}
+}
+
+// TestSaldoClienteGolden confere que /saldo-cliente, getCustomerBalance e a soma de
+// getReceivableBalanceById fecham no mesmo saldo, que passa pelo mesmo BalanceEngine
+func TestSaldoClienteGolden(t *testing.T) {
+	store, err := LoadMemoryStore("testdata/saldo_cliente.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := initGraphQLSchema(store, DefaultConfig().Server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// REST
+	body := `{"codigo_cliente":"CLI-GOLD","data_inicio":"2025-01-01","data_fim":"2025-12-31"}`
+	recorder := httptest.NewRecorder()
+	saldoClienteHandler(NewBalanceEngine(store)).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/saldo-cliente", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("/saldo-cliente: status %d: %s", recorder.Code, recorder.Body)
+	}
+	var rest CustomerBalanceReport
+	if err := json.NewDecoder(recorder.Body).Decode(&rest); err != nil {
+		t.Fatal(err)
+	}
+	if rest.SaldoTotal != saldoGolden || rest.TotalRecebiveis != len(idsGolden) {
+		t.Errorf("/saldo-cliente: saldo_total %s com %d recebíveis, esperado %s com %d", rest.SaldoTotal, rest.TotalRecebiveis, saldoGolden, len(idsGolden))
+	}
+
+	// GraphQL: saldo do cliente
+	var balance struct {
+		GetCustomerBalance struct {
+			SaldoTotal      domain.Money `json:"saldo_total"`
+			TotalRecebiveis int          `json:"total_recebiveis"`
+			SaldoFormatado  string       `json:"saldo_formatado"`
+		} `json:"getCustomerBalance"`
+	}
+	executarGraphQL(t, schema, `{ getCustomerBalance(codigo_cliente: "CLI-GOLD", data_inicio: "2025-01-01", data_fim: "2025-12-31") { saldo_total total_recebiveis saldo_formatado } }`, &balance)
+	if balance.GetCustomerBalance.SaldoTotal != saldoGolden {
+		t.Errorf("getCustomerBalance: saldo_total %s, esperado %s", balance.GetCustomerBalance.SaldoTotal, saldoGolden)
+	}
+	if balance.GetCustomerBalance.SaldoFormatado != rest.SaldoFormatado {
+		t.Errorf("getCustomerBalance: saldo_formatado %q, /saldo-cliente %q", balance.GetCustomerBalance.SaldoFormatado, rest.SaldoFormatado)
+	}
+
+	// GraphQL: soma dos saldos por recebível
+	var soma domain.Money
+	for _, id := range idsGolden {
+		var recebivel struct {
+			GetReceivableBalanceById struct {
+				SaldoDisponivel domain.Money `json:"saldo_disponivel"`
+			} `json:"getReceivableBalanceById"`
+		}
+		executarGraphQL(t, schema, fmt.Sprintf(`{ getReceivableBalanceById(id: %q) { saldo_disponivel } }`, id), &recebivel)
+		soma += recebivel.GetReceivableBalanceById.SaldoDisponivel
+	}
+	if soma != saldoGolden {
+		t.Errorf("soma de getReceivableBalanceById: %s, esperado %s", soma, saldoGolden)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// maxBreakdownGroups limita os grupos de um breakdown; a composite aggregation é paginada
+// internamente até esse total
+const maxBreakdownGroups = 10000
+
+// breakdownDimensions são os campos do documento pelos quais um breakdown pode agrupar
+var breakdownDimensions = []string{"modalidade", "codigo_produto", "codigo_produto_parceiro", "codigo_cliente"}
+
+// BreakdownQuery agrupa os recebíveis do filtro pelas dimensões, na ordem informada
+type BreakdownQuery struct {
+	Filter     ReceivableFilter
+	Dimensions []string
+}
+
+// DimensionValue é o valor de uma dimensão na chave de um grupo
+type DimensionValue struct {
+	Dimensao string `json:"dimensao"`
+	Valor    string `json:"valor"`
+}
+
+// BreakdownGroup é uma combinação de valores das dimensões com os totais dos seus recebíveis
+type BreakdownGroup struct {
+	Chaves []DimensionValue `json:"chaves"`
+	Totais BalanceTotals    `json:"totais"`
+}
+
+// newBreakdownGroup monta o grupo a partir dos valores das dimensões (na ordem da query)
+func newBreakdownGroup(dimensions []string, valores []interface{}) BreakdownGroup {
+	chaves := make([]DimensionValue, len(dimensions))
+	for i, dimension := range dimensions {
+		chaves[i] = DimensionValue{Dimensao: dimension, Valor: fmt.Sprint(valores[i])}
+	}
+	return BreakdownGroup{Chaves: chaves}
+}
+
+// Breakdown soma os recebíveis do filtro por combinação de valores das dimensões, em
+// ordem crescente das chaves; combinações sem recebíveis não aparecem
+func (be *BalanceEngine) Breakdown(ctx context.Context, q BreakdownQuery) ([]BreakdownGroup, error) {
+	if len(q.Dimensions) == 0 {
+		return nil, newAppError(ErrCodeInvalidArgument, "informe ao menos uma dimensão")
+	}
+	vistas := map[string]bool{}
+	for _, dimension := range q.Dimensions {
+		if !containsString(breakdownDimensions, dimension) {
+			return nil, newAppError(ErrCodeInvalidArgument, "dimensão inválida '%s'", dimension)
+		}
+		if vistas[dimension] {
+			return nil, newAppError(ErrCodeInvalidArgument, "dimensão '%s' repetida", dimension)
+		}
+		vistas[dimension] = true
+	}
+	if err := q.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	return be.store.Breakdown(ctx, q)
+}
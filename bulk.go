@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"data-aggregator/domain"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+)
+
+const (
+	// bulkWorkers, bulkFlushBytes e bulkFlushInterval configuram o BulkIndexer da carga em
+	// lote, nos valores que o seeder usa sem esbarrar em 429
+	bulkWorkers       = 5
+	bulkFlushBytes    = 2e+6
+	bulkFlushInterval = 5 * time.Second
+	// bulkSnapshotBatch é quantos itens da carga têm o documento atual lido num mesmo _mget,
+	// para a auditoria e a pré-condição de versão
+	bulkSnapshotBatch = 500
+	// maxBulkLineBytes limita o tamanho de uma linha do NDJSON recebido
+	maxBulkLineBytes = 1 << 20
+)
+
+// Resultados de uma linha da carga em lote; os de sucesso são o result do Elasticsearch
+const (
+	BulkCreated  = "created"
+	BulkUpdated  = "updated"
+	BulkRejected = "rejected"
+)
+
+// BulkItem é um recebível de uma linha da carga, com a linha original para o dead-letter
+type BulkItem struct {
+	Linha     int
+	Raw       []byte
+	Recebivel *domain.Recebivel
+}
+
+// BulkItemResult é o resultado de uma linha da carga
+type BulkItemResult struct {
+	Linha     int    `json:"linha"`
+	ID        string `json:"id,omitempty"`
+	Resultado string `json:"resultado"`
+	Erro      string `json:"erro,omitempty"`
+}
+
+// ReceivableBulk grava recebíveis em lote. Add enfileira; o resultado de cada item chega
+// no callback informado ao abrir o lote, possivelmente de outra goroutine, até Close voltar.
+type ReceivableBulk interface {
+	Add(ctx context.Context, item BulkItem) error
+	Close(ctx context.Context) error
+}
+
+// BulkReport é a resposta de POST /receivables/_bulk
+type BulkReport struct {
+	Total      int              `json:"total"`
+	Gravados   int              `json:"gravados"`
+	Rejeitados int              `json:"rejeitados"`
+	DeadLetter string           `json:"dead_letter,omitempty"` // arquivo com as linhas rejeitadas
+	Linhas     []BulkItemResult `json:"linhas"`
+}
+
+// deadLetter acrescenta as linhas rejeitadas a um arquivo NDJSON, uma por linha, com o
+// erro e a linha original; o arquivo é compartilhado por todas as cargas do servidor
+type deadLetter struct {
+	path string
+	mu   sync.Mutex
+}
+
+// write grava o registro de uma linha rejeitada
+func (dl *deadLetter) write(record map[string]interface{}) error {
+	registro, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	file, err := os.OpenFile(dl.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(registro, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// bulkHandler recebe recebíveis em NDJSON, um documento por linha, e os grava em lote
+// (index, roteado por id_pagamento, sem refresh). Linhas inválidas e itens recusados pelo
+// Elasticsearch não interrompem a carga: vão para o relatório e para o dead-letter.
+func bulkHandler(store ReceivableStore, deadLetterPath string) http.HandlerFunc {
+	dl := &deadLetter{path: deadLetterPath}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "Método não permitido. Use POST",
+			})
+			return
+		}
+
+		// Uma carga diária passa dos timeouts pensados para as consultas
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		report, err := ingestBulk(r.Context(), store, dl, bufio.NewReader(r.Body))
+		if err != nil && report == nil {
+			writeAppError(w, err)
+			return
+		}
+		if err != nil {
+			// Carga interrompida: o relatório traz as linhas já gravadas ou rejeitadas
+			code := errorCode(err)
+			w.WriteHeader(appErrorStatus(code))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     err.Error(),
+				"code":      code,
+				"relatorio": report,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// ingestBulk lê o NDJSON e grava os recebíveis pelo ReceivableBulk do store. Se a carga é
+// interrompida, o relatório parcial volta junto com o erro, com as linhas que já tiveram
+// resultado.
+func ingestBulk(ctx context.Context, store ReceivableStore, dl *deadLetter, body *bufio.Reader) (*BulkReport, error) {
+	lote := time.Now().UTC().Format(time.RFC3339Nano)
+	report := &BulkReport{Linhas: []BulkItemResult{}}
+
+	var mu sync.Mutex
+	var dlErr error
+	registrar := func(item BulkItem, result BulkItemResult) {
+		var err error
+		if result.Resultado == BulkRejected {
+			err = dl.write(map[string]interface{}{
+				"lote":      lote,
+				"linha":     item.Linha,
+				"erro":      result.Erro,
+				"documento": string(item.Raw),
+			})
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Linhas = append(report.Linhas, result)
+		if result.Resultado == BulkRejected {
+			report.Rejeitados++
+			report.DeadLetter = dl.path
+		} else {
+			report.Gravados++
+		}
+		if err != nil && dlErr == nil {
+			dlErr = err
+		}
+	}
+
+	bulk, err := store.NewReceivableBulk(ctx, registrar)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxBulkLineBytes)
+	linha := 0
+	for scanner.Scan() {
+		linha++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		item := BulkItem{Linha: linha, Raw: append([]byte(nil), raw...)}
+		report.Total++
+
+		var recebivel domain.Recebivel
+		if err := json.Unmarshal(raw, &recebivel); err != nil {
+			registrar(item, BulkItemResult{Linha: linha, Resultado: BulkRejected, Erro: fmt.Sprintf("JSON inválido: %v", err)})
+			continue
+		}
+		if err := recebivel.Validate(); err != nil {
+			registrar(item, BulkItemResult{Linha: linha, ID: recebivel.DocumentID(), Resultado: BulkRejected, Erro: err.Error()})
+			continue
+		}
+		item.Recebivel = &recebivel
+		if err := bulk.Add(ctx, item); err != nil {
+			// O Close espera os callbacks dos itens já enfileirados antes de o relatório sair
+			bulk.Close(ctx)
+			return sortBulkReport(report), wrapAppError(ErrCodeBackendError, err, "carga interrompida na linha %d", linha)
+		}
+	}
+	readErr := scanner.Err()
+
+	if err := bulk.Close(ctx); err != nil {
+		return sortBulkReport(report), wrapAppError(ErrCodeBackendError, err, "erro ao concluir a carga")
+	}
+	if readErr != nil {
+		return sortBulkReport(report), wrapAppError(ErrCodeInvalidArgument, readErr, "erro ao ler o NDJSON após a linha %d (%d linha(s) já gravadas ou rejeitadas)", linha, report.Total)
+	}
+	if dlErr != nil {
+		return sortBulkReport(report), wrapAppError(ErrCodeInternal, dlErr, "erro ao gravar o dead-letter %s", dl.path)
+	}
+	return sortBulkReport(report), nil
+}
+
+// sortBulkReport ordena as linhas do relatório pelo número da linha, já que os resultados
+// chegam na ordem em que o Elasticsearch os devolve
+func sortBulkReport(report *BulkReport) *BulkReport {
+	sort.Slice(report.Linhas, func(i, j int) bool {
+		return report.Linhas[i].Linha < report.Linhas[j].Linha
+	})
+	return report
+}
+
+// esReceivableBulk grava o lote com o esutil.BulkIndexer; os registros de auditoria dos
+// itens gravados vão por um segundo BulkIndexer, no índice de auditoria.
+//
+// Os itens esperam em pendentes até um _mget ler, de bulkSnapshotBatch em bulkSnapshotBatch,
+// os documentos que eles vão substituir: cada item vai para o indexer condicionado à versão
+// lida (ou como create, se o documento não existe), então o antes da auditoria é exatamente
+// o documento substituído. Um item alterado por outra escrita no meio é rejeitado com o
+// conflito e vai para o dead-letter.
+type esReceivableBulk struct {
+	ec        *ElasticsearchClient
+	indexer   esutil.BulkIndexer
+	auditor   esutil.BulkIndexer
+	onResult  func(BulkItem, BulkItemResult)
+	pendentes []pendingBulkItem
+	ids       map[string]bool // ids pendentes ou enfileirados no indexer atual
+}
+
+// pendingBulkItem é um item da carga com o corpo já codificado, à espera do _mget
+type pendingBulkItem struct {
+	item BulkItem
+	body []byte
+}
+
+// NewReceivableBulk abre um BulkIndexer no índice de recebíveis
+func (ec *ElasticsearchClient) NewReceivableBulk(ctx context.Context, onResult func(BulkItem, BulkItemResult)) (ReceivableBulk, error) {
+	if err := ec.ensureAuditIndex(ctx); err != nil {
+		return nil, err
+	}
+	auditor, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:         ec.audit.name,
+		Client:        ec.client,
+		NumWorkers:    1,
+		FlushBytes:    bulkFlushBytes,
+		FlushInterval: bulkFlushInterval,
+		OnError: func(ctx context.Context, err error) {
+			log.Printf("❌ bulk de auditoria: %v", err)
+		},
+	})
+	if err != nil {
+		return nil, wrapAppError(ErrCodeInternal, err, "erro ao criar BulkIndexer de auditoria")
+	}
+
+	indexer, err := ec.newReceivableIndexer()
+	if err != nil {
+		auditor.Close(ctx)
+		return nil, err
+	}
+	return &esReceivableBulk{ec: ec, indexer: indexer, auditor: auditor, onResult: onResult, ids: map[string]bool{}}, nil
+}
+
+// newReceivableIndexer cria o BulkIndexer dos recebíveis da carga
+func (ec *ElasticsearchClient) newReceivableIndexer() (esutil.BulkIndexer, error) {
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:         ec.index,
+		Client:        ec.client,
+		NumWorkers:    bulkWorkers,
+		FlushBytes:    bulkFlushBytes,
+		FlushInterval: bulkFlushInterval,
+		OnError: func(ctx context.Context, err error) {
+			log.Printf("bulk de recebíveis: %v", err)
+		},
+	})
+	if err != nil {
+		return nil, wrapAppError(ErrCodeInternal, err, "erro ao criar BulkIndexer")
+	}
+	return indexer, nil
+}
+
+// Add guarda o documento com o saldo_disponivel recalculado até o próximo _mget
+func (b *esReceivableBulk) Add(ctx context.Context, item BulkItem) error {
+	item.Recebivel.UpdateSaldoDisponivel()
+	body, err := json.Marshal(item.Recebivel)
+	if err != nil {
+		return err
+	}
+
+	// Um id repetido na carga substitui o documento do item anterior: ele precisa estar
+	// gravado antes do _mget, então o indexer atual é esvaziado
+	id := item.Recebivel.DocumentID()
+	if b.ids[id] {
+		if err := b.drain(ctx); err != nil {
+			return err
+		}
+	}
+
+	b.pendentes = append(b.pendentes, pendingBulkItem{item: item, body: body})
+	b.ids[id] = true
+	if len(b.pendentes) >= bulkSnapshotBatch {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+// flush lê com um _mget os documentos atuais dos itens pendentes e os enfileira no indexer
+func (b *esReceivableBulk) flush(ctx context.Context) error {
+	if len(b.pendentes) == 0 {
+		return nil
+	}
+	refs := make([]documentRef, len(b.pendentes))
+	for i, pendente := range b.pendentes {
+		refs[i] = documentRef{ID: pendente.item.Recebivel.DocumentID(), Routing: pendente.item.Recebivel.IDPagamento}
+	}
+	atuais, err := b.ec.snapshots(ctx, b.ec.index, refs)
+	if err != nil {
+		return err
+	}
+
+	source := auditSourceFrom(ctx)
+	for i, pendente := range b.pendentes {
+		if err := b.enqueue(ctx, pendente, atuais[i], source); err != nil {
+			return err
+		}
+	}
+	b.pendentes = b.pendentes[:0]
+	return nil
+}
+
+// drain enfileira os pendentes e fecha o indexer, esperando todas as gravações, e abre outro
+func (b *esReceivableBulk) drain(ctx context.Context) error {
+	if err := b.flush(ctx); err != nil {
+		return err
+	}
+	if err := b.indexer.Close(ctx); err != nil {
+		return err
+	}
+	indexer, err := b.ec.newReceivableIndexer()
+	if err != nil {
+		return err
+	}
+	b.indexer = indexer
+	b.ids = map[string]bool{}
+	return nil
+}
+
+// enqueue enfileira o item roteado por id_pagamento: como create se o documento não existe,
+// senão como index condicionado à versão lida no _mget
+func (b *esReceivableBulk) enqueue(ctx context.Context, pendente pendingBulkItem, atual GetResponse, source auditSource) error {
+	item, recebivel, body := pendente.item, pendente.item.Recebivel, pendente.body
+	id := recebivel.DocumentID()
+
+	bulkItem := esutil.BulkIndexerItem{
+		Action:     "create",
+		DocumentID: id,
+		Routing:    recebivel.IDPagamento,
+		Body:       bytes.NewReader(body),
+	}
+	var antes json.RawMessage
+	if versao := atual.versao(); atual.Found && versao != nil {
+		antes = atual.Source
+		bulkItem.Action = "index"
+		bulkItem.IfSeqNo = &versao.SeqNo
+		bulkItem.IfPrimaryTerm = &versao.PrimaryTerm
+	}
+
+	bulkItem.OnSuccess = func(ctx context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+		b.ec.routes.put(b.ec.index, id, recebivel.IDPagamento)
+		b.onResult(item, BulkItemResult{Linha: item.Linha, ID: id, Resultado: res.Result})
+		b.audit(ctx, domain.NovoRegistroAuditoria(source.Ator, source.Origem, "index", b.ec.index, id, recebivel.IDPagamento, antes, body))
+	}
+	bulkItem.OnFailure = func(ctx context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+		erro := fmt.Sprintf("%s: %s", res.Error.Type, res.Error.Reason)
+		if err != nil {
+			erro = err.Error()
+		} else if res.Status == http.StatusConflict {
+			erro = fmt.Sprintf("recebível '%s' alterado por outra escrita durante a carga; reenvie a linha (%s)", id, res.Error.Reason)
+		}
+		b.onResult(item, BulkItemResult{Linha: item.Linha, ID: id, Resultado: BulkRejected, Erro: erro})
+	}
+	return b.indexer.Add(ctx, bulkItem)
+}
+
+// audit enfileira o registro de um item gravado
+func (b *esReceivableBulk) audit(ctx context.Context, registro domain.RegistroAuditoria) {
+	data, err := json.Marshal(registro)
+	if err == nil {
+		err = b.auditor.Add(ctx, esutil.BulkIndexerItem{
+			Action:     "create",
+			DocumentID: registro.IDRegistro,
+			Body:       bytes.NewReader(data),
+			OnFailure: func(ctx context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				log.Printf("❌ auditoria não gravada (%s: %s %v): %s", res.Error.Type, res.Error.Reason, err, data)
+			},
+		})
+	}
+	if err != nil {
+		log.Printf("❌ auditoria não gravada (%v): %s", err, data)
+	}
+}
+
+// Close enfileira os últimos pendentes e espera os flushes; os callbacks de todos os itens
+// já rodaram na volta
+func (b *esReceivableBulk) Close(ctx context.Context) error {
+	err := b.flush(ctx)
+	if closeErr := b.indexer.Close(ctx); err == nil {
+		err = closeErr
+	}
+	// O auditor só fecha depois do indexer, que enfileira nele até o último OnSuccess
+	if auditErr := b.auditor.Close(context.WithoutCancel(ctx)); auditErr != nil {
+		log.Printf("❌ bulk de auditoria: %v", auditErr)
+	}
+	return err
+}
+
+// memoryReceivableBulk grava cada item na hora, com o resultado síncrono
+type memoryReceivableBulk struct {
+	ms       *MemoryStore
+	onResult func(BulkItem, BulkItemResult)
+}
+
+// NewReceivableBulk abre um lote em memória
+func (ms *MemoryStore) NewReceivableBulk(ctx context.Context, onResult func(BulkItem, BulkItemResult)) (ReceivableBulk, error) {
+	return &memoryReceivableBulk{ms: ms, onResult: onResult}, nil
+}
+
+// Add grava o recebível, criando ou substituindo o documento como o index do bulk
+func (b *memoryReceivableBulk) Add(ctx context.Context, item BulkItem) error {
+	id := item.Recebivel.DocumentID()
+	resultado := BulkUpdated
+	antes, err := b.ms.put(item.Recebivel, func(_ string, exists bool) error {
+		if !exists {
+			resultado = BulkCreated
+		}
+		return nil
+	})
+	if err != nil {
+		b.onResult(item, BulkItemResult{Linha: item.Linha, ID: id, Resultado: BulkRejected, Erro: err.Error()})
+		return nil
+	}
+	audit(ctx, b.ms, "index", memoryAuditIndex, id, item.Recebivel.IDPagamento, antes, snapshotOf(item.Recebivel))
+	b.onResult(item, BulkItemResult{Linha: item.Linha, ID: id, Resultado: resultado})
+	return nil
+}
+
+func (b *memoryReceivableBulk) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// linhasBulk é uma carga com uma linha de cada resultado: criado, JSON inválido, recebível
+// inválido, linha vazia e um id repetido, que substitui o documento da primeira linha
+var linhasBulk = strings.Join([]string{
+	`{"id_recebivel":"rec-1","id_pagamento":"pag-1","codigo_cliente":"CLI-A","valor_original":10,"data_vencimento":"2025-01-10"}`,
+	`{"id_recebivel":"rec-2",`,
+	`{"id_recebivel":"rec-3","codigo_cliente":"CLI-A","valor_original":10,"data_vencimento":"2025-01-10"}`,
+	``,
+	`{"id_recebivel":"rec-1","id_pagamento":"pag-1","codigo_cliente":"CLI-A","valor_original":30,"data_vencimento":"2025-01-10"}`,
+}, "\n")
+
+// resultadosBulk resume as linhas do relatório como "linha id resultado"
+func resultadosBulk(report *BulkReport) []string {
+	resultados := []string{}
+	for _, linha := range report.Linhas {
+		resultados = append(resultados, strings.Join(strings.Fields(strconv.Itoa(linha.Linha)+" "+linha.ID+" "+linha.Resultado), " "))
+	}
+	return resultados
+}
+
+// TestBulkHandler confere o relatório da carga, o dead-letter das linhas rejeitadas e o id
+// repetido, que é gravado como created e depois updated
+func TestBulkHandler(t *testing.T) {
+	store := NewMemoryStore()
+	deadLetterPath := filepath.Join(t.TempDir(), "bulk-dead-letter.ndjson")
+	server := httptest.NewServer(bulkHandler(store, deadLetterPath))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/x-ndjson", strings.NewReader(linhasBulk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, esperado 200", resp.StatusCode)
+	}
+	var report BulkReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Total != 4 || report.Gravados != 2 || report.Rejeitados != 2 || report.DeadLetter != deadLetterPath {
+		t.Errorf("relatório = %d total, %d gravados, %d rejeitados, dead-letter %q", report.Total, report.Gravados, report.Rejeitados, report.DeadLetter)
+	}
+	want := []string{"1 rec-1 created", "2 rejected", "3 rec-3 rejected", "5 rec-1 updated"}
+	if got := resultadosBulk(&report); !reflect.DeepEqual(got, want) {
+		t.Errorf("linhas = %v, esperado %v", got, want)
+	}
+	if erro := report.Linhas[2].Erro; !strings.Contains(erro, "id_pagamento é obrigatório") {
+		t.Errorf("erro da linha 3 = %q", erro)
+	}
+
+	recebivel, err := store.GetReceivable(context.Background(), "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recebivel.ValorOriginal.String() != "30.00" {
+		t.Errorf("rec-1 com valor_original %s, esperado o da última linha (30.00)", recebivel.ValorOriginal)
+	}
+
+	// O dead-letter traz as linhas rejeitadas, com o número da linha e a linha original
+	raw, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registros []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(string(raw)))
+	for scanner.Scan() {
+		var registro map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &registro); err != nil {
+			t.Fatalf("registro do dead-letter inválido: %s", scanner.Text())
+		}
+		registros = append(registros, registro)
+	}
+	if len(registros) != 2 {
+		t.Fatalf("%d registros no dead-letter, esperado 2", len(registros))
+	}
+	linhas := strings.Split(linhasBulk, "\n")
+	for i, linha := range []float64{2, 3} {
+		if registros[i]["linha"] != linha || registros[i]["documento"] != linhas[int(linha)-1] || registros[i]["lote"] == "" || registros[i]["erro"] == "" {
+			t.Errorf("registro %d do dead-letter = %v", i, registros[i])
+		}
+	}
+}
+
+// falhaBulkStore é um MemoryStore cujo lote falha ao receber a linha falhaNaLinha
+type falhaBulkStore struct {
+	*MemoryStore
+	falhaNaLinha int
+}
+
+func (s *falhaBulkStore) NewReceivableBulk(ctx context.Context, onResult func(BulkItem, BulkItemResult)) (ReceivableBulk, error) {
+	bulk, err := s.MemoryStore.NewReceivableBulk(ctx, onResult)
+	if err != nil {
+		return nil, err
+	}
+	return &falhaBulk{ReceivableBulk: bulk, falhaNaLinha: s.falhaNaLinha}, nil
+}
+
+type falhaBulk struct {
+	ReceivableBulk
+	falhaNaLinha int
+}
+
+func (b *falhaBulk) Add(ctx context.Context, item BulkItem) error {
+	if item.Linha == b.falhaNaLinha {
+		return errors.New("cluster indisponível")
+	}
+	return b.ReceivableBulk.Add(ctx, item)
+}
+
+// TestBulkHandlerInterrompido confere que uma carga interrompida devolve o erro junto com o
+// relatório das linhas que já tiveram resultado
+func TestBulkHandlerInterrompido(t *testing.T) {
+	store := &falhaBulkStore{MemoryStore: NewMemoryStore(), falhaNaLinha: 5}
+	server := httptest.NewServer(bulkHandler(store, filepath.Join(t.TempDir(), "bulk-dead-letter.ndjson")))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/x-ndjson", strings.NewReader(linhasBulk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status %d, esperado 502", resp.StatusCode)
+	}
+	var body struct {
+		Error     string     `json:"error"`
+		Code      string     `json:"code"`
+		Relatorio BulkReport `json:"relatorio"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != ErrCodeBackendError || !strings.Contains(body.Error, "linha 5") {
+		t.Errorf("erro = %s %q", body.Code, body.Error)
+	}
+	want := []string{"1 rec-1 created", "2 rejected", "3 rec-3 rejected"}
+	if got := resultadosBulk(&body.Relatorio); !reflect.DeepEqual(got, want) || body.Relatorio.Gravados != 1 || body.Relatorio.Rejeitados != 2 {
+		t.Errorf("relatório parcial = %v (%d gravados, %d rejeitados), esperado %v", got, body.Relatorio.Gravados, body.Relatorio.Rejeitados, want)
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+// commands lista os subcomandos do binário; sem subcomando o servidor HTTP é iniciado.
+// Cada subcomando recebe os argumentos seguintes ao seu nome.
+var commands = map[string]func(args []string) error{
+	"reconcile":      runReconcile,
+	"backfill-saldo": runBackfillSaldo,
+	"export":         runExport,
+	"ingest-events":  runIngestEvents,
+}
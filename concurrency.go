@@ -0,0 +1,62 @@
+package main
+
+import (
+	"data-aggregator/domain"
+)
+
+// maxConflictRetries é quantas vezes uma mutação sem versão informada relê o documento e
+// reaplica a mudança quando outra escrita o alterou entre a leitura e a gravação
+const maxConflictRetries = 3
+
+// versionConflict é o erro de uma escrita condicionada a uma versão que não é mais a atual
+func versionConflict(id string, versao *domain.Versao) error {
+	return newAppError(ErrCodeConflict, "recebível '%s' foi alterado depois da versão seq_no=%d primary_term=%d: leia o documento de novo e reaplique a mudança",
+		id, versao.SeqNo, versao.PrimaryTerm)
+}
+
+// expectedVersion monta a versão esperada de if_seq_no e if_primary_term, que são
+// informados juntos ou omitidos juntos (escrita sem pré-condição)
+func expectedVersion(ifSeqNo, ifPrimaryTerm *int64) (*domain.Versao, error) {
+	if ifSeqNo == nil && ifPrimaryTerm == nil {
+		return nil, nil
+	}
+	if ifSeqNo == nil || ifPrimaryTerm == nil {
+		return nil, newAppError(ErrCodeInvalidArgument, "if_seq_no e if_primary_term devem ser informados juntos")
+	}
+	if *ifSeqNo < 0 || *ifPrimaryTerm < 1 {
+		return nil, newAppError(ErrCodeInvalidArgument, "if_seq_no deve ser >= 0 e if_primary_term >= 1")
+	}
+	return &domain.Versao{SeqNo: *ifSeqNo, PrimaryTerm: *ifPrimaryTerm}, nil
+}
+
+// versionArgs lê if_seq_no e if_primary_term dos argumentos de uma mutation
+func versionArgs(args map[string]interface{}) (*domain.Versao, error) {
+	var ifSeqNo, ifPrimaryTerm *int64
+	if v, ok := args["if_seq_no"].(int); ok {
+		n := int64(v)
+		ifSeqNo = &n
+	}
+	if v, ok := args["if_primary_term"].(int); ok {
+		n := int64(v)
+		ifPrimaryTerm = &n
+	}
+	return expectedVersion(ifSeqNo, ifPrimaryTerm)
+}
+
+// ifSeqNo e ifPrimaryTerm convertem a versão nos parâmetros if_seq_no / if_primary_term
+// das requisições do esapi; nil sem versão
+func ifSeqNo(versao *domain.Versao) *int {
+	if versao == nil {
+		return nil
+	}
+	n := int(versao.SeqNo)
+	return &n
+}
+
+func ifPrimaryTerm(versao *domain.Versao) *int {
+	if versao == nil {
+		return nil
+	}
+	n := int(versao.PrimaryTerm)
+	return &n
+}
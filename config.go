@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-aggregator/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Config reúne a configuração do servidor.
+// Precedência: valores padrão < arquivo (-config) < variáveis de ambiente < flags.
+type Config struct {
+	Elasticsearch ElasticsearchConfig `json:"elasticsearch" yaml:"elasticsearch"`
+	Server        ServerConfig        `json:"server" yaml:"server"`
+	Auth          AuthConfig          `json:"auth" yaml:"auth"`
+	DemoData      []string            `json:"demo_data" yaml:"demo_data"` // arquivos do modo demo, sem Elasticsearch
+}
+
+// ElasticsearchConfig configura a conexão com o cluster
+type ElasticsearchConfig struct {
+	Addresses []string `json:"addresses" yaml:"addresses"`
+	Username  string   `json:"username" yaml:"username"`
+	Password  string   `json:"password" yaml:"password"`
+	APIKey    string   `json:"api_key" yaml:"api_key"`
+	CACert    string   `json:"ca_cert" yaml:"ca_cert"` // caminho do certificado da CA em PEM
+	Index     string   `json:"index" yaml:"index"`     // índice ou alias dos recebíveis
+	// AuditIndex recebe um registro por escrita feita pela aplicação (ver domain.RegistroAuditoria)
+	AuditIndex string `json:"audit_index" yaml:"audit_index"`
+}
+
+// ServerConfig configura o servidor HTTP
+type ServerConfig struct {
+	ListenAddr     string   `json:"listen_addr" yaml:"listen_addr"`
+	ReadTimeout    Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout   Duration `json:"write_timeout" yaml:"write_timeout"`
+	QueryTimeout   Duration `json:"query_timeout" yaml:"query_timeout"` // limite de cada consulta ao store
+	GraphiQL       bool     `json:"graphiql" yaml:"graphiql"`
+	BulkDeadLetter string   `json:"bulk_dead_letter" yaml:"bulk_dead_letter"` // linhas rejeitadas por POST /receivables/_bulk
+}
+
+// AuthConfig configura a autenticação das rotas HTTP, exceto /health. Sem API keys e
+// sem jwt_key_file o servidor só sobe com Disabled, que deixa as rotas abertas.
+type AuthConfig struct {
+	Disabled     bool           `json:"disabled" yaml:"disabled"` // rotas abertas, só para desenvolvimento
+	APIKeys      []APIKeyConfig `json:"api_keys" yaml:"api_keys"`
+	JWTKeyFile   string         `json:"jwt_key_file" yaml:"jwt_key_file"`   // segredo HS256 ou chave pública RS256 em PEM
+	JWTAlgorithm string         `json:"jwt_algorithm" yaml:"jwt_algorithm"` // HS256 ou RS256
+	JWTIssuer    string         `json:"jwt_issuer" yaml:"jwt_issuer"`       // iss exigido, se informado
+	JWTAudience  string         `json:"jwt_audience" yaml:"jwt_audience"`   // aud exigido, se informado
+}
+
+// APIKeyConfig é uma API key estática; Name identifica o cliente na auditoria
+type APIKeyConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Key  string `json:"key" yaml:"key"`
+}
+
+// minAPIKeyLength é o tamanho mínimo de uma API key configurada
+const minAPIKeyLength = 16
+
+// Enabled indica se alguma forma de autenticação foi configurada
+func (a AuthConfig) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.JWTKeyFile != ""
+}
+
+// Duration aceita durações no formato "30s", "1m" em JSON e YAML
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duração deve ser texto como \"30s\": %w", err)
+	}
+	return d.set(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.set(value.Value)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("duração inválida '%s': %w", s, err)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// DefaultConfig retorna a configuração usada quando nada é informado
+func DefaultConfig() *Config {
+	return &Config{
+		Elasticsearch: ElasticsearchConfig{
+			Addresses:  []string{"http://localhost:9200"},
+			Index:      "ciclo_vida_recebivel",
+			AuditIndex: domain.IndiceAuditoria,
+		},
+		Server: ServerConfig{
+			ListenAddr:     ":8080",
+			ReadTimeout:    Duration{15 * time.Second},
+			WriteTimeout:   Duration{60 * time.Second},
+			QueryTimeout:   Duration{30 * time.Second},
+			GraphiQL:       true,
+			BulkDeadLetter: "bulk-dead-letter.ndjson",
+		},
+		Auth: AuthConfig{
+			JWTAlgorithm: "HS256",
+		},
+	}
+}
+
+// LoadConfig resolve a configuração a partir dos argumentos de linha de comando,
+// do ambiente e do arquivo opcional, e valida o resultado
+func LoadConfig(args []string) (*Config, error) {
+	return LoadConfigFlags(flag.NewFlagSet("data-aggregator", flag.ContinueOnError), args)
+}
+
+// LoadConfigFlags funciona como LoadConfig usando o FlagSet informado, onde os
+// subcomandos podem registrar flags próprias antes da leitura dos argumentos
+func LoadConfigFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	configFile := fs.String("config", os.Getenv("AGGREGATOR_CONFIG"), "Arquivo de configuração YAML ou JSON (env AGGREGATOR_CONFIG)")
+	esAddresses := fs.String("es-addresses", "", "Endereços do Elasticsearch separados por vírgula (env AGGREGATOR_ES_ADDRESSES)")
+	esUsername := fs.String("es-username", "", "Usuário do Elasticsearch (env AGGREGATOR_ES_USERNAME)")
+	esPassword := fs.String("es-password", "", "Senha do Elasticsearch (env AGGREGATOR_ES_PASSWORD)")
+	esAPIKey := fs.String("es-api-key", "", "API key do Elasticsearch (env AGGREGATOR_ES_API_KEY)")
+	esCACert := fs.String("es-ca-cert", "", "Certificado PEM da CA do Elasticsearch (env AGGREGATOR_ES_CA_CERT)")
+	esIndex := fs.String("es-index", "", "Índice ou alias dos recebíveis (env AGGREGATOR_ES_INDEX)")
+	esAuditIndex := fs.String("es-audit-index", "", "Índice de auditoria das escritas (env AGGREGATOR_ES_AUDIT_INDEX)")
+	listenAddr := fs.String("listen", "", "Endereço HTTP do servidor (env AGGREGATOR_LISTEN_ADDR)")
+	readTimeout := fs.Duration("read-timeout", 0, "Timeout de leitura das requisições HTTP (env AGGREGATOR_READ_TIMEOUT)")
+	writeTimeout := fs.Duration("write-timeout", 0, "Timeout de escrita das respostas HTTP (env AGGREGATOR_WRITE_TIMEOUT)")
+	queryTimeout := fs.Duration("query-timeout", 0, "Timeout de cada consulta ao Elasticsearch (env AGGREGATOR_QUERY_TIMEOUT)")
+	graphiql := fs.Bool("graphiql", false, "Habilita a interface GraphiQL (env AGGREGATOR_GRAPHIQL)")
+	bulkDeadLetter := fs.String("bulk-dead-letter", "", "Arquivo NDJSON das linhas rejeitadas por POST /receivables/_bulk (env AGGREGATOR_BULK_DEAD_LETTER)")
+	jwtKeyFile := fs.String("jwt-key-file", "", "Segredo HS256 ou chave pública RS256 (PEM) dos JWTs aceitos (env AGGREGATOR_JWT_KEY_FILE)")
+	jwtAlgorithm := fs.String("jwt-algorithm", "", "Algoritmo dos JWTs: HS256 ou RS256 (env AGGREGATOR_JWT_ALGORITHM)")
+	jwtIssuer := fs.String("jwt-issuer", "", "Emissor (iss) exigido nos JWTs (env AGGREGATOR_JWT_ISSUER)")
+	jwtAudience := fs.String("jwt-audience", "", "Audiência (aud) exigida nos JWTs (env AGGREGATOR_JWT_AUDIENCE)")
+	authDisabled := fs.Bool("auth-disabled", false, "Desativa a autenticação das rotas HTTP, só para desenvolvimento (env AGGREGATOR_AUTH_DISABLED)")
+	demoData := fs.String("demo-data", "", "Arquivos JSON/NDJSON separados por vírgula para rodar em memória, sem Elasticsearch (env AGGREGATOR_DEMO_DATA)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// Flags só sobrescrevem quando informadas explicitamente
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "es-addresses":
+			cfg.Elasticsearch.Addresses = splitList(*esAddresses)
+		case "es-username":
+			cfg.Elasticsearch.Username = *esUsername
+		case "es-password":
+			cfg.Elasticsearch.Password = *esPassword
+		case "es-api-key":
+			cfg.Elasticsearch.APIKey = *esAPIKey
+		case "es-ca-cert":
+			cfg.Elasticsearch.CACert = *esCACert
+		case "es-index":
+			cfg.Elasticsearch.Index = *esIndex
+		case "es-audit-index":
+			cfg.Elasticsearch.AuditIndex = *esAuditIndex
+		case "listen":
+			cfg.Server.ListenAddr = *listenAddr
+		case "read-timeout":
+			cfg.Server.ReadTimeout = Duration{*readTimeout}
+		case "write-timeout":
+			cfg.Server.WriteTimeout = Duration{*writeTimeout}
+		case "query-timeout":
+			cfg.Server.QueryTimeout = Duration{*queryTimeout}
+		case "graphiql":
+			cfg.Server.GraphiQL = *graphiql
+		case "bulk-dead-letter":
+			cfg.Server.BulkDeadLetter = *bulkDeadLetter
+		case "auth-disabled":
+			cfg.Auth.Disabled = *authDisabled
+		case "jwt-key-file":
+			cfg.Auth.JWTKeyFile = *jwtKeyFile
+		case "jwt-algorithm":
+			cfg.Auth.JWTAlgorithm = *jwtAlgorithm
+		case "jwt-issuer":
+			cfg.Auth.JWTIssuer = *jwtIssuer
+		case "jwt-audience":
+			cfg.Auth.JWTAudience = *jwtAudience
+		case "demo-data":
+			cfg.DemoData = splitList(*demoData)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile carrega o arquivo de configuração; a extensão define o formato
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler arquivo de configuração: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("formato de configuração não suportado '%s': use .yaml, .yml ou .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao decodificar arquivo de configuração '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv aplica as variáveis de ambiente AGGREGATOR_* definidas
+func (c *Config) applyEnv() error {
+	textos := map[string]*string{
+		"AGGREGATOR_ES_USERNAME":      &c.Elasticsearch.Username,
+		"AGGREGATOR_ES_PASSWORD":      &c.Elasticsearch.Password,
+		"AGGREGATOR_ES_API_KEY":       &c.Elasticsearch.APIKey,
+		"AGGREGATOR_ES_CA_CERT":       &c.Elasticsearch.CACert,
+		"AGGREGATOR_ES_INDEX":         &c.Elasticsearch.Index,
+		"AGGREGATOR_ES_AUDIT_INDEX":   &c.Elasticsearch.AuditIndex,
+		"AGGREGATOR_LISTEN_ADDR":      &c.Server.ListenAddr,
+		"AGGREGATOR_BULK_DEAD_LETTER": &c.Server.BulkDeadLetter,
+		"AGGREGATOR_JWT_KEY_FILE":     &c.Auth.JWTKeyFile,
+		"AGGREGATOR_JWT_ALGORITHM":    &c.Auth.JWTAlgorithm,
+		"AGGREGATOR_JWT_ISSUER":       &c.Auth.JWTIssuer,
+		"AGGREGATOR_JWT_AUDIENCE":     &c.Auth.JWTAudience,
+	}
+	for env, target := range textos {
+		if value, ok := os.LookupEnv(env); ok {
+			*target = value
+		}
+	}
+
+	lists := map[string]*[]string{
+		"AGGREGATOR_ES_ADDRESSES": &c.Elasticsearch.Addresses,
+		"AGGREGATOR_DEMO_DATA":    &c.DemoData,
+	}
+	for env, target := range lists {
+		if value, ok := os.LookupEnv(env); ok {
+			*target = splitList(value)
+		}
+	}
+
+	durations := map[string]*Duration{
+		"AGGREGATOR_READ_TIMEOUT":  &c.Server.ReadTimeout,
+		"AGGREGATOR_WRITE_TIMEOUT": &c.Server.WriteTimeout,
+		"AGGREGATOR_QUERY_TIMEOUT": &c.Server.QueryTimeout,
+	}
+	for env, target := range durations {
+		if value, ok := os.LookupEnv(env); ok {
+			if err := target.set(value); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+
+	// AGGREGATOR_API_KEYS substitui as API keys do arquivo: nome:chave separados por vírgula
+	if value, ok := os.LookupEnv("AGGREGATOR_API_KEYS"); ok {
+		c.Auth.APIKeys = nil
+		for i, item := range splitList(value) {
+			// O item pode ser a própria chave: a mensagem não repete o valor
+			name, key, found := strings.Cut(item, ":")
+			if !found {
+				return fmt.Errorf("AGGREGATOR_API_KEYS: o item %d não está no formato nome:chave", i+1)
+			}
+			c.Auth.APIKeys = append(c.Auth.APIKeys, APIKeyConfig{Name: name, Key: key})
+		}
+	}
+
+	if value, ok := os.LookupEnv("AGGREGATOR_AUTH_DISABLED"); ok {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("AGGREGATOR_AUTH_DISABLED: valor booleano inválido '%s'", value)
+		}
+		c.Auth.Disabled = disabled
+	}
+
+	if value, ok := os.LookupEnv("AGGREGATOR_GRAPHIQL"); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("AGGREGATOR_GRAPHIQL: valor booleano inválido '%s'", value)
+		}
+		c.Server.GraphiQL = enabled
+	}
+
+	return nil
+}
+
+// Validate verifica a consistência da configuração resolvida
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Server.ListenAddr == "" {
+		problems = append(problems, "server.listen_addr é obrigatório")
+	}
+	if c.Server.BulkDeadLetter == "" {
+		problems = append(problems, "server.bulk_dead_letter é obrigatório")
+	}
+	if c.Server.ReadTimeout.Duration < 0 || c.Server.WriteTimeout.Duration < 0 || c.Server.QueryTimeout.Duration < 0 {
+		problems = append(problems, "timeouts não podem ser negativos")
+	}
+
+	names := map[string]bool{}
+	keys := map[string]bool{}
+	for i, key := range c.Auth.APIKeys {
+		if key.Name == "" || names[key.Name] {
+			problems = append(problems, fmt.Sprintf("auth.api_keys[%d]: name é obrigatório e deve ser único", i))
+		}
+		if len(key.Key) < minAPIKeyLength || keys[key.Key] {
+			problems = append(problems, fmt.Sprintf("auth.api_keys[%d]: key deve ser única e ter pelo menos %d caracteres", i, minAPIKeyLength))
+		}
+		names[key.Name] = true
+		keys[key.Key] = true
+	}
+	if c.Auth.Disabled && c.Auth.Enabled() {
+		problems = append(problems, "auth.disabled não pode ser combinado com auth.api_keys ou auth.jwt_key_file")
+	}
+	if c.Auth.JWTKeyFile != "" {
+		if c.Auth.JWTAlgorithm != "HS256" && c.Auth.JWTAlgorithm != "RS256" {
+			problems = append(problems, fmt.Sprintf("auth.jwt_algorithm inválido '%s': use HS256 ou RS256", c.Auth.JWTAlgorithm))
+		}
+		if _, err := os.Stat(c.Auth.JWTKeyFile); err != nil {
+			problems = append(problems, fmt.Sprintf("auth.jwt_key_file: %v", err))
+		}
+	}
+
+	for _, path := range c.DemoData {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Sprintf("demo_data: %v", err))
+		}
+	}
+
+	// No modo demo o Elasticsearch não é usado
+	if len(c.DemoData) == 0 {
+		es := c.Elasticsearch
+		if len(es.Addresses) == 0 {
+			problems = append(problems, "elasticsearch.addresses é obrigatório")
+		}
+		for _, address := range es.Addresses {
+			u, err := url.Parse(address)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("elasticsearch.addresses: endereço inválido '%s'", address))
+			}
+		}
+		if es.Index == "" {
+			problems = append(problems, "elasticsearch.index é obrigatório")
+		}
+		if es.AuditIndex == "" || es.AuditIndex == es.Index {
+			problems = append(problems, "elasticsearch.audit_index é obrigatório e deve ser diferente de elasticsearch.index")
+		}
+		if (es.Username == "") != (es.Password == "") {
+			problems = append(problems, "elasticsearch.username e elasticsearch.password devem ser informados juntos")
+		}
+		if es.APIKey != "" && es.Username != "" {
+			problems = append(problems, "use elasticsearch.api_key ou username/password, não ambos")
+		}
+		if es.CACert != "" {
+			if _, err := os.Stat(es.CACert); err != nil {
+				problems = append(problems, fmt.Sprintf("elasticsearch.ca_cert: %v", err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("configuração inválida:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// splitList separa uma lista separada por vírgulas, ignorando itens vazios
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CustomerGroupsQuery pede uma página dos clientes dos recebíveis do filtro, em ordem
+// crescente de codigo_cliente, começando depois do cliente After (vazio para a primeira)
+type CustomerGroupsQuery struct {
+	Filter ReceivableFilter
+	After  string
+	Size   int
+}
+
+// CustomerGroup é um cliente com as somas de saldo dos seus recebíveis no filtro
+type CustomerGroup struct {
+	CodigoCliente string        `json:"codigo_cliente"`
+	Totais        BalanceTotals `json:"totais"`
+}
+
+// CustomerGroupEdge liga um cliente ao cursor da sua posição (o after_key da composite)
+type CustomerGroupEdge struct {
+	Cursor string         `json:"cursor"`
+	Node   *CustomerGroup `json:"node"`
+}
+
+// CustomerGroupPage é uma página de clientes no formato de connection do Relay
+type CustomerGroupPage struct {
+	Edges    []CustomerGroupEdge `json:"edges"`
+	PageInfo PageInfo            `json:"pageInfo"`
+}
+
+// BalanceByCustomer pagina os clientes dos recebíveis do filtro com as somas de saldo de
+// cada um. O cursor guarda o codigo_cliente do último cliente da página, que é o after_key
+// da composite aggregation, então nenhuma página depende de um limite de buckets.
+func (be *BalanceEngine) BalanceByCustomer(ctx context.Context, filter ReceivableFilter, page PageRequest) (*CustomerGroupPage, error) {
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, err
+	}
+	query := CustomerGroupsQuery{Filter: filter, Size: page.First + 1}
+	if len(cursor.After) > 0 {
+		query.After = fmt.Sprint(cursor.After[0])
+	}
+
+	groups, err := be.store.CustomerGroups(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CustomerGroupPage{Edges: []CustomerGroupEdge{}}
+	result.PageInfo.HasPreviousPage = page.After != ""
+	if len(groups) > page.First {
+		groups = groups[:page.First]
+		result.PageInfo.HasNextPage = true
+	}
+	for i := range groups {
+		result.Edges = append(result.Edges, CustomerGroupEdge{
+			Cursor: encodeCursor(pageCursor{After: []interface{}{groups[i].CodigoCliente}}),
+			Node:   &groups[i],
+		})
+	}
+	if len(result.Edges) > 0 {
+		result.PageInfo.StartCursor = result.Edges[0].Cursor
+		result.PageInfo.EndCursor = result.Edges[len(result.Edges)-1].Cursor
+	}
+	return result, nil
+}
+
+// AllCustomerGroups percorre todas as páginas de CustomerGroups do filtro, em ordem de
+// codigo_cliente
+func (be *BalanceEngine) AllCustomerGroups(ctx context.Context, filter ReceivableFilter) ([]CustomerGroup, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	var all []CustomerGroup
+	query := CustomerGroupsQuery{Filter: filter, Size: compositePageSize}
+	for {
+		groups, err := be.store.CustomerGroups(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, groups...)
+		if len(groups) < query.Size {
+			return all, nil
+		}
+		query.After = groups[len(groups)-1].CodigoCliente
+	}
+}
+
+// CountByCustomer conta os recebíveis de todos os clientes no período de vencimento, do
+// maior para o menor (chave crescente no empate), sem o corte de uma terms aggregation
+func (be *BalanceEngine) CountByCustomer(ctx context.Context, dataInicio, dataFim string) ([]CustomerStats, error) {
+	groups, err := be.AllCustomerGroups(ctx, customerFilter("", dataInicio, dataFim))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]CustomerStats, len(groups))
+	for i, group := range groups {
+		stats[i] = CustomerStats{CodigoCliente: group.CodigoCliente, TotalRecebiveis: group.Totais.TotalRecebiveis}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].TotalRecebiveis > stats[j].TotalRecebiveis
+	})
+	return stats, nil
+}
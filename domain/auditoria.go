@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IndiceAuditoria é o nome padrão do índice de auditoria das escritas
+const IndiceAuditoria = "auditoria_escritas"
+
+// Origens das escritas registradas na auditoria
+const (
+	OrigemQuery   = "query"         // endpoint genérico /query
+	OrigemGraphQL = "graphql"       // mutations
+	OrigemBulk    = "bulk"          // POST /receivables/_bulk
+	OrigemEventos = "ingest-events" // subcomando ingest-events
+	OrigemSeeder  = "seeder"        // scripts/seed_receivables.go
+)
+
+// RegistroAuditoria é uma escrita registrada no índice de auditoria, que só recebe
+// documentos novos: quem escreveu, quando, por onde, a operação, o documento alvo e o
+// documento antes e depois da escrita.
+type RegistroAuditoria struct {
+	// IDRegistro é um UUIDv7: a ordem dos ids é a ordem das escritas
+	IDRegistro  string          `json:"id_registro" es:"keyword"`
+	Timestamp   string          `json:"timestamp" es:"date"`
+	Ator        string          `json:"ator" es:"keyword"`
+	Origem      string          `json:"origem" es:"keyword"`
+	Operacao    string          `json:"operacao" es:"keyword"` // create_index, create, index, update ou delete
+	Indice      string          `json:"indice" es:"keyword"`
+	DocumentoID string          `json:"documento_id,omitempty" es:"keyword"`
+	Routing     string          `json:"routing,omitempty" es:"keyword"`
+	Antes       json.RawMessage `json:"antes,omitempty" es:"snapshot"`
+	Depois      json.RawMessage `json:"depois,omitempty" es:"snapshot"`
+}
+
+// NovoRegistroAuditoria cria o registro de uma escrita feita agora
+func NovoRegistroAuditoria(ator, origem, operacao, indice, documentoID, routing string, antes, depois json.RawMessage) RegistroAuditoria {
+	return RegistroAuditoria{
+		IDRegistro:  uuid.Must(uuid.NewV7()).String(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Ator:        ator,
+		Origem:      origem,
+		Operacao:    operacao,
+		Indice:      indice,
+		DocumentoID: documentoID,
+		Routing:     routing,
+		Antes:       antes,
+		Depois:      depois,
+	}
+}
+
+// AuditMapping gera o corpo de criação do índice de auditoria. O mapping é strict e os
+// snapshots não são indexados, para documentos de qualquer índice caberem nele.
+func AuditMapping() map[string]interface{} {
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"dynamic":    "strict",
+			"properties": Properties(reflect.TypeOf(RegistroAuditoria{}), MoneyScaledFloat),
+		},
+	}
+}
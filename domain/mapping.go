@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+)
+
+// MoneyMapping define como os campos Money (tag es:"money") são mapeados no índice
+type MoneyMapping int
+
+const (
+	// MoneyScaledFloat usa scaled_float com fator 100: o Elasticsearch guarda centavos
+	// inteiros (long) e as somas não acumulam erro de ponto flutuante. É o padrão.
+	MoneyScaledFloat MoneyMapping = iota
+	// MoneyFloat mantém o mapping legado em float, sujeito a erro de arredondamento
+	MoneyFloat
+)
+
+// IndexMapping gera o corpo de criação do índice de recebíveis com o mapping monetário padrão
+func IndexMapping() map[string]interface{} {
+	return IndexMappingWith(MoneyScaledFloat)
+}
+
+// IndexMappingWith gera o corpo de criação do índice de recebíveis a partir das tags es do modelo
+func IndexMappingWith(money MoneyMapping) map[string]interface{} {
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": Properties(reflect.TypeOf(Recebivel{}), money),
+		},
+	}
+}
+
+// Properties gera as propriedades do mapping para um struct do modelo.
+// Campos sem tag es (como o ID do documento) ficam fora do mapping; campos es:"snapshot"
+// guardam um documento qualquer no _source, sem indexá-lo.
+func Properties(t reflect.Type, money MoneyMapping) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, field := range Fields(t) {
+		property := map[string]interface{}{
+			"type": field.ESType,
+		}
+		switch field.ESType {
+		case "nested":
+			property["properties"] = Properties(field.Type.Elem(), money)
+		case "money":
+			property = money.property()
+		case "snapshot":
+			property = map[string]interface{}{"type": "object", "enabled": false}
+		}
+		properties[field.JSONName] = property
+	}
+	return properties
+}
+
+// property retorna a definição de mapping de um campo monetário
+func (m MoneyMapping) property() map[string]interface{} {
+	if m == MoneyFloat {
+		return map[string]interface{}{"type": "float"}
+	}
+	return map[string]interface{}{
+		"type":           "scaled_float",
+		"scaling_factor": 100,
+	}
+}
+
+// Field descreve um campo do modelo persistido no índice
+type Field struct {
+	Name     string // nome do campo no struct Go
+	JSONName string // nome do campo no documento
+	ESType   string // tipo no mapping do Elasticsearch ("money" para campos Money)
+	Type     reflect.Type
+	Index    int
+	Computed bool // calculado pela aplicação a cada escrita (opção computed da tag es)
+}
+
+// Fields lista os campos persistidos de um struct do modelo, na ordem de declaração
+func Fields(t reflect.Type) []Field {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		esOptions := strings.Split(f.Tag.Get("es"), ",")
+		esType := esOptions[0]
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if esType == "" || jsonName == "" || jsonName == "-" {
+			continue
+		}
+		fields = append(fields, Field{
+			Name:     f.Name,
+			JSONName: jsonName,
+			ESType:   esType,
+			Type:     f.Type,
+			Index:    i,
+			Computed: containsOption(esOptions[1:], "computed"),
+		})
+	}
+	return fields
+}
+
+// containsOption informa se a opção aparece entre as opções de uma tag
+func containsOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
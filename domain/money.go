@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money representa um valor monetário em centavos inteiros.
+// Toda a aritmética de saldo é feita em Money para fechar exatamente no centavo;
+// no JSON o valor continua sendo um número decimal com duas casas (ex.: 1234.56).
+type Money int64
+
+// Centavos cria um Money a partir de centavos
+func Centavos(centavos int64) Money {
+	return Money(centavos)
+}
+
+// Reais cria um Money a partir de reais inteiros
+func Reais(reais int64) Money {
+	return Money(reais * 100)
+}
+
+// MoneyFromFloat converte um valor em reais para Money, arredondando para o centavo
+// mais próximo (meio centavo se afasta do zero). Use apenas na fronteira com fontes
+// que só entregam float, como agregações do Elasticsearch.
+func MoneyFromFloat(valor float64) Money {
+	return Money(math.Round(valor * 100))
+}
+
+// ParseMoney interpreta um decimal em reais ("1234.56", "-0.5", "10") sem passar por float.
+// Casas além do centavo são arredondadas (meio centavo se afasta do zero).
+func ParseMoney(s string) (Money, error) {
+	texto := strings.TrimSpace(s)
+	if texto == "" {
+		return 0, fmt.Errorf("valor monetário vazio")
+	}
+
+	// Notação científica não é usada pelos documentos; cai para float e arredonda
+	if strings.ContainsAny(texto, "eE") {
+		valor, err := strconv.ParseFloat(texto, 64)
+		if err != nil {
+			return 0, fmt.Errorf("valor monetário inválido '%s'", s)
+		}
+		return MoneyFromFloat(valor), nil
+	}
+
+	negativo := false
+	switch texto[0] {
+	case '-':
+		negativo = true
+		texto = texto[1:]
+	case '+':
+		texto = texto[1:]
+	}
+
+	inteiro, fracao, _ := strings.Cut(texto, ".")
+	if inteiro == "" && fracao == "" {
+		return 0, fmt.Errorf("valor monetário inválido '%s'", s)
+	}
+	if inteiro == "" {
+		inteiro = "0"
+	}
+	for _, parte := range []string{inteiro, fracao} {
+		for _, c := range parte {
+			if c < '0' || c > '9' {
+				return 0, fmt.Errorf("valor monetário inválido '%s'", s)
+			}
+		}
+	}
+
+	reais, err := strconv.ParseInt(inteiro, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("valor monetário fora do limite '%s'", s)
+	}
+
+	fracao += "000"
+	centavos, _ := strconv.ParseInt(fracao[:2], 10, 64)
+	if fracao[2] >= '5' {
+		centavos++
+	}
+	// reais*100 + centavos precisa caber em int64
+	if reais > (math.MaxInt64-centavos)/100 {
+		return 0, fmt.Errorf("valor monetário fora do limite '%s'", s)
+	}
+
+	total := reais*100 + centavos
+	if negativo {
+		total = -total
+	}
+	return Money(total), nil
+}
+
+// Float64 retorna o valor em reais; use apenas para exibição ou para montar queries
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String retorna o valor decimal com duas casas, no formato do JSON ("-1234.56")
+func (m Money) String() string {
+	sinal := ""
+	centavos := int64(m)
+	if centavos < 0 {
+		sinal = "-"
+		centavos = -centavos
+	}
+	return fmt.Sprintf("%s%d.%02d", sinal, centavos/100, centavos%100)
+}
+
+// Format formata o valor como moeda brasileira: "R$ 1.234,56" ou "-R$ 1.234,56"
+func (m Money) Format() string {
+	sinal := ""
+	centavos := int64(m)
+	if centavos < 0 {
+		sinal = "-"
+		centavos = -centavos
+	}
+
+	strInteira := strconv.FormatInt(centavos/100, 10)
+	var resultado strings.Builder
+	for i, digit := range strInteira {
+		if i > 0 && (len(strInteira)-i)%3 == 0 {
+			resultado.WriteString(".")
+		}
+		resultado.WriteRune(digit)
+	}
+
+	return fmt.Sprintf("%sR$ %s,%02d", sinal, resultado.String(), centavos%100)
+}
+
+// MarshalJSON grava o valor como número decimal com duas casas
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON lê números (ou textos numéricos) em reais sem perder centavos
+func (m *Money) UnmarshalJSON(data []byte) error {
+	texto := strings.Trim(string(data), `"`)
+	if texto == "null" {
+		return nil
+	}
+	valor, err := ParseMoney(texto)
+	if err != nil {
+		return err
+	}
+	*m = valor
+	return nil
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		entrada string
+		want    Money
+		erro    bool
+	}{
+		{entrada: "1234.56", want: 123456},
+		{entrada: "10", want: 1000},
+		{entrada: "-0.5", want: -50},
+		{entrada: ".5", want: 50},
+		{entrada: "0.005", want: 1},
+		{entrada: "0.004", want: 0},
+		{entrada: "-0.005", want: -1},
+		{entrada: "1234567.891", want: 123456789},
+		{entrada: "0.1", want: 10},
+		{entrada: "1e2", want: 10000},
+		{entrada: "92233720368547758.07", want: math.MaxInt64},
+		{entrada: "-92233720368547758.07", want: -math.MaxInt64},
+		{entrada: "92233720368547758.08", erro: true},
+		{entrada: "92233720368547758.075", erro: true},
+		{entrada: "99999999999999999999", erro: true},
+		{entrada: "", erro: true},
+		{entrada: "-", erro: true},
+		{entrada: "12,34", erro: true},
+		{entrada: "R$ 1.234,56", erro: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entrada, func(t *testing.T) {
+			got, err := ParseMoney(tt.entrada)
+			if tt.erro {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %d, esperado erro", tt.entrada, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q): %v", tt.entrada, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, esperado %d", tt.entrada, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		valor  Money
+		string string
+		format string
+	}{
+		{valor: 0, string: "0.00", format: "R$ 0,00"},
+		{valor: 5, string: "0.05", format: "R$ 0,05"},
+		{valor: -50, string: "-0.50", format: "-R$ 0,50"},
+		{valor: 123456, string: "1234.56", format: "R$ 1.234,56"},
+		{valor: -123456, string: "-1234.56", format: "-R$ 1.234,56"},
+		{valor: 123456789, string: "1234567.89", format: "R$ 1.234.567,89"},
+		{valor: math.MaxInt64, string: "92233720368547758.07", format: "R$ 92.233.720.368.547.758,07"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.string, func(t *testing.T) {
+			if got := tt.valor.String(); got != tt.string {
+				t.Errorf("String() = %q, esperado %q", got, tt.string)
+			}
+			if got := tt.valor.Format(); got != tt.format {
+				t.Errorf("Format() = %q, esperado %q", got, tt.format)
+			}
+		})
+	}
+}
+
+// TestMoneyJSON confere que o valor volta igual do JSON, como número ou como texto
+func TestMoneyJSON(t *testing.T) {
+	var doc struct {
+		Valor Money `json:"valor"`
+		Texto Money `json:"texto"`
+	}
+	if err := json.Unmarshal([]byte(`{"valor": 1234567.891, "texto": "-0.5"}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Valor != 123456789 || doc.Texto != -50 {
+		t.Errorf("valor = %d, texto = %d; esperado 123456789 e -50", doc.Valor, doc.Texto)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `{"valor":1234567.89,"texto":-0.50}` {
+		t.Errorf("json = %s", raw)
+	}
+}
@@ -0,0 +1,216 @@
+// Package domain define o modelo de recebíveis compartilhado pelo servidor,
+// pelo seeder e pelas demais ferramentas do projeto.
+//
+// As tags json definem o formato do documento no índice e as tags es definem o
+// tipo do campo no mapping do Elasticsearch (ver IndexMapping).
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LayoutData é o formato das datas armazenadas nos documentos
+const LayoutData = "2006-01-02"
+
+// Recebivel representa um recebível e seu ciclo de vida
+type Recebivel struct {
+	// ID é o _id do documento no índice; não faz parte do _source
+	ID string `json:"-"`
+	// Versao é a versão do documento lida do índice, usada como pré-condição da próxima
+	// escrita (controle de concorrência otimista); também não faz parte do _source
+	Versao *Versao `json:"-"`
+
+	IDRecebivel           string         `json:"id_recebivel" es:"keyword"`
+	IDPagamento           string         `json:"id_pagamento" es:"keyword"`
+	CodigoCliente         string         `json:"codigo_cliente" es:"keyword"`
+	CodigoProduto         int            `json:"codigo_produto" es:"integer"`
+	CodigoProdutoParceiro int            `json:"codigo_produto_parceiro" es:"integer"`
+	Modalidade            int            `json:"modalidade" es:"integer"`
+	ValorOriginal         Money          `json:"valor_original" es:"money"`
+	DataVencimento        string         `json:"data_vencimento" es:"date"`
+	Cancelamentos         []Cancelamento `json:"cancelamentos,omitempty" es:"nested"`
+	Negociacoes           []Negociacao   `json:"negociacoes,omitempty" es:"nested"`
+
+	// SaldoDisponivel é o Saldo() gravado no documento para filtros e ordenação no índice.
+	// É calculado a cada escrita (UpdateSaldoDisponivel), nunca recebido do cliente.
+	SaldoDisponivel Money `json:"saldo_disponivel" es:"money,computed"`
+}
+
+// Cancelamento representa um cancelamento
+type Cancelamento struct {
+	IDCancelamento   string `json:"id_cancelamento" es:"keyword"`
+	DataCancelamento string `json:"data_cancelamento" es:"date"`
+	ValorCancelado   Money  `json:"valor_cancelado" es:"money"`
+	Motivo           string `json:"motivo" es:"text"`
+}
+
+// Negociacao representa uma negociação
+type Negociacao struct {
+	IDNegociacao   string `json:"id_negociacao" es:"keyword"`
+	DataNegociacao string `json:"data_negociacao" es:"date"`
+	ValorNegociado Money  `json:"valor_negociado" es:"money"`
+}
+
+// Versao identifica a última escrita de um documento: o _seq_no e o _primary_term do
+// Elasticsearch. Uma escrita condicionada a uma Versao falha se o documento mudou depois dela.
+type Versao struct {
+	SeqNo       int64 `json:"seq_no"`
+	PrimaryTerm int64 `json:"primary_term"`
+}
+
+// DocumentID retorna o _id do documento, que por padrão é o id_recebivel
+func (r *Recebivel) DocumentID() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.IDRecebivel
+}
+
+// TotalCancelado soma os valores de todos os cancelamentos
+func (r *Recebivel) TotalCancelado() Money {
+	var total Money
+	for _, c := range r.Cancelamentos {
+		total += c.ValorCancelado
+	}
+	return total
+}
+
+// TotalNegociado soma os valores de todas as negociações
+func (r *Recebivel) TotalNegociado() Money {
+	var total Money
+	for _, n := range r.Negociacoes {
+		total += n.ValorNegociado
+	}
+	return total
+}
+
+// Saldo retorna o saldo disponível: valor original - cancelamentos - negociações
+func (r *Recebivel) Saldo() Money {
+	return r.ValorOriginal - r.TotalCancelado() - r.TotalNegociado()
+}
+
+// UpdateSaldoDisponivel grava o saldo atual em SaldoDisponivel; deve ser chamado antes de persistir
+func (r *Recebivel) UpdateSaldoDisponivel() {
+	r.SaldoDisponivel = r.Saldo()
+}
+
+// AddCancelamento acrescenta um cancelamento ao recebível
+func (r *Recebivel) AddCancelamento(c Cancelamento) {
+	r.Cancelamentos = append(r.Cancelamentos, c)
+}
+
+// AddNegociacao acrescenta uma negociação ao recebível
+func (r *Recebivel) AddNegociacao(n Negociacao) {
+	r.Negociacoes = append(r.Negociacoes, n)
+}
+
+// RemoveCancelamento remove o cancelamento com o id informado; retorna false se não existir
+func (r *Recebivel) RemoveCancelamento(idCancelamento string) bool {
+	for i, c := range r.Cancelamentos {
+		if c.IDCancelamento == idCancelamento {
+			r.Cancelamentos = append(r.Cancelamentos[:i:i], r.Cancelamentos[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AsOf retorna uma cópia do recebível só com os cancelamentos e negociações datados até a
+// data informada (yyyy-MM-dd, inclusive): o recebível como estava ao fim daquele dia. Com
+// data vazia a cópia mantém todos os eventos.
+func (r *Recebivel) AsOf(data string) *Recebivel {
+	copia := *r
+	copia.Cancelamentos = nil
+	for _, c := range r.Cancelamentos {
+		if data == "" || c.DataCancelamento <= data {
+			copia.Cancelamentos = append(copia.Cancelamentos, c)
+		}
+	}
+	copia.Negociacoes = nil
+	for _, n := range r.Negociacoes {
+		if data == "" || n.DataNegociacao <= data {
+			copia.Negociacoes = append(copia.Negociacoes, n)
+		}
+	}
+	copia.UpdateSaldoDisponivel()
+	return &copia
+}
+
+// ValidationError lista os problemas encontrados na validação de um recebível
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "recebível inválido: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate verifica campos obrigatórios, datas, valores e se o saldo não fica negativo
+func (r *Recebivel) Validate() error {
+	var problems []string
+
+	if r.IDRecebivel == "" {
+		problems = append(problems, "id_recebivel é obrigatório")
+	}
+	if r.IDPagamento == "" {
+		problems = append(problems, "id_pagamento é obrigatório")
+	}
+	if r.CodigoCliente == "" {
+		problems = append(problems, "codigo_cliente é obrigatório")
+	}
+	if r.ValorOriginal <= 0 {
+		problems = append(problems, "valor_original deve ser positivo")
+	}
+	if !dataValida(r.DataVencimento) {
+		problems = append(problems, fmt.Sprintf("data_vencimento inválida '%s'", r.DataVencimento))
+	}
+
+	idsCancelamento := map[string]bool{}
+	for i, c := range r.Cancelamentos {
+		if c.IDCancelamento == "" {
+			problems = append(problems, fmt.Sprintf("cancelamentos[%d].id_cancelamento é obrigatório", i))
+		} else if idsCancelamento[c.IDCancelamento] {
+			problems = append(problems, fmt.Sprintf("cancelamentos[%d].id_cancelamento '%s' duplicado", i, c.IDCancelamento))
+		}
+		idsCancelamento[c.IDCancelamento] = true
+		if !dataValida(c.DataCancelamento) {
+			problems = append(problems, fmt.Sprintf("cancelamentos[%d].data_cancelamento inválida '%s'", i, c.DataCancelamento))
+		}
+		if c.ValorCancelado <= 0 {
+			problems = append(problems, fmt.Sprintf("cancelamentos[%d].valor_cancelado deve ser positivo", i))
+		}
+	}
+
+	idsNegociacao := map[string]bool{}
+	for i, n := range r.Negociacoes {
+		if n.IDNegociacao == "" {
+			problems = append(problems, fmt.Sprintf("negociacoes[%d].id_negociacao é obrigatório", i))
+		} else if idsNegociacao[n.IDNegociacao] {
+			problems = append(problems, fmt.Sprintf("negociacoes[%d].id_negociacao '%s' duplicado", i, n.IDNegociacao))
+		}
+		idsNegociacao[n.IDNegociacao] = true
+		if !dataValida(n.DataNegociacao) {
+			problems = append(problems, fmt.Sprintf("negociacoes[%d].data_negociacao inválida '%s'", i, n.DataNegociacao))
+		}
+		if n.ValorNegociado <= 0 {
+			problems = append(problems, fmt.Sprintf("negociacoes[%d].valor_negociado deve ser positivo", i))
+		}
+	}
+
+	if r.ValorOriginal > 0 && r.Saldo() < 0 {
+		problems = append(problems, fmt.Sprintf("cancelamentos e negociações excedem o valor original (saldo %s)", r.Saldo()))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// dataValida verifica se a data está no formato yyyy-MM-dd
+func dataValida(data string) bool {
+	_, err := time.Parse(LayoutData, data)
+	return err == nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Códigos de erro estáveis, expostos em extensions.code no GraphQL e em "code" no REST
+const (
+	ErrCodeInvalidArgument    = "INVALID_ARGUMENT"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeAlreadyExists      = "ALREADY_EXISTS"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodeUnauthenticated    = "UNAUTHENTICATED"
+	ErrCodeBadQuery           = "BACKEND_BAD_QUERY"
+	ErrCodeBackendError       = "BACKEND_ERROR"
+	ErrCodeBackendUnavailable = "BACKEND_UNAVAILABLE"
+	ErrCodeMalformedResponse  = "MALFORMED_RESPONSE"
+	ErrCodeInternal           = "INTERNAL"
+	ErrCodeUnimplemented      = "UNIMPLEMENTED"
+)
+
+// AppError é um erro com código estável que pode ser reportado ao cliente
+type AppError struct {
+	Code    string
+	Message string
+	Status  int // status HTTP devolvido pelo Elasticsearch, quando houver
+	Err     error
+}
+
+// newAppError cria um AppError com mensagem formatada
+func newAppError(code string, format string, args ...interface{}) *AppError {
+	return &AppError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// wrapAppError cria um AppError que encapsula a causa original
+func wrapAppError(code string, err error, format string, args ...interface{}) *AppError {
+	return &AppError{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Extensions implementa gqlerrors.ExtendedError para o código aparecer no array errors do GraphQL
+func (e *AppError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{
+		"code": e.Code,
+	}
+	if e.Status != 0 {
+		ext["status"] = e.Status
+	}
+	return ext
+}
+
+// errorCode retorna o código estável de um erro, ou INTERNAL se não for um AppError
+func errorCode(err error) string {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ErrCodeInternal
+}
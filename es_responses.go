@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"data-aggregator/domain"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SearchResponse representa a resposta do endpoint _search
+type SearchResponse struct {
+	Took         int                        `json:"took"`
+	TimedOut     bool                       `json:"timed_out"`
+	PitID        string                     `json:"pit_id,omitempty"` // presente em buscas com point-in-time
+	Hits         SearchHits                 `json:"hits"`
+	Aggregations map[string]json.RawMessage `json:"aggregations,omitempty"`
+}
+
+// SearchHits representa o bloco hits de uma busca
+type SearchHits struct {
+	Total *TotalHits  `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// TotalHits representa o total de documentos encontrados
+type TotalHits struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// SearchHit representa um documento retornado por uma busca
+type SearchHit struct {
+	Index   string                   `json:"_index"`
+	ID      string                   `json:"_id"`
+	Routing string                   `json:"_routing,omitempty"`
+	Score   *float64                 `json:"_score"`
+	Source  json.RawMessage          `json:"_source,omitempty"`
+	Fields  map[string][]interface{} `json:"fields,omitempty"`
+	Sort    []interface{}            `json:"sort,omitempty"`
+}
+
+// PointInTimeResponse representa a resposta da abertura de um point-in-time
+type PointInTimeResponse struct {
+	ID string `json:"id"`
+}
+
+// CountResponse representa a resposta do endpoint _count
+type CountResponse struct {
+	Count int `json:"count"`
+}
+
+// GetResponse representa a resposta do endpoint _doc
+type GetResponse struct {
+	Index   string `json:"_index"`
+	ID      string `json:"_id"`
+	Routing string `json:"_routing,omitempty"`
+	Found   bool   `json:"found"`
+	// SeqNo e PrimaryTerm são a versão do documento, para if_seq_no/if_primary_term
+	SeqNo       *int64          `json:"_seq_no,omitempty"`
+	PrimaryTerm *int64          `json:"_primary_term,omitempty"`
+	Source      json.RawMessage `json:"_source,omitempty"`
+}
+
+// versao retorna a versão do documento lido; nil se a resposta não a trouxe
+func (r *GetResponse) versao() *domain.Versao {
+	if r.SeqNo == nil || r.PrimaryTerm == nil {
+		return nil
+	}
+	return &domain.Versao{SeqNo: *r.SeqNo, PrimaryTerm: *r.PrimaryTerm}
+}
+
+// MgetResponse representa a resposta do endpoint _mget, com um doc por id pedido, na ordem
+type MgetResponse struct {
+	Docs []GetResponse `json:"docs"`
+}
+
+// ResolveIndexResponse representa a resposta do endpoint _resolve/index: os índices,
+// aliases e data streams que um nome, padrão ou lista alcança
+type ResolveIndexResponse struct {
+	Indices []struct {
+		Name string `json:"name"`
+	} `json:"indices"`
+	Aliases []struct {
+		Name    string   `json:"name"`
+		Indices []string `json:"indices"`
+	} `json:"aliases"`
+	DataStreams []struct {
+		Name           string   `json:"name"`
+		BackingIndices []string `json:"backing_indices"`
+	} `json:"data_streams"`
+}
+
+// UpdateResponse representa a resposta dos endpoints _update e _doc (index); result é
+// "created", "updated" ou "noop"
+type UpdateResponse struct {
+	ID          string `json:"_id"`
+	Result      string `json:"result"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+	// Get traz o documento resultante quando o update pede "_source": true
+	Get *struct {
+		Source json.RawMessage `json:"_source"`
+	} `json:"get,omitempty"`
+}
+
+// source retorna o documento resultante do update, se ele veio na resposta
+func (r *UpdateResponse) source() json.RawMessage {
+	if r.Get == nil {
+		return nil
+	}
+	return r.Get.Source
+}
+
+// versao retorna a versão do documento depois da escrita
+func (r *UpdateResponse) versao() *domain.Versao {
+	return &domain.Versao{SeqNo: r.SeqNo, PrimaryTerm: r.PrimaryTerm}
+}
+
+// UpdateByQueryResponse representa a resposta do endpoint _update_by_query
+type UpdateByQueryResponse struct {
+	Total    int               `json:"total"`
+	Updated  int               `json:"updated"`
+	Failures []json.RawMessage `json:"failures"`
+}
+
+// ErrorResponse representa o corpo de erro devolvido pelo Elasticsearch
+type ErrorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+	Status int `json:"status"`
+}
+
+// ValueAggregation representa agregações de métrica simples (sum, avg, scripted_metric...)
+type ValueAggregation struct {
+	Value *float64 `json:"value"`
+}
+
+// TermsBucket representa um bucket de uma terms aggregation
+type TermsBucket struct {
+	Key      interface{} `json:"key"`
+	DocCount int         `json:"doc_count"`
+}
+
+// TermsAggregation representa o resultado de uma terms aggregation
+type TermsAggregation struct {
+	Buckets []TermsBucket `json:"buckets"`
+}
+
+// total retorna o total de hits ou erro se a resposta não trouxer o campo
+func (sr *SearchResponse) total() (int, error) {
+	if sr.Hits.Total == nil {
+		return 0, newAppError(ErrCodeMalformedResponse, "resposta de busca sem hits.total")
+	}
+	return sr.Hits.Total.Value, nil
+}
+
+// aggregation decodifica a agregação informada no destino tipado
+func (sr *SearchResponse) aggregation(name string, target interface{}) error {
+	raw, ok := sr.Aggregations[name]
+	if !ok {
+		return newAppError(ErrCodeMalformedResponse, "agregação '%s' ausente na resposta", name)
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return wrapAppError(ErrCodeMalformedResponse, err, "agregação '%s' em formato inesperado", name)
+	}
+	return nil
+}
+
+// value retorna o valor da métrica ou erro se vier nulo
+func (va *ValueAggregation) value(name string) (float64, error) {
+	if va == nil || va.Value == nil {
+		return 0, newAppError(ErrCodeMalformedResponse, "agregação '%s' sem valor", name)
+	}
+	return *va.Value, nil
+}
+
+// keyString retorna a chave do bucket como texto
+func (tb TermsBucket) keyString() string {
+	if key, ok := tb.Key.(string); ok {
+		return key
+	}
+	return fmt.Sprint(tb.Key)
+}
+
+// decodeResponse converte uma resposta do Elasticsearch no destino tipado.
+// Respostas de erro e corpos malformados viram *AppError com código estável.
+func decodeResponse(res *esapi.Response, operacao string, target interface{}) error {
+	if res.IsError() {
+		return decodeError(res, operacao)
+	}
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return wrapAppError(ErrCodeMalformedResponse, err, "erro ao decodificar resposta de %s", operacao)
+	}
+	return nil
+}
+
+// decodeError converte uma resposta de erro do Elasticsearch em *AppError
+func decodeError(res *esapi.Response, operacao string) error {
+	body, _ := io.ReadAll(res.Body)
+
+	reason := string(body)
+	var errRes ErrorResponse
+	if err := json.Unmarshal(body, &errRes); err == nil && errRes.Error.Type != "" {
+		reason = fmt.Sprintf("%s: %s", errRes.Error.Type, errRes.Error.Reason)
+	} else if res.StatusCode == http.StatusNotFound {
+		// GET de documento inexistente devolve 404 com found=false, sem corpo de erro
+		reason = "documento não encontrado"
+	}
+
+	code := ErrCodeBackendError
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		code = ErrCodeNotFound
+	case res.StatusCode == http.StatusBadRequest:
+		code = ErrCodeBadQuery
+	case res.StatusCode == http.StatusConflict:
+		// version_conflict_engine_exception: o documento mudou depois da versão informada
+		code = ErrCodeConflict
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable:
+		code = ErrCodeBackendUnavailable
+	}
+
+	return &AppError{
+		Code:    code,
+		Message: fmt.Sprintf("erro ao %s: [%d] %s", operacao, res.StatusCode, reason),
+		Status:  res.StatusCode,
+	}
+}
+
+// transportError converte uma falha de comunicação com o cluster em *AppError
+func transportError(err error, operacao string) error {
+	return wrapAppError(ErrCodeBackendUnavailable, err, "erro ao %s", operacao)
+}
@@ -0,0 +1,250 @@
+// Package esquery monta a Query DSL do Elasticsearch com tipos, no lugar de maps
+// aninhados escritos à mão. Cada Query sabe gerar o seu trecho de DSL com Map.
+//
+//	q := esquery.Bool().
+//		Filter(esquery.Terms("codigo_cliente", "CLI-1", "CLI-2")).
+//		Filter(esquery.Range("data_vencimento").Gte("2025-01-01"))
+//	body := map[string]interface{}{"query": q.Map()}
+package esquery
+
+// Query é um trecho de Query DSL
+type Query interface {
+	Map() map[string]interface{}
+}
+
+// MatchAllQuery casa todos os documentos
+type MatchAllQuery struct{}
+
+// MatchAll cria uma query match_all
+func MatchAll() MatchAllQuery {
+	return MatchAllQuery{}
+}
+
+func (MatchAllQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"match_all": map[string]interface{}{},
+	}
+}
+
+// TermQuery casa um valor exato em um campo keyword, numérico ou booleano
+type TermQuery struct {
+	Field string
+	Value interface{}
+}
+
+// Term cria uma query term
+func Term(field string, value interface{}) TermQuery {
+	return TermQuery{Field: field, Value: value}
+}
+
+func (q TermQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			q.Field: q.Value,
+		},
+	}
+}
+
+// TermsQuery casa qualquer um dos valores exatos de um campo
+type TermsQuery struct {
+	Field  string
+	Values []interface{}
+}
+
+// Terms cria uma query terms
+func Terms(field string, values ...interface{}) TermsQuery {
+	return TermsQuery{Field: field, Values: values}
+}
+
+// TermsStrings cria uma query terms a partir de textos
+func TermsStrings(field string, values ...string) TermsQuery {
+	q := TermsQuery{Field: field, Values: make([]interface{}, len(values))}
+	for i, value := range values {
+		q.Values[i] = value
+	}
+	return q
+}
+
+func (q TermsQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"terms": map[string]interface{}{
+			q.Field: q.Values,
+		},
+	}
+}
+
+// IdsQuery casa documentos pelo _id
+type IdsQuery struct {
+	Values []string
+}
+
+// Ids cria uma query ids
+func Ids(values ...string) IdsQuery {
+	return IdsQuery{Values: values}
+}
+
+func (q IdsQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"ids": map[string]interface{}{
+			"values": q.Values,
+		},
+	}
+}
+
+// ExistsQuery casa documentos que têm valor no campo
+type ExistsQuery struct {
+	Field string
+}
+
+// Exists cria uma query exists
+func Exists(field string) ExistsQuery {
+	return ExistsQuery{Field: field}
+}
+
+func (q ExistsQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"exists": map[string]interface{}{
+			"field": q.Field,
+		},
+	}
+}
+
+// RangeQuery limita um campo a um intervalo; limites nulos são omitidos
+type RangeQuery struct {
+	Field string
+	gt    interface{}
+	gte   interface{}
+	lt    interface{}
+	lte   interface{}
+}
+
+// Range cria uma query range sem limites
+func Range(field string) *RangeQuery {
+	return &RangeQuery{Field: field}
+}
+
+// Gt define o limite inferior exclusivo
+func (q *RangeQuery) Gt(value interface{}) *RangeQuery {
+	q.gt = value
+	return q
+}
+
+// Gte define o limite inferior inclusivo
+func (q *RangeQuery) Gte(value interface{}) *RangeQuery {
+	q.gte = value
+	return q
+}
+
+// Lt define o limite superior exclusivo
+func (q *RangeQuery) Lt(value interface{}) *RangeQuery {
+	q.lt = value
+	return q
+}
+
+// Lte define o limite superior inclusivo
+func (q *RangeQuery) Lte(value interface{}) *RangeQuery {
+	q.lte = value
+	return q
+}
+
+// IsEmpty informa se nenhum limite foi definido
+func (q *RangeQuery) IsEmpty() bool {
+	return q.gt == nil && q.gte == nil && q.lt == nil && q.lte == nil
+}
+
+func (q *RangeQuery) Map() map[string]interface{} {
+	bounds := map[string]interface{}{}
+	for name, value := range map[string]interface{}{"gt": q.gt, "gte": q.gte, "lt": q.lt, "lte": q.lte} {
+		if value != nil {
+			bounds[name] = value
+		}
+	}
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			q.Field: bounds,
+		},
+	}
+}
+
+// NestedQuery aplica uma query aos objetos de um campo nested
+type NestedQuery struct {
+	Path  string
+	Query Query
+}
+
+// Nested cria uma query nested
+func Nested(path string, query Query) NestedQuery {
+	return NestedQuery{Path: path, Query: query}
+}
+
+func (q NestedQuery) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":  q.Path,
+			"query": q.Query.Map(),
+		},
+	}
+}
+
+// BoolQuery combina queries; as cláusulas filter e must_not não afetam o score
+type BoolQuery struct {
+	must    []Query
+	filter  []Query
+	should  []Query
+	mustNot []Query
+}
+
+// Bool cria uma bool query vazia
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+// Must acrescenta cláusulas obrigatórias que contam no score
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	return q
+}
+
+// Filter acrescenta cláusulas obrigatórias sem score
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	return q
+}
+
+// Should acrescenta cláusulas opcionais
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	return q
+}
+
+// MustNot acrescenta cláusulas que excluem documentos
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	return q
+}
+
+// IsEmpty informa se a bool query não tem cláusulas
+func (q *BoolQuery) IsEmpty() bool {
+	return len(q.must)+len(q.filter)+len(q.should)+len(q.mustNot) == 0
+}
+
+func (q *BoolQuery) Map() map[string]interface{} {
+	clauses := map[string]interface{}{}
+	for name, queries := range map[string][]Query{"must": q.must, "filter": q.filter, "should": q.should, "must_not": q.mustNot} {
+		if len(queries) > 0 {
+			clauses[name] = maps(queries)
+		}
+	}
+	return map[string]interface{}{
+		"bool": clauses,
+	}
+}
+
+// maps gera a DSL de cada query da lista
+func maps(queries []Query) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(queries))
+	for i, q := range queries {
+		result[i] = q.Map()
+	}
+	return result
+}
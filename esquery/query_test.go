@@ -0,0 +1,104 @@
+package esquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestMap confere a DSL gerada por cada query contra o JSON esperado
+func TestMap(t *testing.T) {
+	tests := []struct {
+		nome  string
+		query Query
+		want  string
+	}{
+		{
+			nome:  "match_all",
+			query: MatchAll(),
+			want:  `{"match_all":{}}`,
+		},
+		{
+			nome:  "term",
+			query: Term("modalidade", 2),
+			want:  `{"term":{"modalidade":2}}`,
+		},
+		{
+			nome:  "terms",
+			query: Terms("codigo_produto", 1, 2),
+			want:  `{"terms":{"codigo_produto":[1,2]}}`,
+		},
+		{
+			nome:  "terms de textos",
+			query: TermsStrings("codigo_cliente", "CLI-1", "CLI-2"),
+			want:  `{"terms":{"codigo_cliente":["CLI-1","CLI-2"]}}`,
+		},
+		{
+			nome:  "ids",
+			query: Ids("rec-1", "rec-2"),
+			want:  `{"ids":{"values":["rec-1","rec-2"]}}`,
+		},
+		{
+			nome:  "exists",
+			query: Exists("cancelamentos.id_cancelamento"),
+			want:  `{"exists":{"field":"cancelamentos.id_cancelamento"}}`,
+		},
+		{
+			nome:  "range sem limites",
+			query: Range("data_vencimento"),
+			want:  `{"range":{"data_vencimento":{}}}`,
+		},
+		{
+			nome:  "range fechado",
+			query: Range("data_vencimento").Gte("2025-01-01").Lte("2025-12-31"),
+			want:  `{"range":{"data_vencimento":{"gte":"2025-01-01","lte":"2025-12-31"}}}`,
+		},
+		{
+			nome:  "range exclusivo",
+			query: Range("valor_original").Gt(10.5).Lt(100),
+			want:  `{"range":{"valor_original":{"gt":10.5,"lt":100}}}`,
+		},
+		{
+			nome:  "nested",
+			query: Nested("negociacoes", Range("negociacoes.data_negociacao").Lte("2025-06-30")),
+			want:  `{"nested":{"path":"negociacoes","query":{"range":{"negociacoes.data_negociacao":{"lte":"2025-06-30"}}}}}`,
+		},
+		{
+			nome:  "bool vazia",
+			query: Bool(),
+			want:  `{"bool":{}}`,
+		},
+		{
+			nome: "bool",
+			query: Bool().
+				Must(Term("modalidade", 1)).
+				Filter(TermsStrings("codigo_cliente", "CLI-1"), Range("data_vencimento").Gte("2025-01-01")).
+				Should(Term("codigo_produto", 3)).
+				MustNot(Nested("cancelamentos", Exists("cancelamentos.id_cancelamento"))),
+			want: `{"bool":{` +
+				`"filter":[{"terms":{"codigo_cliente":["CLI-1"]}},{"range":{"data_vencimento":{"gte":"2025-01-01"}}}],` +
+				`"must":[{"term":{"modalidade":1}}],` +
+				`"must_not":[{"nested":{"path":"cancelamentos","query":{"exists":{"field":"cancelamentos.id_cancelamento"}}}}],` +
+				`"should":[{"term":{"codigo_produto":3}}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nome, func(t *testing.T) {
+			got, err := json.Marshal(tt.query.Map())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Map() = %s\nesperado %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	if !Bool().IsEmpty() || Bool().MustNot(MatchAll()).IsEmpty() {
+		t.Error("Bool().IsEmpty() deve ser verdadeiro só sem cláusulas")
+	}
+	if !Range("valor_original").IsEmpty() || Range("valor_original").Gt(0).IsEmpty() {
+		t.Error("Range().IsEmpty() deve ser verdadeiro só sem limites")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"data-aggregator/domain"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Tipos de evento do ciclo de vida emitidos pelos sistemas de origem
+const (
+	EventoRecebivelCriado        = "RecebivelCriado"
+	EventoCancelamentoRegistrado = "CancelamentoRegistrado"
+	EventoNegociacaoRegistrada   = "NegociacaoRegistrada"
+)
+
+// LifecycleEvent é um evento do ciclo de vida de um recebível. RecebivelCriado traz o
+// recebível completo; os demais trazem o recebível alvo e o cancelamento ou a negociação.
+type LifecycleEvent struct {
+	Tipo         string               `json:"tipo"`
+	IDRecebivel  string               `json:"id_recebivel,omitempty"`
+	IDPagamento  string               `json:"id_pagamento,omitempty"` // routing; opcional nos eventos de update
+	Recebivel    *domain.Recebivel    `json:"recebivel,omitempty"`
+	Cancelamento *domain.Cancelamento `json:"cancelamento,omitempty"`
+	Negociacao   *domain.Negociacao   `json:"negociacao,omitempty"`
+}
+
+// ApplyEvent aplica um evento ao documento do recebível. Eventos repetidos são ignorados
+// e voltam aplicado=false: RecebivelCriado pelo id_recebivel, os demais pelo
+// id_cancelamento / id_negociacao, que por isso são obrigatórios nos eventos.
+func (rl *ReceivableLifecycle) ApplyEvent(ctx context.Context, evento LifecycleEvent) (bool, error) {
+	switch evento.Tipo {
+	case EventoRecebivelCriado:
+		if evento.Recebivel == nil {
+			return false, newAppError(ErrCodeInvalidArgument, "evento %s sem recebivel", evento.Tipo)
+		}
+		_, err := rl.Register(ctx, *evento.Recebivel)
+		if errorCode(err) == ErrCodeAlreadyExists {
+			return false, nil
+		}
+		return err == nil, err
+
+	case EventoCancelamentoRegistrado:
+		c := evento.Cancelamento
+		if evento.IDRecebivel == "" || c == nil || c.IDCancelamento == "" {
+			return false, newAppError(ErrCodeInvalidArgument, "evento %s exige id_recebivel e cancelamento.id_cancelamento", evento.Tipo)
+		}
+		if err := validarEvento(c.DataCancelamento, c.ValorCancelado); err != nil {
+			return false, err
+		}
+		return rl.store.AppendCancelamento(ctx, evento.IDRecebivel, evento.IDPagamento, *c)
+
+	case EventoNegociacaoRegistrada:
+		n := evento.Negociacao
+		if evento.IDRecebivel == "" || n == nil || n.IDNegociacao == "" {
+			return false, newAppError(ErrCodeInvalidArgument, "evento %s exige id_recebivel e negociacao.id_negociacao", evento.Tipo)
+		}
+		if err := validarEvento(n.DataNegociacao, n.ValorNegociado); err != nil {
+			return false, err
+		}
+		return rl.store.AppendNegociacao(ctx, evento.IDRecebivel, evento.IDPagamento, *n)
+	}
+	return false, newAppError(ErrCodeInvalidArgument, "tipo de evento desconhecido '%s'", evento.Tipo)
+}
+
+// validarEvento verifica a data e o valor de um cancelamento ou negociação
+func validarEvento(data string, valor domain.Money) error {
+	if _, err := time.Parse(domain.LayoutData, data); err != nil {
+		return newAppError(ErrCodeInvalidArgument, "data do evento inválida '%s': use o formato yyyy-MM-dd", data)
+	}
+	if valor <= 0 {
+		return newAppError(ErrCodeInvalidArgument, "valor do evento deve ser positivo")
+	}
+	return nil
+}
+
+// saldoNegativoMensagem é lançada pelo appendEventoScript quando o evento deixaria o saldo
+// negativo, e reconhecida na resposta de erro do update; só ASCII, para casar mesmo com o
+// JSON de erro escapado
+const saldoNegativoMensagem = "saldo_disponivel ficaria negativo"
+
+// appendEventoScript acrescenta params.evento ao array nested params.campo e recalcula o
+// saldo_disponivel. Se já houver um evento com o mesmo params.chave o update vira noop,
+// o que torna o replay de eventos idempotente.
+const appendEventoScript = `
+if (ctx._source[params.campo] == null) { ctx._source[params.campo] = new ArrayList(); }
+for (def e : ctx._source[params.campo]) {
+  if (e[params.chave] == params.evento[params.chave]) { ctx.op = 'noop'; return; }
+}
+ctx._source[params.campo].add(params.evento);
+` + saldoCalculoScript + `
+if (saldo < 0) { throw new IllegalArgumentException('` + saldoNegativoMensagem + `'); }
+ctx._source.saldo_disponivel = saldo / 100.0;
+`
+
+// AppendCancelamento acrescenta o cancelamento com um update scripted
+func (ec *ElasticsearchClient) AppendCancelamento(ctx context.Context, id, idPagamento string, cancelamento domain.Cancelamento) (bool, error) {
+	return ec.appendEvento(ctx, id, idPagamento, "cancelamentos", "id_cancelamento", cancelamento)
+}
+
+// AppendNegociacao acrescenta a negociação com um update scripted
+func (ec *ElasticsearchClient) AppendNegociacao(ctx context.Context, id, idPagamento string, negociacao domain.Negociacao) (bool, error) {
+	return ec.appendEvento(ctx, id, idPagamento, "negociacoes", "id_negociacao", negociacao)
+}
+
+// appendEvento roda o appendEventoScript no documento, roteado por id_pagamento
+func (ec *ElasticsearchClient) appendEvento(ctx context.Context, id, idPagamento, campo, chave string, evento interface{}) (bool, error) {
+	routing, err := ec.resolveRouting(ctx, ec.index, id, idPagamento)
+	if err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"_source": true,
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": appendEventoScript,
+			"params": map[string]interface{}{
+				"campo":  campo,
+				"chave":  chave,
+				"evento": evento,
+			},
+		},
+	})
+	if err != nil {
+		return false, wrapAppError(ErrCodeInternal, err, "erro ao codificar evento")
+	}
+
+	// O script roda condicionado à versão lida para a auditoria; se outro evento do mesmo
+	// recebível passou no meio, o documento é relido e o script reaplicado
+	for tentativa := 0; ; tentativa++ {
+		antes, versao, err := ec.snapshot(ctx, ec.index, id, routing)
+		if err != nil {
+			return false, err
+		}
+		updated, err := ec.applyEvento(ctx, id, routing, body, versao)
+		if errorCode(err) == ErrCodeConflict && tentativa < maxConflictRetries {
+			continue
+		}
+		if err != nil || updated.Result == "noop" {
+			return false, err
+		}
+		audit(ctx, ec, "update", ec.index, id, routing, antes, updated.source())
+		return true, nil
+	}
+}
+
+// applyEvento envia o update scripted do evento, condicionado à versão se informada
+func (ec *ElasticsearchClient) applyEvento(ctx context.Context, id, routing string, body []byte, versao *domain.Versao) (*UpdateResponse, error) {
+	req := esapi.UpdateRequest{
+		Index:         ec.index,
+		DocumentID:    id,
+		Body:          bytes.NewReader(body),
+		Routing:       routing,
+		IfSeqNo:       ifSeqNo(versao),
+		IfPrimaryTerm: ifPrimaryTerm(versao),
+	}
+	res, err := req.Do(ctx, ec.client)
+	if err != nil {
+		return nil, transportError(err, "aplicar evento")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(res.Body)
+		if bytes.Contains(body, []byte(saldoNegativoMensagem)) {
+			return nil, newAppError(ErrCodeInvalidArgument, "recebível '%s': %s", id, saldoNegativoMensagem)
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var updated UpdateResponse
+	if err := decodeResponse(res, "aplicar evento", &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// AppendCancelamento acrescenta o cancelamento se o id_cancelamento ainda não existir
+func (ms *MemoryStore) AppendCancelamento(ctx context.Context, id, idPagamento string, cancelamento domain.Cancelamento) (bool, error) {
+	return ms.appendEvento(ctx, id, func(recebivel *domain.Recebivel) bool {
+		for _, c := range recebivel.Cancelamentos {
+			if c.IDCancelamento == cancelamento.IDCancelamento {
+				return false
+			}
+		}
+		recebivel.Cancelamentos = append(append([]domain.Cancelamento(nil), recebivel.Cancelamentos...), cancelamento)
+		return true
+	})
+}
+
+// AppendNegociacao acrescenta a negociação se o id_negociacao ainda não existir
+func (ms *MemoryStore) AppendNegociacao(ctx context.Context, id, idPagamento string, negociacao domain.Negociacao) (bool, error) {
+	return ms.appendEvento(ctx, id, func(recebivel *domain.Recebivel) bool {
+		for _, n := range recebivel.Negociacoes {
+			if n.IDNegociacao == negociacao.IDNegociacao {
+				return false
+			}
+		}
+		recebivel.Negociacoes = append(append([]domain.Negociacao(nil), recebivel.Negociacoes...), negociacao)
+		return true
+	})
+}
+
+// appendEvento aplica a mudança sob o lock, como o script faz no documento do índice;
+// change devolve false se o evento já existir
+func (ms *MemoryStore) appendEvento(ctx context.Context, id string, change func(*domain.Recebivel) bool) (bool, error) {
+	ms.mu.Lock()
+	recebivel, ok := ms.documents[id]
+	if !ok {
+		ms.mu.Unlock()
+		return false, newAppError(ErrCodeNotFound, "recebível '%s' não encontrado", id)
+	}
+	antes := snapshotOf(recebivel)
+	if !change(&recebivel) {
+		ms.mu.Unlock()
+		return false, nil
+	}
+	if err := validarRecebivel(&recebivel); err != nil {
+		ms.mu.Unlock()
+		return false, err
+	}
+
+	recebivel.UpdateSaldoDisponivel()
+	recebivel.Versao = ms.nextVersao()
+	ms.documents[id] = recebivel
+	ms.mu.Unlock()
+
+	audit(ctx, ms, "update", memoryAuditIndex, id, recebivel.IDPagamento, antes, snapshotOf(recebivel))
+	return true, nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"data-aggregator/domain"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ExportFormat é o formato de arquivo de uma exportação de recebíveis
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportNDJSON  ExportFormat = "ndjson"
+	ExportParquet ExportFormat = "parquet"
+)
+
+// contentType retorna o Content-Type da resposta HTTP no formato
+func (f ExportFormat) contentType() string {
+	switch f {
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	case ExportParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ExportRow é uma linha exportada: os campos do recebível com cancelamentos e negociações
+// achatados em quantidade e total, e o saldo calculado por domain.Recebivel.Saldo
+type ExportRow struct {
+	IDRecebivel             string       `json:"id_recebivel"`
+	IDPagamento             string       `json:"id_pagamento"`
+	CodigoCliente           string       `json:"codigo_cliente"`
+	CodigoProduto           int          `json:"codigo_produto"`
+	CodigoProdutoParceiro   int          `json:"codigo_produto_parceiro"`
+	Modalidade              int          `json:"modalidade"`
+	DataVencimento          string       `json:"data_vencimento"`
+	ValorOriginal           domain.Money `json:"valor_original"`
+	QuantidadeCancelamentos int          `json:"quantidade_cancelamentos"`
+	TotalCancelado          domain.Money `json:"total_cancelado"`
+	QuantidadeNegociacoes   int          `json:"quantidade_negociacoes"`
+	TotalNegociado          domain.Money `json:"total_negociado"`
+	SaldoDisponivel         domain.Money `json:"saldo_disponivel"`
+}
+
+// exportColumns é o cabeçalho do CSV, na ordem de ExportRow.values
+var exportColumns = []string{
+	"id_recebivel", "id_pagamento", "codigo_cliente", "codigo_produto", "codigo_produto_parceiro",
+	"modalidade", "data_vencimento", "valor_original", "quantidade_cancelamentos", "total_cancelado",
+	"quantidade_negociacoes", "total_negociado", "saldo_disponivel",
+}
+
+// newExportRow achata um recebível em uma linha de exportação
+func newExportRow(recebivel *domain.Recebivel) ExportRow {
+	return ExportRow{
+		IDRecebivel:             recebivel.IDRecebivel,
+		IDPagamento:             recebivel.IDPagamento,
+		CodigoCliente:           recebivel.CodigoCliente,
+		CodigoProduto:           recebivel.CodigoProduto,
+		CodigoProdutoParceiro:   recebivel.CodigoProdutoParceiro,
+		Modalidade:              recebivel.Modalidade,
+		DataVencimento:          recebivel.DataVencimento,
+		ValorOriginal:           recebivel.ValorOriginal,
+		QuantidadeCancelamentos: len(recebivel.Cancelamentos),
+		TotalCancelado:          recebivel.TotalCancelado(),
+		QuantidadeNegociacoes:   len(recebivel.Negociacoes),
+		TotalNegociado:          recebivel.TotalNegociado(),
+		SaldoDisponivel:         recebivel.Saldo(),
+	}
+}
+
+// values retorna os campos da linha como texto, na ordem de exportColumns
+func (r ExportRow) values() []string {
+	return []string{
+		r.IDRecebivel, r.IDPagamento, r.CodigoCliente,
+		strconv.Itoa(r.CodigoProduto), strconv.Itoa(r.CodigoProdutoParceiro), strconv.Itoa(r.Modalidade),
+		r.DataVencimento, r.ValorOriginal.String(),
+		strconv.Itoa(r.QuantidadeCancelamentos), r.TotalCancelado.String(),
+		strconv.Itoa(r.QuantidadeNegociacoes), r.TotalNegociado.String(),
+		r.SaldoDisponivel.String(),
+	}
+}
+
+// rowWriter grava as linhas de uma exportação num formato
+type rowWriter interface {
+	write(row ExportRow) error
+	flush() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvRowWriter) write(row ExportRow) error {
+	return cw.w.Write(row.values())
+}
+
+func (cw *csvRowWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonRowWriter) write(row ExportRow) error {
+	return nw.enc.Encode(row)
+}
+
+func (nw *ndjsonRowWriter) flush() error {
+	return nil
+}
+
+// parquetExportRow é o schema Parquet de ExportRow, com as mesmas colunas do CSV. Os
+// valores são DECIMAL(18,2) sobre os centavos de domain.Money, sem passar por float.
+type parquetExportRow struct {
+	IDRecebivel             string `parquet:"name=id_recebivel, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IDPagamento             string `parquet:"name=id_pagamento, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CodigoCliente           string `parquet:"name=codigo_cliente, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CodigoProduto           int32  `parquet:"name=codigo_produto, type=INT32"`
+	CodigoProdutoParceiro   int32  `parquet:"name=codigo_produto_parceiro, type=INT32"`
+	Modalidade              int32  `parquet:"name=modalidade, type=INT32"`
+	DataVencimento          string `parquet:"name=data_vencimento, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ValorOriginal           int64  `parquet:"name=valor_original, type=INT64, convertedtype=DECIMAL, scale=2, precision=18"`
+	QuantidadeCancelamentos int32  `parquet:"name=quantidade_cancelamentos, type=INT32"`
+	TotalCancelado          int64  `parquet:"name=total_cancelado, type=INT64, convertedtype=DECIMAL, scale=2, precision=18"`
+	QuantidadeNegociacoes   int32  `parquet:"name=quantidade_negociacoes, type=INT32"`
+	TotalNegociado          int64  `parquet:"name=total_negociado, type=INT64, convertedtype=DECIMAL, scale=2, precision=18"`
+	SaldoDisponivel         int64  `parquet:"name=saldo_disponivel, type=INT64, convertedtype=DECIMAL, scale=2, precision=18"`
+}
+
+// parquetRowGroupSize é o tamanho, em bytes, em que um row group é fechado e gravado; só o
+// row group corrente fica em memória
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+type parquetRowWriter struct {
+	pw *writer.ParquetWriter
+}
+
+func (pw *parquetRowWriter) write(row ExportRow) error {
+	return pw.pw.Write(parquetExportRow{
+		IDRecebivel:             row.IDRecebivel,
+		IDPagamento:             row.IDPagamento,
+		CodigoCliente:           row.CodigoCliente,
+		CodigoProduto:           int32(row.CodigoProduto),
+		CodigoProdutoParceiro:   int32(row.CodigoProdutoParceiro),
+		Modalidade:              int32(row.Modalidade),
+		DataVencimento:          row.DataVencimento,
+		ValorOriginal:           int64(row.ValorOriginal),
+		QuantidadeCancelamentos: int32(row.QuantidadeCancelamentos),
+		TotalCancelado:          int64(row.TotalCancelado),
+		QuantidadeNegociacoes:   int32(row.QuantidadeNegociacoes),
+		TotalNegociado:          int64(row.TotalNegociado),
+		SaldoDisponivel:         int64(row.SaldoDisponivel),
+	})
+}
+
+// flush grava o último row group e o rodapé do arquivo
+func (pw *parquetRowWriter) flush() error {
+	return pw.pw.WriteStop()
+}
+
+// newRowWriter abre o writer do formato; o CSV já sai com o cabeçalho
+func newRowWriter(format ExportFormat, w io.Writer) (rowWriter, error) {
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return nil, err
+		}
+		return &csvRowWriter{w: cw}, nil
+	case ExportNDJSON:
+		return &ndjsonRowWriter{enc: json.NewEncoder(w)}, nil
+	case ExportParquet:
+		pw, err := writer.NewParquetWriterFromWriter(w, new(parquetExportRow), 1)
+		if err != nil {
+			return nil, err
+		}
+		pw.RowGroupSize = parquetRowGroupSize
+		return &parquetRowWriter{pw: pw}, nil
+	}
+	return nil, newAppError(ErrCodeInvalidArgument, "formato inválido '%s': use csv, ndjson ou parquet", format)
+}
+
+// exportReceivables grava todos os recebíveis do filtro no writer, um a um, conforme
+// ScanReceivables os lê (point-in-time + search_after no Elasticsearch): a exportação
+// nunca guarda o resultado inteiro em memória. Retorna quantos recebíveis foram gravados.
+func exportReceivables(ctx context.Context, store ReceivableStore, filter ReceivableFilter, format ExportFormat, w io.Writer) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	rw, err := newRowWriter(format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	err = store.ScanReceivables(ctx, ReceivableQuery{Filter: filter}, func(recebivel *domain.Recebivel) error {
+		total++
+		return rw.write(newExportRow(recebivel))
+	})
+	if err != nil {
+		return total, err
+	}
+	return total, rw.flush()
+}
+
+// parseExportFilter lê o filtro de uma exportação: o JSON de ReceivableFilter, o mesmo
+// input filter de searchReceivables; vazio exporta o índice inteiro
+func parseExportFilter(value string) (ReceivableFilter, error) {
+	var filter ReceivableFilter
+	if value == "" {
+		return filter, nil
+	}
+	if err := json.Unmarshal([]byte(value), &filter); err != nil {
+		return filter, wrapAppError(ErrCodeInvalidArgument, err, "filter inválido")
+	}
+	return filter, nil
+}
+
+// countingWriter conta os bytes já enviados ao cliente, para saber se um erro ainda pode
+// virar resposta de erro ou se a resposta já começou
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// exportHandler exporta os recebíveis do filtro em streaming:
+//
+//	GET /export/receivables?format=csv|ndjson|parquet&filter={"codigo_cliente":["X"]}
+//
+// Erros antes do primeiro byte viram a resposta de erro usual; depois disso a conexão é
+// abortada, para o cliente não tomar um arquivo truncado por completo.
+func exportHandler(store ReceivableStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "Método não permitido. Use GET",
+			})
+			return
+		}
+
+		format := ExportFormat(r.URL.Query().Get("format"))
+		if format == "" {
+			format = ExportCSV
+		}
+		filter, err := parseExportFilter(r.URL.Query().Get("filter"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeAppError(w, err)
+			return
+		}
+
+		// A exportação pode passar do write timeout do servidor, pensado para as consultas
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", format.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"recebiveis.%s\"", format))
+
+		out := &countingWriter{w: w}
+		total, err := exportReceivables(r.Context(), store, filter, format, out)
+		if err != nil {
+			if out.n == 0 {
+				w.Header().Del("Content-Disposition")
+				w.Header().Set("Content-Type", "application/json")
+				writeAppError(w, err)
+				return
+			}
+			log.Printf("exportação interrompida após %d recebíveis: %v", total, err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// runExport exporta os recebíveis do filtro para um arquivo.
+//
+//	data-aggregator export [flags de configuração] -out arquivo [-format csv|ndjson|parquet] [-filter JSON]
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "", "Arquivo de saída (obrigatório)")
+	format := fs.String("format", string(ExportCSV), "Formato: csv, ndjson ou parquet")
+	filterJSON := fs.String("filter", "", `Filtro no formato do input filter de searchReceivables, ex.: {"codigo_cliente":["CLI-10001"]}`)
+
+	cfg, err := LoadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("-out é obrigatório")
+	}
+	filter, err := parseExportFilter(*filterJSON)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	total, err := exportReceivables(context.Background(), store, filter, ExportFormat(*format), file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	fmt.Printf("✅ %d recebível(is) exportado(s) em %s\n", total, *out)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// TestExportParquet lê de volta o arquivo exportado e confere as linhas e os valores em centavos
+func TestExportParquet(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	var out bytes.Buffer
+	total, err := exportReceivables(context.Background(), store, customerFilter("CLI-A", "", ""), ExportParquet, &out)
+	if err != nil {
+		t.Fatalf("exportReceivables: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("total = %d, esperado 2", total)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(parquetExportRow), 1)
+	if err != nil {
+		t.Fatalf("arquivo parquet inválido: %v", err)
+	}
+	defer pr.ReadStop()
+
+	rows := make([]parquetExportRow, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal(err)
+	}
+	want := []parquetExportRow{
+		{
+			IDRecebivel: "rec-1", IDPagamento: "pag-1", CodigoCliente: "CLI-A", DataVencimento: "2025-01-10",
+			ValorOriginal: 100000, QuantidadeCancelamentos: 1, TotalCancelado: 10000, SaldoDisponivel: 90000,
+		},
+		{
+			IDRecebivel: "rec-2", IDPagamento: "pag-1", CodigoCliente: "CLI-A", DataVencimento: "2025-02-10",
+			ValorOriginal: 50000, QuantidadeNegociacoes: 1, TotalNegociado: 2550, SaldoDisponivel: 47450,
+		},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("linhas = %+v, esperado %+v", rows, want)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"time"
+
+	"data-aggregator/domain"
+	"data-aggregator/esquery"
+)
+
+// ReceivableFilter combina os critérios de busca de recebíveis; campos vazios não filtram.
+// As tags json são os nomes dos campos do input ReceivableFilter do GraphQL.
+type ReceivableFilter struct {
+	CodigoCliente         []string   `json:"codigo_cliente,omitempty"` // qualquer um dos clientes
+	IDPagamento           string     `json:"id_pagamento,omitempty"`
+	CodigoProduto         *int       `json:"codigo_produto,omitempty"`
+	CodigoProdutoParceiro *int       `json:"codigo_produto_parceiro,omitempty"`
+	Modalidade            *int       `json:"modalidade,omitempty"`
+	DataVencimento        DateRange  `json:"data_vencimento,omitempty"`
+	DataCancelamento      DateRange  `json:"data_cancelamento,omitempty"` // algum cancelamento no intervalo
+	DataNegociacao        DateRange  `json:"data_negociacao,omitempty"`   // alguma negociação no intervalo
+	ValorOriginal         MoneyRange `json:"valor_original,omitempty"`
+	SaldoDisponivel       MoneyRange `json:"saldo_disponivel,omitempty"` // saldo gravado no documento
+	HasCancelamento       *bool      `json:"has_cancelamento,omitempty"`
+	HasNegociacao         *bool      `json:"has_negociacao,omitempty"`
+}
+
+// DateRange é um intervalo inclusivo de datas yyyy-MM-dd; pontas vazias ficam abertas
+type DateRange struct {
+	Gte string `json:"gte,omitempty"`
+	Lte string `json:"lte,omitempty"`
+}
+
+// MoneyRange é um intervalo inclusivo de valores; pontas nulas ficam abertas
+type MoneyRange struct {
+	Gte *domain.Money `json:"gte,omitempty"`
+	Lte *domain.Money `json:"lte,omitempty"`
+}
+
+// customerFilter filtra os recebíveis de um cliente (se informado) por data de vencimento
+func customerFilter(codigoCliente, dataInicio, dataFim string) ReceivableFilter {
+	filter := ReceivableFilter{DataVencimento: DateRange{Gte: dataInicio, Lte: dataFim}}
+	if codigoCliente != "" {
+		filter.CodigoCliente = []string{codigoCliente}
+	}
+	return filter
+}
+
+// Validate verifica o formato das datas e a ordem das pontas dos intervalos
+func (f ReceivableFilter) Validate() error {
+	for name, r := range map[string]DateRange{
+		"data_vencimento":   f.DataVencimento,
+		"data_cancelamento": f.DataCancelamento,
+		"data_negociacao":   f.DataNegociacao,
+	} {
+		for _, data := range []string{r.Gte, r.Lte} {
+			if data == "" {
+				continue
+			}
+			if _, err := time.Parse(domain.LayoutData, data); err != nil {
+				return newAppError(ErrCodeInvalidArgument, "%s: data inválida '%s', use o formato yyyy-MM-dd", name, data)
+			}
+		}
+		if r.Gte != "" && r.Lte != "" && r.Gte > r.Lte {
+			return newAppError(ErrCodeInvalidArgument, "%s: gte '%s' é posterior a lte '%s'", name, r.Gte, r.Lte)
+		}
+	}
+
+	for name, r := range map[string]MoneyRange{
+		"valor_original":   f.ValorOriginal,
+		"saldo_disponivel": f.SaldoDisponivel,
+	} {
+		if r.Gte != nil && r.Lte != nil && *r.Gte > *r.Lte {
+			return newAppError(ErrCodeInvalidArgument, "%s: gte %s é maior que lte %s", name, r.Gte, r.Lte)
+		}
+	}
+	return nil
+}
+
+// Query traduz o filtro para a Query DSL; sem critérios o resultado é match_all
+func (f ReceivableFilter) Query() esquery.Query {
+	q := esquery.Bool()
+
+	if len(f.CodigoCliente) > 0 {
+		q.Filter(esquery.TermsStrings("codigo_cliente", f.CodigoCliente...))
+	}
+	if f.IDPagamento != "" {
+		q.Filter(esquery.Term("id_pagamento", f.IDPagamento))
+	}
+	if f.CodigoProduto != nil {
+		q.Filter(esquery.Term("codigo_produto", *f.CodigoProduto))
+	}
+	if f.CodigoProdutoParceiro != nil {
+		q.Filter(esquery.Term("codigo_produto_parceiro", *f.CodigoProdutoParceiro))
+	}
+	if f.Modalidade != nil {
+		q.Filter(esquery.Term("modalidade", *f.Modalidade))
+	}
+
+	if r := f.DataVencimento.query("data_vencimento"); r != nil {
+		q.Filter(r)
+	}
+	if r := f.DataCancelamento.query("cancelamentos.data_cancelamento"); r != nil {
+		q.Filter(esquery.Nested("cancelamentos", r))
+	}
+	if r := f.DataNegociacao.query("negociacoes.data_negociacao"); r != nil {
+		q.Filter(esquery.Nested("negociacoes", r))
+	}
+	if r := f.ValorOriginal.query("valor_original"); r != nil {
+		q.Filter(r)
+	}
+	if r := f.SaldoDisponivel.query("saldo_disponivel"); r != nil {
+		q.Filter(r)
+	}
+
+	if f.HasCancelamento != nil {
+		existe := esquery.Nested("cancelamentos", esquery.Exists("cancelamentos.id_cancelamento"))
+		if *f.HasCancelamento {
+			q.Filter(existe)
+		} else {
+			q.MustNot(existe)
+		}
+	}
+	if f.HasNegociacao != nil {
+		existe := esquery.Nested("negociacoes", esquery.Exists("negociacoes.id_negociacao"))
+		if *f.HasNegociacao {
+			q.Filter(existe)
+		} else {
+			q.MustNot(existe)
+		}
+	}
+
+	if q.IsEmpty() {
+		return esquery.MatchAll()
+	}
+	return q
+}
+
+// query retorna o range do intervalo ou nil se as duas pontas estiverem abertas
+func (r DateRange) query(field string) *esquery.RangeQuery {
+	q := esquery.Range(field)
+	if r.Gte != "" {
+		q.Gte(r.Gte)
+	}
+	if r.Lte != "" {
+		q.Lte(r.Lte)
+	}
+	if q.IsEmpty() {
+		return nil
+	}
+	return q
+}
+
+// query retorna o range do intervalo em reais ou nil se as duas pontas estiverem abertas
+func (r MoneyRange) query(field string) *esquery.RangeQuery {
+	q := esquery.Range(field)
+	if r.Gte != nil {
+		q.Gte(r.Gte.Float64())
+	}
+	if r.Lte != nil {
+		q.Lte(r.Lte.Float64())
+	}
+	if q.IsEmpty() {
+		return nil
+	}
+	return q
+}
+
+// contains informa se a data está no intervalo
+func (r DateRange) contains(data string) bool {
+	// Datas no formato yyyy-MM-dd podem ser comparadas como texto
+	return (r.Gte == "" || data >= r.Gte) && (r.Lte == "" || data <= r.Lte)
+}
+
+// isOpen informa se o intervalo não tem limites
+func (r DateRange) isOpen() bool {
+	return r.Gte == "" && r.Lte == ""
+}
+
+// contains informa se o valor está no intervalo
+func (r MoneyRange) contains(valor domain.Money) bool {
+	return (r.Gte == nil || valor >= *r.Gte) && (r.Lte == nil || valor <= *r.Lte)
+}
+
+// Match avalia o filtro sobre um recebível em memória, com a mesma semântica da Query
+func (f ReceivableFilter) Match(recebivel *domain.Recebivel) bool {
+	if len(f.CodigoCliente) > 0 && !containsString(f.CodigoCliente, recebivel.CodigoCliente) {
+		return false
+	}
+	if f.IDPagamento != "" && recebivel.IDPagamento != f.IDPagamento {
+		return false
+	}
+	if f.CodigoProduto != nil && recebivel.CodigoProduto != *f.CodigoProduto {
+		return false
+	}
+	if f.CodigoProdutoParceiro != nil && recebivel.CodigoProdutoParceiro != *f.CodigoProdutoParceiro {
+		return false
+	}
+	if f.Modalidade != nil && recebivel.Modalidade != *f.Modalidade {
+		return false
+	}
+	if !f.DataVencimento.contains(recebivel.DataVencimento) {
+		return false
+	}
+	if !f.ValorOriginal.contains(recebivel.ValorOriginal) {
+		return false
+	}
+	if !f.SaldoDisponivel.contains(recebivel.SaldoDisponivel) {
+		return false
+	}
+
+	if !f.DataCancelamento.isOpen() {
+		encontrado := false
+		for _, c := range recebivel.Cancelamentos {
+			encontrado = encontrado || f.DataCancelamento.contains(c.DataCancelamento)
+		}
+		if !encontrado {
+			return false
+		}
+	}
+	if !f.DataNegociacao.isOpen() {
+		encontrado := false
+		for _, n := range recebivel.Negociacoes {
+			encontrado = encontrado || f.DataNegociacao.contains(n.DataNegociacao)
+		}
+		if !encontrado {
+			return false
+		}
+	}
+
+	if f.HasCancelamento != nil && *f.HasCancelamento != (len(recebivel.Cancelamentos) > 0) {
+		return false
+	}
+	if f.HasNegociacao != nil && *f.HasNegociacao != (len(recebivel.Negociacoes) > 0) {
+		return false
+	}
+
+	return true
+}
+
+// containsString informa se o valor está na lista
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
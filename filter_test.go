@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"data-aggregator/domain"
+)
+
+// TestReceivableFilterQuery confere a tradução de cada critério do filtro para a Query DSL
+func TestReceivableFilterQuery(t *testing.T) {
+	produto, modalidade := 7, 2
+	sim, nao := true, false
+	minimo, maximo := domain.Reais(100), domain.Centavos(123456)
+
+	tests := []struct {
+		nome   string
+		filter ReceivableFilter
+		want   string
+	}{
+		{
+			nome: "vazio",
+			want: `{"match_all":{}}`,
+		},
+		{
+			nome:   "cliente e vencimento",
+			filter: customerFilter("CLI-A", "2025-01-01", "2025-12-31"),
+			want: `{"bool":{"filter":[` +
+				`{"terms":{"codigo_cliente":["CLI-A"]}},` +
+				`{"range":{"data_vencimento":{"gte":"2025-01-01","lte":"2025-12-31"}}}]}}`,
+		},
+		{
+			nome: "campos exatos",
+			filter: ReceivableFilter{
+				CodigoCliente: []string{"CLI-A", "CLI-B"},
+				IDPagamento:   "pag-1",
+				CodigoProduto: &produto,
+				Modalidade:    &modalidade,
+			},
+			want: `{"bool":{"filter":[` +
+				`{"terms":{"codigo_cliente":["CLI-A","CLI-B"]}},` +
+				`{"term":{"id_pagamento":"pag-1"}},` +
+				`{"term":{"codigo_produto":7}},` +
+				`{"term":{"modalidade":2}}]}}`,
+		},
+		{
+			nome: "datas dos eventos e valor",
+			filter: ReceivableFilter{
+				DataCancelamento: DateRange{Gte: "2025-03-01"},
+				DataNegociacao:   DateRange{Lte: "2025-06-30"},
+				ValorOriginal:    MoneyRange{Gte: &minimo, Lte: &maximo},
+			},
+			want: `{"bool":{"filter":[` +
+				`{"nested":{"path":"cancelamentos","query":{"range":{"cancelamentos.data_cancelamento":{"gte":"2025-03-01"}}}}},` +
+				`{"nested":{"path":"negociacoes","query":{"range":{"negociacoes.data_negociacao":{"lte":"2025-06-30"}}}}},` +
+				`{"range":{"valor_original":{"gte":100,"lte":1234.56}}}]}}`,
+		},
+		{
+			nome:   "com cancelamento e sem negociação",
+			filter: ReceivableFilter{HasCancelamento: &sim, HasNegociacao: &nao},
+			want: `{"bool":{` +
+				`"filter":[{"nested":{"path":"cancelamentos","query":{"exists":{"field":"cancelamentos.id_cancelamento"}}}}],` +
+				`"must_not":[{"nested":{"path":"negociacoes","query":{"exists":{"field":"negociacoes.id_negociacao"}}}}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nome, func(t *testing.T) {
+			got, err := json.Marshal(tt.filter.Query().Map())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Query() = %s\nesperado %s", got, tt.want)
+			}
+		})
+	}
+}
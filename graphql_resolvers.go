@@ -1,200 +1,104 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 
+	"data-aggregator/domain"
 	"github.com/graphql-go/graphql"
 )
 
-// Resolver para buscar todos os recebíveis com limite
-func getAllReceivablesResolver(params graphql.ResolveParams) (interface{}, error) {
-	size, _ := params.Args["size"].(int)
-	if size == 0 {
-		size = 10
-	}
-
-	query := map[string]interface{}{
-		"size": size,
-		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
-		},
-	}
+// Resolvers agrupa os resolvers GraphQL e o store de recebíveis que eles consultam
+type Resolvers struct {
+	store     ReceivableStore
+	balances  *BalanceEngine
+	lifecycle *ReceivableLifecycle
+	cfg       ServerConfig
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
+// NewResolvers cria os resolvers usando o store e a configuração informados
+func NewResolvers(store ReceivableStore, cfg ServerConfig) *Resolvers {
+	return &Resolvers{
+		store:     store,
+		balances:  NewBalanceEngine(store),
+		lifecycle: NewReceivableLifecycle(store),
+		cfg:       cfg,
 	}
+}
 
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
-	if err != nil {
-		return nil, err
+// context deriva o contexto da requisição GraphQL limitado pelo query_timeout
+func (r *Resolvers) context(params graphql.ResolveParams) (context.Context, context.CancelFunc) {
+	ctx := params.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
+	if r.cfg.QueryTimeout.Duration > 0 {
+		return context.WithTimeout(ctx, r.cfg.QueryTimeout.Duration)
 	}
+	return context.WithCancel(ctx)
+}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	total := int(result["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+// Resolver para buscar todos os recebíveis com limite
+func (r *Resolvers) getAllReceivablesResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	receivables := make([]map[string]interface{}, len(hits))
-	for i, hit := range hits {
-		hitMap := hit.(map[string]interface{})
-		source := hitMap["_source"].(map[string]interface{})
-		source["id"] = hitMap["_id"]
-		receivables[i] = source
+	size, _ := params.Args["size"].(int)
+	if size == 0 {
+		size = 10
 	}
 
-	return map[string]interface{}{
-		"total":       total,
-		"receivables": receivables,
-	}, nil
+	return r.store.SearchReceivables(ctx, ReceivableQuery{
+		Size: size,
+	})
 }
 
 // Resolver para buscar recebível por ID
-func getReceivableByIdResolver(params graphql.ResolveParams) (interface{}, error) {
+func (r *Resolvers) getReceivableByIdResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	id, ok := params.Args["id"].(string)
 	if !ok {
-		return nil, fmt.Errorf("id é obrigatório")
+		return nil, newAppError(ErrCodeInvalidArgument, "id é obrigatório")
 	}
+	idPagamento, _ := params.Args["id_pagamento"].(string)
 
-	doc, err := esClient.GetDocument(context.Background(), "ciclo_vida_recebivel", id)
-	if err != nil {
-		return nil, err
-	}
+	return r.store.GetReceivable(ctx, id, idPagamento)
+}
 
-	source := doc["_source"].(map[string]interface{})
-	source["id"] = doc["_id"]
-	return source, nil
+// Resolver para paginar a trilha de auditoria de um recebível
+func (r *Resolvers) receivableAuditTrailResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	id, _ := params.Args["id"].(string)
+	return auditTrailPage(ctx, r.store, id, pageRequest(params))
 }
 
 // Resolver para buscar saldo do cliente por período
-func getCustomerBalanceResolver(params graphql.ResolveParams) (interface{}, error) {
+func (r *Resolvers) getCustomerBalanceResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	codigoCliente, _ := params.Args["codigo_cliente"].(string)
 	dataInicio, _ := params.Args["data_inicio"].(string)
 	dataFim, _ := params.Args["data_fim"].(string)
-
-	if codigoCliente == "" || dataInicio == "" || dataFim == "" {
-		return nil, fmt.Errorf("codigo_cliente, data_inicio e data_fim são obrigatórios")
-	}
-
-	query := map[string]interface{}{
-		"size": 0,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"codigo_cliente": codigoCliente,
-						},
-					},
-					{
-						"range": map[string]interface{}{
-							"data_vencimento": map[string]interface{}{
-								"gte": dataInicio,
-								"lte": dataFim,
-							},
-						},
-					},
-				},
-			},
-		},
-		"aggs": map[string]interface{}{
-			"resultado": map[string]interface{}{
-				"filters": map[string]interface{}{
-					"filters": map[string]interface{}{
-						"all": map[string]interface{}{
-							"match_all": map[string]interface{}{},
-						},
-					},
-				},
-				"aggs": map[string]interface{}{
-					"soma_valores_originais": map[string]interface{}{
-						"sum": map[string]interface{}{
-							"field": "valor_original",
-						},
-					},
-					"soma_cancelamentos": map[string]interface{}{
-						"nested": map[string]interface{}{
-							"path": "cancelamentos",
-						},
-						"aggs": map[string]interface{}{
-							"total_cancelado": map[string]interface{}{
-								"sum": map[string]interface{}{
-									"field": "cancelamentos.valor_cancelado",
-								},
-							},
-						},
-					},
-					"soma_negociacoes": map[string]interface{}{
-						"nested": map[string]interface{}{
-							"path": "negociacoes",
-						},
-						"aggs": map[string]interface{}{
-							"total_negociado": map[string]interface{}{
-								"sum": map[string]interface{}{
-									"field": "negociacoes.valor_negociado",
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
-	}
-
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	hits := result["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)
-	aggs := result["aggregations"].(map[string]interface{})["resultado"].(map[string]interface{})["buckets"].(map[string]interface{})["all"].(map[string]interface{})
-
-	valorOriginal := aggs["soma_valores_originais"].(map[string]interface{})["value"].(float64)
-	cancelamentos := aggs["soma_cancelamentos"].(map[string]interface{})["total_cancelado"].(map[string]interface{})["value"].(float64)
-	negociacoes := aggs["soma_negociacoes"].(map[string]interface{})["total_negociado"].(map[string]interface{})["value"].(float64)
-
-	saldoTotal := valorOriginal - cancelamentos - negociacoes
-
-	return map[string]interface{}{
-		"codigo_cliente": codigoCliente,
-		"periodo": map[string]interface{}{
-			"inicio": dataInicio,
-			"fim":    dataFim,
-		},
-		"total_recebiveis": int(hits),
-		"saldo_total":      saldoTotal,
-		"saldo_formatado":  formatarMoeda(saldoTotal),
-	}, nil
+	asOf, _ := params.Args["as_of"].(string)
+
+	return r.balances.CustomerBalance(ctx, BalanceQuery{
+		CodigoCliente: codigoCliente,
+		DataInicio:    dataInicio,
+		DataFim:       dataFim,
+		AsOf:          asOf,
+	})
 }
 
 // Resolver para buscar recebíveis por cliente e data de vencimento
-func getReceivablesByCustomerAndDueDateResolver(params graphql.ResolveParams) (interface{}, error) {
+func (r *Resolvers) getReceivablesByCustomerAndDueDateResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	codigoCliente, _ := params.Args["codigo_cliente"].(string)
 	dataInicio, _ := params.Args["data_inicio"].(string)
 	dataFim, _ := params.Args["data_fim"].(string)
@@ -205,392 +109,525 @@ func getReceivablesByCustomerAndDueDateResolver(params graphql.ResolveParams) (i
 		size = 10
 	}
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"match": map[string]interface{}{
-							"codigo_cliente": codigoCliente,
-						},
-					},
-					{
-						"range": map[string]interface{}{
-							"data_vencimento": map[string]interface{}{
-								"gte": dataInicio,
-								"lte": dataFim,
-							},
-						},
-					},
-				},
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"data_vencimento": map[string]interface{}{
-					"order": "asc",
-				},
-			},
-		},
-		"from": from,
-		"size": size,
-	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
-	}
-
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
-	}
+	return r.store.SearchReceivables(ctx, ReceivableQuery{
+		Filter: customerFilter(codigoCliente, dataInicio, dataFim),
+		Sort:   []SortField{{Field: "data_vencimento"}},
+		From:   from,
+		Size:   size,
+	})
+}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	total := int(result["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+// Resolver paginado por cursor para recebíveis por cliente e data de vencimento
+func (r *Resolvers) getReceivablesByCustomerAndDueDateConnectionResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	receivables := make([]map[string]interface{}, len(hits))
-	for i, hit := range hits {
-		hitMap := hit.(map[string]interface{})
-		source := hitMap["_source"].(map[string]interface{})
-		source["id"] = hitMap["_id"]
-		receivables[i] = source
-	}
+	codigoCliente, _ := params.Args["codigo_cliente"].(string)
+	dataInicio, _ := params.Args["data_inicio"].(string)
+	dataFim, _ := params.Args["data_fim"].(string)
 
-	return map[string]interface{}{
-		"total":       total,
-		"receivables": receivables,
-	}, nil
+	return r.store.PageReceivables(ctx, ReceivableQuery{
+		Filter: customerFilter(codigoCliente, dataInicio, dataFim),
+		Sort:   []SortField{{Field: "data_vencimento"}},
+	}, pageRequest(params))
 }
 
 // Resolver para contar recebíveis de um cliente
-func countReceivablesByCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+func (r *Resolvers) countReceivablesByCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	codigoCliente, _ := params.Args["codigo_cliente"].(string)
 
 	if codigoCliente == "" {
-		return nil, fmt.Errorf("codigo_cliente é obrigatório")
+		return nil, newAppError(ErrCodeInvalidArgument, "codigo_cliente é obrigatório")
 	}
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"term": map[string]interface{}{
-				"codigo_cliente": codigoCliente,
-			},
-		},
-	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
-	}
-
-	res, err := esClient.client.Count(
-		esClient.client.Count.WithContext(context.Background()),
-		esClient.client.Count.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Count.WithBody(&buf),
-	)
+	count, err := r.store.CountReceivables(ctx, codigoCliente)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
-	}
 
-	count := int(result["count"].(float64))
 	return map[string]interface{}{
 		"count": count,
 	}, nil
 }
 
 // Resolver para contar total de documentos no índice
-func getIndexCountResolver(params graphql.ResolveParams) (interface{}, error) {
-	res, err := esClient.client.Count(
-		esClient.client.Count.WithContext(context.Background()),
-		esClient.client.Count.WithIndex("ciclo_vida_recebivel"),
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
+func (r *Resolvers) getIndexCountResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+	count, err := r.store.CountReceivables(ctx, "")
+	if err != nil {
 		return nil, err
 	}
 
-	count := int(result["count"].(float64))
 	return map[string]interface{}{
 		"count": count,
 	}, nil
 }
 
 // Resolver para contar recebíveis agrupados por cliente
-func countReceivablesGroupByCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+func (r *Resolvers) countReceivablesGroupByCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	dataInicio, _ := params.Args["data_inicio"].(string)
 	dataFim, _ := params.Args["data_fim"].(string)
 
-	query := map[string]interface{}{
-		"size": 0,
-		"query": map[string]interface{}{
-			"range": map[string]interface{}{
-				"data_vencimento": map[string]interface{}{
-					"gte": dataInicio,
-					"lte": dataFim,
-				},
-			},
-		},
-		"aggs": map[string]interface{}{
-			"total_por_cliente": map[string]interface{}{
-				"terms": map[string]interface{}{
-					"field": "codigo_cliente",
-					"size":  100,
-				},
-			},
-		},
-	}
+	return r.balances.CountByCustomer(ctx, dataInicio, dataFim)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
+// Resolver para paginar os clientes com os totais de saldo de cada um
+func (r *Resolvers) balanceByCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	var filter ReceivableFilter
+	if value, ok := params.Args["filter"]; ok {
+		if err := decodeInput(value, &filter); err != nil {
+			return nil, err
+		}
 	}
 
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
+	return r.balances.BalanceByCustomer(ctx, filter, pageRequest(params))
+}
+
+// Resolver para buscar cliente com mais registros
+func (r *Resolvers) getTopCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	stats, err := r.store.GroupByCustomer(ctx, GroupByCustomerQuery{
+		Size: 1,
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
+	if len(stats) == 0 {
+		return nil, newAppError(ErrCodeNotFound, "nenhum cliente encontrado")
 	}
 
-	aggs := result["aggregations"].(map[string]interface{})["total_por_cliente"].(map[string]interface{})
-	buckets := aggs["buckets"].([]interface{})
+	return stats[0], nil
+}
+
+// Resolver do ranking de clientes por métrica
+func (r *Resolvers) topCustomersResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	stats := make([]map[string]interface{}, len(buckets))
-	for i, bucket := range buckets {
-		bucketMap := bucket.(map[string]interface{})
-		stats[i] = map[string]interface{}{
-			"codigo_cliente":   bucketMap["key"].(string),
-			"total_recebiveis": int(bucketMap["doc_count"].(float64)),
+	query := TopCustomersQuery{Metric: MetricCount}
+	query.N, _ = params.Args["n"].(int)
+	if metric, ok := params.Args["metric"].(TopCustomerMetric); ok {
+		query.Metric = metric
+	}
+	if value, ok := params.Args["filter"]; ok {
+		if err := decodeInput(value, &query.Filter); err != nil {
+			return nil, err
 		}
 	}
 
-	return stats, nil
+	return r.balances.TopCustomers(ctx, query)
 }
 
-// Resolver para buscar cliente com mais registros
-func getTopCustomerResolver(params graphql.ResolveParams) (interface{}, error) {
-	query := map[string]interface{}{
-		"size": 0,
-		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
-		},
-		"aggs": map[string]interface{}{
-			"clientes": map[string]interface{}{
-				"terms": map[string]interface{}{
-					"field": "codigo_cliente",
-					"size":  1,
-					"order": map[string]interface{}{
-						"_count": "desc",
-					},
-				},
-			},
-		},
+// minBalanceArg lê o argumento min_balance; saldo disponível é sempre positivo, então
+// recebíveis totalmente cancelados ou negociados (saldo zero) nunca entram no resultado
+func minBalanceArg(params graphql.ResolveParams) domain.Money {
+	minBalance, _ := params.Args["min_balance"].(float64)
+	if money := domain.MoneyFromFloat(minBalance); money > domain.Centavos(1) {
+		return money
 	}
+	return domain.Centavos(1)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
+// Resolver para buscar recebíveis com saldo disponível mínimo
+func (r *Resolvers) getReceivablesByBalanceAvailableResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	codigoCliente, _ := params.Args["codigo_cliente"].(string)
+	minBalance := minBalanceArg(params)
+	from, _ := params.Args["from"].(int)
+	size, _ := params.Args["size"].(int)
+
+	if size == 0 {
+		size = 50
 	}
 
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
+	return r.store.SearchReceivables(ctx, ReceivableQuery{
+		Filter: ReceivableFilter{
+			CodigoCliente:   []string{codigoCliente},
+			SaldoDisponivel: MoneyRange{Gte: &minBalance},
+		},
+		Sort: []SortField{{Field: "saldo_disponivel", Desc: true}},
+		From: from,
+		Size: size,
+	})
+}
+
+// Resolver paginado por cursor para recebíveis com saldo disponível mínimo
+func (r *Resolvers) getReceivablesByBalanceAvailableConnectionResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	codigoCliente, _ := params.Args["codigo_cliente"].(string)
+	minBalance := minBalanceArg(params)
+
+	return r.store.PageReceivables(ctx, ReceivableQuery{
+		Filter: ReceivableFilter{
+			CodigoCliente:   []string{codigoCliente},
+			SaldoDisponivel: MoneyRange{Gte: &minBalance},
+		},
+		Sort: []SortField{{Field: "saldo_disponivel", Desc: true}},
+	}, pageRequest(params))
+}
+
+// Resolver para buscar recebíveis por qualquer combinação de filtros, com ordenação e paginação por cursor
+func (r *Resolvers) searchReceivablesResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	query, page, err := receivableSearchArgs(params)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, err
+	return r.store.PageReceivables(ctx, query, page)
+}
+
+// receivableSearchArgs lê os argumentos filter, sort e page de uma busca de recebíveis
+func receivableSearchArgs(params graphql.ResolveParams) (ReceivableQuery, PageRequest, error) {
+	var filter ReceivableFilter
+	if value, ok := params.Args["filter"]; ok {
+		if err := decodeInput(value, &filter); err != nil {
+			return ReceivableQuery{}, PageRequest{}, err
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return ReceivableQuery{}, PageRequest{}, err
 	}
 
-	// Verificar se há agregações
-	if aggs, ok := result["aggregations"].(map[string]interface{}); ok {
-		if clientes, ok := aggs["clientes"].(map[string]interface{}); ok {
-			if buckets, ok := clientes["buckets"].([]interface{}); ok && len(buckets) > 0 {
-				bucket := buckets[0].(map[string]interface{})
-				return map[string]interface{}{
-					"codigo_cliente":   bucket["key"].(string),
-					"total_recebiveis": int(bucket["doc_count"].(float64)),
-				}, nil
-			}
+	var sortArgs []struct {
+		Field     string `json:"field"`
+		Direction string `json:"direction"`
+	}
+	if value, ok := params.Args["sort"]; ok {
+		if err := decodeInput(value, &sortArgs); err != nil {
+			return ReceivableQuery{}, PageRequest{}, err
 		}
 	}
+	sortFields := make([]SortField, len(sortArgs))
+	for i, s := range sortArgs {
+		sortFields[i] = SortField{Field: s.Field, Desc: s.Direction == "DESC"}
+	}
 
-	return nil, fmt.Errorf("nenhum cliente encontrado")
+	var page PageRequest
+	if value, ok := params.Args["page"].(map[string]interface{}); ok {
+		page.First, _ = value["first"].(int)
+		page.After, _ = value["after"].(string)
+	}
+	if page.First == 0 {
+		page.First = 50
+	}
+
+	return ReceivableQuery{Filter: filter, Sort: sortFields}, page, nil
 }
 
-// Resolver para buscar recebíveis com saldo disponível mínimo
-func getReceivablesByBalanceAvailableResolver(params graphql.ResolveParams) (interface{}, error) {
+// pageRequest lê os argumentos first/after de uma connection
+func pageRequest(params graphql.ResolveParams) PageRequest {
+	first, _ := params.Args["first"].(int)
+	after, _ := params.Args["after"].(string)
+	return PageRequest{First: first, After: after}
+}
+
+// Resolver para buscar saldo de um recebível específico
+func (r *Resolvers) getReceivableBalanceByIdResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	id, _ := params.Args["id"].(string)
+	idPagamento, _ := params.Args["id_pagamento"].(string)
+	asOf, _ := params.Args["as_of"].(string)
+
+	return r.balances.ReceivableBalance(ctx, id, idPagamento, asOf)
+}
+
+// Resolver para buscar um pagamento com seus recebíveis
+func (r *Resolvers) getPaymentByIdResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	idPagamento, _ := params.Args["id_pagamento"].(string)
+
+	return r.balances.Payment(ctx, idPagamento)
+}
+
+// Resolver do campo Receivable.payment
+func (r *Resolvers) receivablePaymentResolver(params graphql.ResolveParams) (interface{}, error) {
+	recebivel, ok := params.Source.(*domain.Recebivel)
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	return r.balances.Payment(ctx, recebivel.IDPagamento)
+}
+
+// Resolver da série de saldo de um cliente
+func (r *Resolvers) customerBalanceSeriesResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
 	codigoCliente, _ := params.Args["codigo_cliente"].(string)
-	minBalance, _ := params.Args["min_balance"].(float64)
-	from, _ := params.Args["from"].(int)
-	size, _ := params.Args["size"].(int)
+	from, _ := params.Args["from"].(string)
+	to, _ := params.Args["to"].(string)
+	interval, _ := params.Args["interval"].(SeriesInterval)
+	if interval == "" {
+		interval = IntervalMonth
+	}
+
+	return r.balances.BalanceSeries(ctx, BalanceSeriesQuery{
+		CodigoCliente: codigoCliente,
+		DataInicio:    from,
+		DataFim:       to,
+		Intervalo:     interval,
+	})
+}
 
-	if size == 0 {
-		size = 50
+// Resolver do breakdown de recebíveis por dimensões
+func (r *Resolvers) receivableBreakdownResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	var query BreakdownQuery
+	if value, ok := params.Args["filter"]; ok {
+		if err := decodeInput(value, &query.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if err := decodeInput(params.Args["dimensions"], &query.Dimensions); err != nil {
+		return nil, err
 	}
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"codigo_cliente": codigoCliente,
-						},
-					},
-					{
-						"range": map[string]interface{}{
-							"valor_original": map[string]interface{}{
-								"gte": minBalance,
-							},
-						},
-					},
-				},
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"valor_original": map[string]interface{}{
-					"order": "desc",
-				},
-			},
-		},
-		"from":    from,
-		"size":    size,
-		"_source": []string{"id_recebivel", "codigo_cliente", "valor_original", "data_vencimento", "cancelamentos", "negociacoes"},
+	return r.balances.Breakdown(ctx, query)
+}
+
+// Resolver do relatório de aging de um cliente
+func (r *Resolvers) agingReportResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	codigoCliente, _ := params.Args["codigo_cliente"].(string)
+	referenceDate, _ := params.Args["reference_date"].(string)
+	if codigoCliente == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "codigo_cliente é obrigatório")
 	}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
+	return r.balances.AgingReport(ctx, AgingQuery{
+		CodigoCliente:  codigoCliente,
+		DataReferencia: referenceDate,
+	})
+}
+
+// Resolver do relatório de aging da carteira inteira
+func (r *Resolvers) portfolioAgingReportResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	referenceDate, _ := params.Args["reference_date"].(string)
+
+	return r.balances.AgingReport(ctx, AgingQuery{DataReferencia: referenceDate})
+}
+
+// Customer é a raiz da visão de um cliente; cada sub-campo consulta o store só quando selecionado
+type Customer struct {
+	CodigoCliente string `json:"codigo_cliente"`
+}
+
+// Resolver para a visão de um cliente; não consulta o store, os sub-campos é que consultam
+func (r *Resolvers) customerResolver(params graphql.ResolveParams) (interface{}, error) {
+	codigoCliente, _ := params.Args["codigo_cliente"].(string)
+	if codigoCliente == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "codigo_cliente é obrigatório")
 	}
+	return &Customer{CodigoCliente: codigoCliente}, nil
+}
+
+// Resolver do campo Customer.receivables: a busca de searchReceivables restrita ao cliente
+func (r *Resolvers) customerReceivablesResolver(params graphql.ResolveParams) (interface{}, error) {
+	customer, _ := params.Source.(*Customer)
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
+	query, page, err := receivableSearchArgs(params)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
+	query.Filter.CodigoCliente = []string{customer.CodigoCliente}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+	return r.store.PageReceivables(ctx, query, page)
+}
+
+// Resolver do campo Customer.balance
+func (r *Resolvers) customerBalanceResolver(params graphql.ResolveParams) (interface{}, error) {
+	customer, _ := params.Source.(*Customer)
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	var period Periodo
+	if value, ok := params.Args["period"]; ok {
+		if err := decodeInput(value, &period); err != nil {
+			return nil, err
+		}
+	}
+	asOf, _ := params.Args["as_of"].(string)
+
+	return r.balances.CustomerBalance(ctx, BalanceQuery{
+		CodigoCliente: customer.CodigoCliente,
+		DataInicio:    period.Inicio,
+		DataFim:       period.Fim,
+		AsOf:          asOf,
+	})
+}
+
+// Resolver do campo Customer.stats
+func (r *Resolvers) customerStatsResolver(params graphql.ResolveParams) (interface{}, error) {
+	customer, _ := params.Source.(*Customer)
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	count, err := r.store.CountReceivables(ctx, customer.CodigoCliente)
+	if err != nil {
 		return nil, err
 	}
+	return CustomerStats{CodigoCliente: customer.CodigoCliente, TotalRecebiveis: count}, nil
+}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	total := int(result["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+// Resolver do campo Customer.payments: cada pagamento é lido do seu shard por BalanceEngine.Payment
+func (r *Resolvers) customerPaymentsResolver(params graphql.ResolveParams) (interface{}, error) {
+	customer, _ := params.Source.(*Customer)
+	ctx, cancel := r.context(params)
+	defer cancel()
 
-	receivables := make([]map[string]interface{}, len(hits))
-	for i, hit := range hits {
-		hitMap := hit.(map[string]interface{})
-		source := hitMap["_source"].(map[string]interface{})
-		source["id"] = hitMap["_id"]
-		receivables[i] = source
+	first, _ := params.Args["first"].(int)
+	if first <= 0 || first > maxPageSize {
+		return nil, newAppError(ErrCodeInvalidArgument, "first deve estar entre 1 e %d", maxPageSize)
 	}
 
-	return map[string]interface{}{
-		"total":       total,
-		"receivables": receivables,
-	}, nil
-}
+	ids, err := r.store.PaymentIDs(ctx, customer.CodigoCliente, first)
+	if err != nil {
+		return nil, err
+	}
 
-// Resolver para buscar saldo de um recebível específico
-func getReceivableBalanceByIdResolver(params graphql.ResolveParams) (interface{}, error) {
-	id, ok := params.Args["id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("id é obrigatório")
+	pagamentos := make([]*Pagamento, len(ids))
+	for i, id := range ids {
+		if pagamentos[i], err = r.balances.Payment(ctx, id); err != nil {
+			return nil, err
+		}
 	}
+	return pagamentos, nil
+}
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"term": map[string]interface{}{
-				"_id": id,
-			},
-		},
-		"size": 1,
-		"script_fields": map[string]interface{}{
-			"saldo_calculado": map[string]interface{}{
-				"script": map[string]interface{}{
-					"lang":   "painless",
-					"source": "double valor = params._source.valor_original; double cancelado = 0.0; double negociado = 0.0; if (params._source.cancelamentos != null) { for (def c : params._source.cancelamentos) { cancelado += c.valor_cancelado; } } if (params._source.negociacoes != null) { for (def n : params._source.negociacoes) { negociado += n.valor_negociado; } } return Math.round((valor - cancelado - negociado) * 100.0) / 100.0;",
-				},
-			},
-		},
-		"_source": []string{"id_recebivel", "codigo_cliente", "valor_original", "data_vencimento", "cancelamentos", "negociacoes"},
+// Resolver do campo Customer.agingBuckets
+func (r *Resolvers) customerAgingResolver(params graphql.ResolveParams) (interface{}, error) {
+	customer, _ := params.Source.(*Customer)
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	referenceDate, _ := params.Args["reference_date"].(string)
+
+	return r.balances.Aging(ctx, AgingQuery{
+		CodigoCliente:  customer.CodigoCliente,
+		DataReferencia: referenceDate,
+	})
+}
+
+// Mutation para registrar um recebível novo
+func (r *Resolvers) registerReceivableResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	var recebivel domain.Recebivel
+	if err := decodeInput(params.Args["input"], &recebivel); err != nil {
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+	return r.lifecycle.Register(ctx, recebivel)
+}
+
+// Mutation para acrescentar um cancelamento a um recebível
+func (r *Resolvers) addCancelamentoResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	id, _ := params.Args["id"].(string)
+	idPagamento, _ := params.Args["id_pagamento"].(string)
+	var cancelamento domain.Cancelamento
+	if err := decodeInput(params.Args["cancelamento"], &cancelamento); err != nil {
 		return nil, err
 	}
 
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
+	versao, err := versionArgs(params.Args)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+	return r.lifecycle.AddCancelamento(ctx, id, idPagamento, versao, cancelamento)
+}
+
+// Mutation para acrescentar uma negociação a um recebível
+func (r *Resolvers) addNegociacaoResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	id, _ := params.Args["id"].(string)
+	idPagamento, _ := params.Args["id_pagamento"].(string)
+	var negociacao domain.Negociacao
+	if err := decodeInput(params.Args["negociacao"], &negociacao); err != nil {
 		return nil, err
 	}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	if len(hits) == 0 {
-		return nil, fmt.Errorf("recebível não encontrado")
+	versao, err := versionArgs(params.Args)
+	if err != nil {
+		return nil, err
 	}
 
-	hit := hits[0].(map[string]interface{})
-	source := hit["_source"].(map[string]interface{})
-	source["id"] = hit["_id"]
+	return r.lifecycle.AddNegociacao(ctx, id, idPagamento, versao, negociacao)
+}
 
-	if fields, ok := hit["fields"].(map[string]interface{}); ok {
-		if saldoArray, ok := fields["saldo_calculado"].([]interface{}); ok && len(saldoArray) > 0 {
-			source["saldo_disponivel"] = saldoArray[0].(float64)
-		}
+// Mutation para remover um cancelamento de um recebível
+func (r *Resolvers) removeCancelamentoResolver(params graphql.ResolveParams) (interface{}, error) {
+	ctx, cancel := r.context(params)
+	defer cancel()
+
+	id, _ := params.Args["id"].(string)
+	idPagamento, _ := params.Args["id_pagamento"].(string)
+	idCancelamento, _ := params.Args["id_cancelamento"].(string)
+	if idCancelamento == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "id_cancelamento é obrigatório")
+	}
+
+	versao, err := versionArgs(params.Args)
+	if err != nil {
+		return nil, err
 	}
 
-	return source, nil
+	return r.lifecycle.RemoveCancelamento(ctx, id, idPagamento, versao, idCancelamento)
+}
+
+// decodeInput converte um input object do GraphQL no struct do domínio pelo formato JSON
+// do documento, para os valores monetários passarem pelo mesmo parse exato do índice
+func decodeInput(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return wrapAppError(ErrCodeInvalidArgument, err, "input inválido")
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return wrapAppError(ErrCodeInvalidArgument, err, "input inválido")
+	}
+	return nil
 }
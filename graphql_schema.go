@@ -1,85 +1,568 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"data-aggregator/domain"
 	"github.com/graphql-go/graphql"
 )
 
 // GraphQL Types
-var receivableType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Receivable",
-	Fields: graphql.Fields{
-		"id": &graphql.Field{
-			Type: graphql.String,
+
+// receivableType é derivado de domain.Recebivel, para o schema acompanhar o documento armazenado.
+// Além dos campos persistidos expõe o _id do documento, o saldo calculado e a versão lida
+// do índice (seq_no/primary_term), que as mutations aceitam como if_seq_no/if_primary_term.
+var receivableType = objectFromModel("Receivable", reflect.TypeOf(domain.Recebivel{}), graphql.Fields{
+	"id": &graphql.Field{
+		Type: graphql.String,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if recebivel, ok := p.Source.(*domain.Recebivel); ok {
+				return recebivel.DocumentID(), nil
+			}
+			return nil, nil
 		},
-		"id_recebivel": &graphql.Field{
-			Type: graphql.String,
+	},
+	"seq_no": &graphql.Field{
+		Type:        graphql.Int,
+		Description: "_seq_no do documento quando o recebível é lido por id (getReceivableById e mutations)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if recebivel, ok := p.Source.(*domain.Recebivel); ok && recebivel.Versao != nil {
+				return recebivel.Versao.SeqNo, nil
+			}
+			return nil, nil
 		},
+	},
+	"primary_term": &graphql.Field{
+		Type:        graphql.Int,
+		Description: "_primary_term do documento quando o recebível é lido por id (getReceivableById e mutations)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if recebivel, ok := p.Source.(*domain.Recebivel); ok && recebivel.Versao != nil {
+				return recebivel.Versao.PrimaryTerm, nil
+			}
+			return nil, nil
+		},
+	},
+	"saldo_disponivel": &graphql.Field{
+		Type:        graphql.Float,
+		Description: "valor_original - soma(valor_cancelado) - soma(valor_negociado)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if recebivel, ok := p.Source.(*domain.Recebivel); ok {
+				return recebivel.Saldo().Float64(), nil
+			}
+			return nil, nil
+		},
+	},
+})
+
+// moneyType é o tipo Go dos valores monetários do domínio
+var moneyType = reflect.TypeOf(domain.Money(0))
+
+// objectFromModel monta um tipo GraphQL com os campos persistidos de um struct do domínio.
+// Slices de structs viram listas de objetos nomeados pelo tipo Go (Cancelamento, Negociacao)
+// e campos Money são expostos em reais como Float.
+func objectFromModel(name string, t reflect.Type, extra graphql.Fields) *graphql.Object {
+	fields := graphql.Fields{}
+	for _, field := range domain.Fields(t) {
+		if field.Type == moneyType {
+			fields[field.JSONName] = moneyField("")
+			continue
+		}
+		fields[field.JSONName] = &graphql.Field{Type: outputTypeFromModel(field.Type)}
+	}
+	for fieldName, field := range extra {
+		fields[fieldName] = field
+	}
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:   name,
+		Fields: fields,
+	})
+}
+
+// moneyField cria um campo Float para valores domain.Money, expostos em reais
+func moneyField(description string) *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.Float,
+		Description: description,
+		Resolve:     resolveMoney,
+	}
+}
+
+// resolveMoney resolve o campo pelo resolver padrão e converte domain.Money para reais
+func resolveMoney(p graphql.ResolveParams) (interface{}, error) {
+	valor, err := graphql.DefaultResolveFn(p)
+	if money, ok := valor.(domain.Money); ok {
+		return money.Float64(), err
+	}
+	return valor, err
+}
+
+// outputTypeFromModel converte o tipo Go de um campo do domínio no tipo GraphQL correspondente
+func outputTypeFromModel(t reflect.Type) graphql.Output {
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.String
+	case reflect.Int, reflect.Int64:
+		return graphql.Int
+	case reflect.Float64:
+		return graphql.Float
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return graphql.NewList(objectFromModel(t.Elem().Name(), t.Elem(), nil))
+		}
+		return graphql.NewList(outputTypeFromModel(t.Elem()))
+	}
+	panic(fmt.Sprintf("tipo %s sem equivalente GraphQL", t))
+}
+
+// inputTypes guarda os input objects já criados, para cada nome existir uma única vez no schema
+var inputTypes = map[string]*graphql.InputObject{}
+
+// inputFromModel monta um input object com os campos persistidos de um struct do domínio.
+// Campos Money são recebidos em reais como Float e slices de structs viram listas de inputs;
+// campos calculados (como saldo_disponivel) ficam de fora.
+func inputFromModel(name string, t reflect.Type) *graphql.InputObject {
+	if input, ok := inputTypes[name]; ok {
+		return input
+	}
+
+	fields := graphql.InputObjectConfigFieldMap{}
+	for _, field := range domain.Fields(t) {
+		if field.Computed {
+			continue
+		}
+		fields[field.JSONName] = &graphql.InputObjectFieldConfig{Type: inputTypeFromModel(field.Type)}
+	}
+
+	input := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   name,
+		Fields: fields,
+	})
+	inputTypes[name] = input
+	return input
+}
+
+// inputTypeFromModel converte o tipo Go de um campo do domínio no tipo GraphQL de entrada
+func inputTypeFromModel(t reflect.Type) graphql.Input {
+	if t == moneyType {
+		return graphql.Float
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.String
+	case reflect.Int, reflect.Int64:
+		return graphql.Int
+	case reflect.Float64:
+		return graphql.Float
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return graphql.NewList(inputFromModel(t.Elem().Name()+"Input", t.Elem()))
+		}
+		return graphql.NewList(inputTypeFromModel(t.Elem()))
+	}
+	panic(fmt.Sprintf("tipo %s sem equivalente GraphQL", t))
+}
+
+var (
+	receivableInputType   = inputFromModel("ReceivableInput", reflect.TypeOf(domain.Recebivel{}))
+	cancelamentoInputType = inputFromModel("CancelamentoInput", reflect.TypeOf(domain.Cancelamento{}))
+	negociacaoInputType   = inputFromModel("NegociacaoInput", reflect.TypeOf(domain.Negociacao{}))
+)
+
+var periodType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Period",
+	Fields: graphql.Fields{
+		"inicio": &graphql.Field{Type: graphql.String},
+		"fim":    &graphql.Field{Type: graphql.String},
+	},
+})
+
+var balanceType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Balance",
+	Fields: graphql.Fields{
 		"codigo_cliente": &graphql.Field{
 			Type: graphql.String,
 		},
-		"valor_original": &graphql.Field{
-			Type: graphql.Float,
+		"periodo": &graphql.Field{
+			Type: periodType,
+		},
+		"as_of": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Data de reconstrução do saldo, quando pedida",
+		},
+		"total_recebiveis": &graphql.Field{
+			Type: graphql.Int,
 		},
-		"data_vencimento": &graphql.Field{
+		"valor_original":  moneyField("Soma de valor_original"),
+		"total_cancelado": moneyField("Soma de cancelamentos.valor_cancelado"),
+		"total_negociado": moneyField("Soma de negociacoes.valor_negociado"),
+		"saldo_total":     moneyField("valor_original - total_cancelado - total_negociado"),
+		"saldo_formatado": &graphql.Field{
 			Type: graphql.String,
 		},
+	},
+})
+
+// paymentType agrupa os recebíveis de um id_pagamento. O campo Receivable.payment,
+// que aponta de volta para ele, é acrescentado em initGraphQLSchema junto com o resolver.
+var paymentType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Pagamento",
+	Description: "Recebíveis de um id_pagamento, lidos do shard do pagamento, com os valores somados",
+	Fields: graphql.Fields{
 		"id_pagamento": &graphql.Field{
 			Type: graphql.String,
 		},
-		"saldo_disponivel": &graphql.Field{
-			Type: graphql.Float,
+		"total_recebiveis": &graphql.Field{
+			Type: graphql.Int,
 		},
-		"cancelamentos": &graphql.Field{
-			Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
-				Name: "Cancelamento",
-				Fields: graphql.Fields{
-					"data_cancelamento": &graphql.Field{Type: graphql.String},
-					"valor_cancelado":   &graphql.Field{Type: graphql.Float},
-					"motivo":            &graphql.Field{Type: graphql.String},
-				},
-			})),
-		},
-		"negociacoes": &graphql.Field{
-			Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
-				Name: "Negociacao",
-				Fields: graphql.Fields{
-					"data_negociacao": &graphql.Field{Type: graphql.String},
-					"valor_negociado": &graphql.Field{Type: graphql.Float},
-					"tipo_negociacao": &graphql.Field{Type: graphql.String},
-					"observacao":      &graphql.Field{Type: graphql.String},
-				},
-			})),
+		"valor_original":   moneyField("Soma de valor_original"),
+		"total_cancelado":  moneyField("Soma de cancelamentos.valor_cancelado"),
+		"total_negociado":  moneyField("Soma de negociacoes.valor_negociado"),
+		"saldo_disponivel": moneyField("valor_original - total_cancelado - total_negociado"),
+		"receivables": &graphql.Field{
+			Type: graphql.NewList(receivableType),
 		},
 	},
 })
 
-var balanceType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Balance",
+// balanceTotalsType expõe as somas de saldo de um grupo de recebíveis (BalanceTotals)
+var balanceTotalsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "BalanceTotals",
+	Fields: graphql.Fields{
+		"total_recebiveis": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"valor_original":  moneyField("Soma de valor_original"),
+		"total_cancelado": moneyField("Soma de cancelamentos.valor_cancelado"),
+		"total_negociado": moneyField("Soma de negociacoes.valor_negociado"),
+		"saldo":           moneyField("valor_original - total_cancelado - total_negociado"),
+	},
+})
+
+var agingBucketType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "AgingBucket",
+	Description: "Faixa de atraso do vencimento em relação à data de referência",
+	Fields: graphql.Fields{
+		"faixa": &graphql.Field{
+			Type:        graphql.String,
+			Description: "a_vencer, vencido_1_30, vencido_31_60, vencido_61_90 ou vencido_90_mais",
+		},
+		"descricao": &graphql.Field{
+			Type: graphql.String,
+		},
+		"data_de": &graphql.Field{
+			Type:        graphql.String,
+			Description: "data_vencimento inicial da faixa (inclusiva); vazia se aberta",
+		},
+		"data_ate": &graphql.Field{
+			Type:        graphql.String,
+			Description: "data_vencimento final da faixa (inclusiva); vazia se aberta",
+		},
+		"totais": &graphql.Field{
+			Type: balanceTotalsType,
+		},
+	},
+})
+
+var agingReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "AgingReport",
+	Description: "Aging por faixa de atraso, de um cliente ou da carteira inteira",
 	Fields: graphql.Fields{
 		"codigo_cliente": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Cliente do relatório; nulo no relatório da carteira",
+		},
+		"data_referencia": &graphql.Field{
 			Type: graphql.String,
 		},
+		"faixas": &graphql.Field{
+			Type: graphql.NewList(agingBucketType),
+		},
+		"totais": &graphql.Field{
+			Type:        balanceTotalsType,
+			Description: "Soma de todas as faixas",
+		},
+	},
+})
+
+var seriesIntervalEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "SeriesInterval",
+	Description: "Largura de cada intervalo da série (semanas começam na segunda-feira)",
+	Values: graphql.EnumValueConfigMap{
+		"DAY":   &graphql.EnumValueConfig{Value: IntervalDay},
+		"WEEK":  &graphql.EnumValueConfig{Value: IntervalWeek},
+		"MONTH": &graphql.EnumValueConfig{Value: IntervalMonth},
+	},
+})
+
+var balanceSeriesBucketType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "BalanceSeriesBucket",
+	Description: "Intervalo da série de saldo com os totais dos recebíveis que vencem nele",
+	Fields: graphql.Fields{
 		"periodo": &graphql.Field{
-			Type: graphql.NewObject(graphql.ObjectConfig{
-				Name: "Period",
-				Fields: graphql.Fields{
-					"inicio": &graphql.Field{Type: graphql.String},
-					"fim":    &graphql.Field{Type: graphql.String},
-				},
-			}),
+			Type:        periodType,
+			Description: "Primeiro e último dia do intervalo",
 		},
-		"total_recebiveis": &graphql.Field{
-			Type: graphql.Int,
+		"totais": &graphql.Field{
+			Type: balanceTotalsType,
 		},
-		"saldo_total": &graphql.Field{
-			Type: graphql.Float,
+	},
+})
+
+// dimensionEnum lista os campos aceitos em receivableBreakdown (breakdownDimensions)
+var dimensionEnum = func() *graphql.Enum {
+	values := graphql.EnumValueConfigMap{}
+	for _, dimension := range breakdownDimensions {
+		values[strings.ToUpper(dimension)] = &graphql.EnumValueConfig{Value: dimension}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{
+		Name:   "Dimension",
+		Values: values,
+	})
+}()
+
+var dimensionValueType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DimensionValue",
+	Fields: graphql.Fields{
+		"dimensao": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Campo do documento (modalidade, codigo_produto...)",
 		},
-		"saldo_formatado": &graphql.Field{
+		"valor": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+var breakdownGroupType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "BreakdownGroup",
+	Description: "Combinação de valores das dimensões com os totais dos seus recebíveis",
+	Fields: graphql.Fields{
+		"chaves": &graphql.Field{
+			Type:        graphql.NewList(dimensionValueType),
+			Description: "Um valor por dimensão, na ordem pedida",
+		},
+		"totais": &graphql.Field{
+			Type: balanceTotalsType,
+		},
+	},
+})
+
+var customerGroupType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "CustomerGroup",
+	Description: "Cliente com as somas de saldo dos seus recebíveis no filtro",
+	Fields: graphql.Fields{
+		"codigo_cliente": &graphql.Field{
+			Type: graphql.String,
+		},
+		"totais": &graphql.Field{
+			Type: balanceTotalsType,
+		},
+	},
+})
+
+var topCustomerMetricEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "TopCustomerMetric",
+	Description: "Métrica que ordena o ranking de clientes",
+	Values: graphql.EnumValueConfigMap{
+		"COUNT":          &graphql.EnumValueConfig{Value: MetricCount, Description: "Quantidade de recebíveis"},
+		"ORIGINAL_VALUE": &graphql.EnumValueConfig{Value: MetricOriginalValue, Description: "Soma de valor_original"},
+		"BALANCE":        &graphql.EnumValueConfig{Value: MetricBalance, Description: "Saldo: valor original - cancelado - negociado"},
+		"CANCELLED":      &graphql.EnumValueConfig{Value: MetricCancelled, Description: "Soma dos cancelamentos"},
+		"NEGOTIATED":     &graphql.EnumValueConfig{Value: MetricNegotiated, Description: "Soma das negociações"},
+	},
+})
+
+var customerGroupEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CustomerGroupEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"node": &graphql.Field{
+			Type: customerGroupType,
+		},
+	},
+})
+
+// customerGroupConnectionType pagina os clientes em ordem de codigo_cliente; o cursor
+// é o after_key da composite aggregation
+var customerGroupConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CustomerGroupConnection",
+	Fields: graphql.Fields{
+		"edges": &graphql.Field{
+			Type: graphql.NewList(customerGroupEdgeType),
+		},
+		"pageInfo": &graphql.Field{
+			Type: graphql.NewNonNull(pageInfoType),
+		},
+	},
+})
+
+// auditSnapshotField expõe um snapshot da auditoria como o JSON do documento, em texto
+func auditSnapshotField(description string, snapshot func(*domain.RegistroAuditoria) json.RawMessage) *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.String,
+		Description: description,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if registro, ok := p.Source.(*domain.RegistroAuditoria); ok && len(snapshot(registro)) > 0 {
+				return string(snapshot(registro)), nil
+			}
+			return nil, nil
+		},
+	}
+}
+
+var auditEntryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "AuditEntry",
+	Description: "Escrita registrada no índice de auditoria",
+	Fields: graphql.Fields{
+		"id_registro": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "UUIDv7 do registro; a ordem dos ids é a ordem das escritas",
+		},
+		"timestamp": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ator": &graphql.Field{
+			Type: graphql.String,
+		},
+		"origem": &graphql.Field{
+			Type:        graphql.String,
+			Description: "query, graphql, bulk, ingest-events ou seeder",
+		},
+		"operacao": &graphql.Field{
+			Type:        graphql.String,
+			Description: "create_index, create, index, update ou delete",
+		},
+		"indice": &graphql.Field{
+			Type: graphql.String,
+		},
+		"documento_id": &graphql.Field{
 			Type: graphql.String,
 		},
+		"routing": &graphql.Field{
+			Type: graphql.String,
+		},
+		"antes": auditSnapshotField("Documento antes da escrita, em JSON; nulo em documentos novos e nas cargas em lote",
+			func(registro *domain.RegistroAuditoria) json.RawMessage { return registro.Antes }),
+		"depois": auditSnapshotField("Documento depois da escrita, em JSON; nulo no delete",
+			func(registro *domain.RegistroAuditoria) json.RawMessage { return registro.Depois }),
+	},
+})
+
+var auditEntryEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEntryEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"node": &graphql.Field{
+			Type: auditEntryType,
+		},
+	},
+})
+
+// auditEntryConnectionType pagina a trilha de auditoria em ordem de escrita; o cursor é
+// o id_registro do último registro da página
+var auditEntryConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEntryConnection",
+	Fields: graphql.Fields{
+		"edges": &graphql.Field{
+			Type: graphql.NewList(auditEntryEdgeType),
+		},
+		"pageInfo": &graphql.Field{
+			Type: graphql.NewNonNull(pageInfoType),
+		},
+	},
+})
+
+var periodInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "PeriodInput",
+	Description: "Intervalo inclusivo de data_vencimento (yyyy-MM-dd); pontas omitidas ficam abertas",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"inicio": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"fim":    &graphql.InputObjectFieldConfig{Type: graphql.String},
 	},
 })
 
+// customerObject monta o tipo Customer. Cada sub-campo tem seu próprio resolver, então
+// uma consulta só paga pelas buscas dos campos que seleciona.
+func customerObject(r *Resolvers) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Customer",
+		Description: "Visão consolidada de um cliente",
+		Fields: graphql.Fields{
+			"codigo_cliente": &graphql.Field{
+				Type: graphql.String,
+			},
+			"receivables": &graphql.Field{
+				Type:        receivableConnectionType,
+				Description: "Recebíveis do cliente, com os mesmos filtros, ordenação e cursor de searchReceivables",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type: receivableFilterInputType,
+					},
+					"sort": &graphql.ArgumentConfig{
+						Type: graphql.NewList(graphql.NewNonNull(receivableSortInputType)),
+					},
+					"page": &graphql.ArgumentConfig{
+						Type: pageInputType,
+					},
+				},
+				Resolve: r.customerReceivablesResolver,
+			},
+			"balance": &graphql.Field{
+				Type:        balanceType,
+				Description: "Saldo do cliente no período de vencimento (sem período, toda a carteira)",
+				Args: graphql.FieldConfigArgument{
+					"period": &graphql.ArgumentConfig{
+						Type: periodInputType,
+					},
+					"as_of": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Reconstrói o saldo nesta data (yyyy-MM-dd): só eventos datados até ela são descontados",
+					},
+				},
+				Resolve: r.customerBalanceResolver,
+			},
+			"stats": &graphql.Field{
+				Type:    customerStatsType,
+				Resolve: r.customerStatsResolver,
+			},
+			"payments": &graphql.Field{
+				Type:        graphql.NewList(paymentType),
+				Description: "Pagamentos com recebíveis do cliente, por id_pagamento; os totais são do pagamento inteiro",
+				Args: graphql.FieldConfigArgument{
+					"first": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 50,
+					},
+				},
+				Resolve: r.customerPaymentsResolver,
+			},
+			"agingBuckets": &graphql.Field{
+				Type:        graphql.NewList(agingBucketType),
+				Description: "Aging dos recebíveis do cliente por faixa de atraso",
+				Args: graphql.FieldConfigArgument{
+					"reference_date": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Data a partir da qual o atraso é contado (yyyy-MM-dd); padrão: hoje",
+					},
+				},
+				Resolve: r.customerAgingResolver,
+			},
+		},
+	})
+}
+
 var customerStatsType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "CustomerStats",
 	Fields: graphql.Fields{
@@ -112,3 +595,159 @@ var countResultType = graphql.NewObject(graphql.ObjectConfig{
 		},
 	},
 })
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+		},
+		"hasPreviousPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+		},
+		"startCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+		"endCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+var receivableEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReceivableEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"node": &graphql.Field{
+			Type: receivableType,
+		},
+	},
+})
+
+// receivableConnectionType segue a especificação de connections do Relay; os cursores
+// são opacos e valem apenas para a mesma consulta (mesmos filtros e ordenação)
+var receivableConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReceivableConnection",
+	Fields: graphql.Fields{
+		"totalCount": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(receivableEdgeType),
+		},
+		"pageInfo": &graphql.Field{
+			Type: graphql.NewNonNull(pageInfoType),
+		},
+	},
+})
+
+// connectionArgs acrescenta os argumentos de paginação por cursor (first/after) aos informados
+func connectionArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["first"] = &graphql.ArgumentConfig{
+		Type:         graphql.Int,
+		DefaultValue: 50,
+	}
+	args["after"] = &graphql.ArgumentConfig{
+		Type: graphql.String,
+	}
+	return args
+}
+
+var dateRangeInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "DateRangeInput",
+	Description: "Intervalo inclusivo de datas yyyy-MM-dd; pontas omitidas ficam abertas",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"gte": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"lte": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var moneyRangeInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "MoneyRangeInput",
+	Description: "Intervalo inclusivo de valores em reais; pontas omitidas ficam abertas",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"gte": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"lte": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+	},
+})
+
+// receivableFilterInputType espelha ReceivableFilter; todos os campos são opcionais e combinados com E
+var receivableFilterInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReceivableFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"codigo_cliente": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Qualquer um dos clientes informados",
+		},
+		"id_pagamento":            &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"codigo_produto":          &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"codigo_produto_parceiro": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"modalidade":              &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"data_vencimento":         &graphql.InputObjectFieldConfig{Type: dateRangeInputType},
+		"data_cancelamento": &graphql.InputObjectFieldConfig{
+			Type:        dateRangeInputType,
+			Description: "Algum cancelamento com data no intervalo",
+		},
+		"data_negociacao": &graphql.InputObjectFieldConfig{
+			Type:        dateRangeInputType,
+			Description: "Alguma negociação com data no intervalo",
+		},
+		"valor_original":   &graphql.InputObjectFieldConfig{Type: moneyRangeInputType},
+		"saldo_disponivel": &graphql.InputObjectFieldConfig{Type: moneyRangeInputType},
+		"has_cancelamento": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"has_negociacao":   &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+	},
+})
+
+// receivableSortFieldEnum lista os campos ordenáveis: os campos de primeiro nível do documento,
+// exceto os nested e os de texto livre
+var receivableSortFieldEnum = func() *graphql.Enum {
+	values := graphql.EnumValueConfigMap{}
+	for _, field := range domain.Fields(reflect.TypeOf(domain.Recebivel{})) {
+		if field.ESType == "nested" || field.ESType == "text" {
+			continue
+		}
+		values[strings.ToUpper(field.JSONName)] = &graphql.EnumValueConfig{Value: field.JSONName}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{
+		Name:   "ReceivableSortField",
+		Values: values,
+	})
+}()
+
+var sortDirectionEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortDirection",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: "ASC"},
+		"DESC": &graphql.EnumValueConfig{Value: "DESC"},
+	},
+})
+
+var receivableSortInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReceivableSort",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(receivableSortFieldEnum),
+		},
+		"direction": &graphql.InputObjectFieldConfig{
+			Type:         sortDirectionEnum,
+			DefaultValue: "ASC",
+		},
+	},
+})
+
+var pageInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "PageInput",
+	Description: "Página por cursor: first itens depois do cursor after (endCursor da página anterior)",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"first": &graphql.InputObjectFieldConfig{
+			Type:         graphql.Int,
+			DefaultValue: 50,
+		},
+		"after": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+	},
+})
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"data-aggregator/domain"
+)
+
+// checkpointInterval é a cada quantos eventos consumidos o offset é gravado no checkpoint,
+// além do fim de cada arquivo
+const checkpointInterval = 100
+
+// ingestCheckpoint é a posição do consumidor: o arquivo e o offset em bytes da próxima
+// linha a consumir. Na fila, os arquivos com nome anterior a Arquivo já foram consumidos.
+type ingestCheckpoint struct {
+	Arquivo      string `json:"arquivo"`
+	Offset       int64  `json:"offset"`
+	AtualizadoEm string `json:"atualizado_em"`
+}
+
+// loadCheckpoint lê o checkpoint; sem arquivo o consumo começa do início
+func loadCheckpoint(path string) (ingestCheckpoint, error) {
+	var cp ingestCheckpoint
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("checkpoint %s inválido: %w", path, err)
+	}
+	return cp, nil
+}
+
+// saveCheckpoint grava o checkpoint num arquivo temporário e o renomeia, para uma queda no
+// meio da escrita não deixar um checkpoint truncado
+func saveCheckpoint(path, arquivo string, offset int64) error {
+	data, err := json.Marshal(ingestCheckpoint{
+		Arquivo:      arquivo,
+		Offset:       offset,
+		AtualizadoEm: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// eventIngestor aplica os eventos lidos pelo ReceivableLifecycle e conta os resultados
+type eventIngestor struct {
+	lifecycle  *ReceivableLifecycle
+	dl         *deadLetter
+	lote       string
+	aplicados  int
+	duplicados int
+	rejeitados int
+}
+
+// apply aplica o evento de uma linha. Eventos inválidos ou de recebíveis inexistentes vão
+// para o dead-letter e o consumo segue; qualquer outro erro interrompe o consumo.
+func (ei *eventIngestor) apply(ctx context.Context, origem string, offset int64, raw []byte) error {
+	linha := bytes.TrimSpace(raw)
+	if len(linha) == 0 {
+		return nil
+	}
+
+	var evento LifecycleEvent
+	aplicado := false
+	err := json.Unmarshal(linha, &evento)
+	if err != nil {
+		err = wrapAppError(ErrCodeInvalidArgument, err, "JSON inválido")
+	} else {
+		aplicado, err = ei.lifecycle.ApplyEvent(ctx, evento)
+	}
+
+	switch {
+	case err == nil && aplicado:
+		ei.aplicados++
+	case err == nil:
+		ei.duplicados++
+	case errorCode(err) == ErrCodeInvalidArgument || errorCode(err) == ErrCodeNotFound:
+		ei.rejeitados++
+		return ei.dl.write(map[string]interface{}{
+			"lote":   ei.lote,
+			"origem": origem,
+			"offset": offset,
+			"erro":   err.Error(),
+			"evento": string(linha),
+		})
+	default:
+		return fmt.Errorf("evento em %s, offset %d: %w", origem, offset, err)
+	}
+	return nil
+}
+
+// consume aplica os eventos de r, uma linha por evento; offset é a posição de r na origem.
+// Só linhas terminadas em '\n' são consumidas, a menos que parcial seja true: a última
+// linha de um arquivo ainda em escrita fica para a próxima leitura. save recebe o offset
+// da próxima linha a cada checkpointInterval eventos. Retorna o offset da primeira linha
+// não consumida, inclusive quando um evento interrompe o consumo.
+func (ei *eventIngestor) consume(ctx context.Context, r io.Reader, origem string, offset int64, parcial bool, save func(int64) error) (int64, error) {
+	reader := bufio.NewReader(r)
+	consumidos := 0
+	for ctx.Err() == nil {
+		raw, err := reader.ReadBytes('\n')
+		if err == io.EOF && (len(raw) == 0 || !parcial) {
+			return offset, nil
+		}
+		if err != nil && err != io.EOF {
+			return offset, err
+		}
+		if err := ei.apply(ctx, origem, offset, raw); err != nil {
+			return offset, err
+		}
+		offset += int64(len(raw))
+
+		consumidos++
+		if save != nil && consumidos%checkpointInterval == 0 {
+			if err := save(offset); err != nil {
+				return offset, err
+			}
+		}
+	}
+	return offset, nil
+}
+
+// consumeFile consome o arquivo a partir do offset e grava o checkpoint no fim
+func (ei *eventIngestor) consumeFile(ctx context.Context, path, arquivo string, offset int64, parcial bool, checkpointPath string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < offset {
+		return fmt.Errorf("%s tem %d bytes, menos que o offset %d do checkpoint: o arquivo foi truncado ou substituído", path, info.Size(), offset)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	save := func(o int64) error {
+		return saveCheckpoint(checkpointPath, arquivo, o)
+	}
+	fim, err := ei.consume(ctx, file, arquivo, offset, parcial, save)
+	if fim != offset {
+		if saveErr := save(fim); err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// consumeQueue consome os arquivos *.ndjson do diretório em ordem de nome, retomando do
+// checkpoint. Os arquivos da fila são imutáveis: o produtor os cria com outro nome e os
+// renomeia para .ndjson, então a última linha sem '\n' também é consumida.
+func (ei *eventIngestor) consumeQueue(ctx context.Context, dir, checkpointPath string) error {
+	cp, err := loadCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		arquivo := filepath.Base(path)
+		if arquivo < cp.Arquivo {
+			continue
+		}
+		offset := int64(0)
+		if arquivo == cp.Arquivo {
+			offset = cp.Offset
+		}
+		if err := ei.consumeFile(ctx, path, arquivo, offset, true, checkpointPath); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// runIngestEvents consome eventos do ciclo de vida (RecebivelCriado, CancelamentoRegistrado,
+// NegociacaoRegistrada) em NDJSON e os aplica aos documentos. O replay é idempotente e o
+// offset consumido fica no checkpoint, então o comando pode ser reiniciado a qualquer momento.
+//
+//	data-aggregator ingest-events [flags de configuração] -file eventos.ndjson | -queue dir | -stdin [-follow]
+func runIngestEvents(args []string) error {
+	fs := flag.NewFlagSet("ingest-events", flag.ContinueOnError)
+	filePath := fs.String("file", "", "Arquivo NDJSON de eventos")
+	queueDir := fs.String("queue", "", "Diretório de fila: os arquivos *.ndjson são consumidos em ordem de nome")
+	stdin := fs.Bool("stdin", false, "Ler os eventos da entrada padrão (sem checkpoint)")
+	checkpointPath := fs.String("checkpoint", "", "Arquivo de checkpoint (padrão: <file>.offset ou <queue>/.checkpoint)")
+	follow := fs.Bool("follow", false, "Aguardar novos eventos até SIGINT/SIGTERM")
+	poll := fs.Duration("poll", 2*time.Second, "Intervalo entre leituras com -follow")
+	deadLetterPath := fs.String("dead-letter", "eventos-dead-letter.ndjson", "Arquivo NDJSON dos eventos rejeitados")
+
+	cfg, err := LoadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	fontes := 0
+	for _, informada := range []bool{*filePath != "", *queueDir != "", *stdin} {
+		if informada {
+			fontes++
+		}
+	}
+	if fontes != 1 {
+		return fmt.Errorf("informe exatamente uma origem: -file, -queue ou -stdin")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = withAuditSource(ctx, cliActor(), domain.OrigemEventos)
+
+	ei := &eventIngestor{
+		lifecycle: NewReceivableLifecycle(store),
+		dl:        &deadLetter{path: *deadLetterPath},
+		lote:      time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	var pass func() error
+	switch {
+	case *stdin:
+		pass = func() error {
+			_, err := ei.consume(ctx, os.Stdin, "stdin", 0, true, nil)
+			return err
+		}
+		*follow = false
+	case *queueDir != "":
+		if *checkpointPath == "" {
+			*checkpointPath = filepath.Join(*queueDir, ".checkpoint")
+		}
+		pass = func() error {
+			return ei.consumeQueue(ctx, *queueDir, *checkpointPath)
+		}
+	default:
+		if *checkpointPath == "" {
+			*checkpointPath = *filePath + ".offset"
+		}
+		arquivo := filepath.Base(*filePath)
+		cp, err := loadCheckpoint(*checkpointPath)
+		if err != nil {
+			return err
+		}
+		if cp.Arquivo != "" && cp.Arquivo != arquivo {
+			return fmt.Errorf("o checkpoint %s é do arquivo %s, não de %s", *checkpointPath, cp.Arquivo, arquivo)
+		}
+		pass = func() error {
+			cp, err := loadCheckpoint(*checkpointPath)
+			if err != nil {
+				return err
+			}
+			return ei.consumeFile(ctx, *filePath, arquivo, cp.Offset, !*follow, *checkpointPath)
+		}
+	}
+
+	for {
+		err = pass()
+		if err != nil || !*follow || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(*poll):
+		}
+	}
+	if ctx.Err() != nil {
+		// Interrompido por sinal: o checkpoint já está na última linha aplicada
+		err = nil
+	}
+
+	fmt.Printf("📥 %d evento(s) aplicado(s), %d duplicado(s), %d rejeitado(s)\n", ei.aplicados, ei.duplicados, ei.rejeitados)
+	if ei.rejeitados > 0 {
+		fmt.Printf("⚠️  eventos rejeitados em %s\n", ei.dl.path)
+	}
+	if err != nil {
+		return err
+	}
+	if *checkpointPath != "" {
+		fmt.Printf("✅ checkpoint em %s\n", *checkpointPath)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"data-aggregator/domain"
+)
+
+// eventoCriado, eventoCancelamento e eventoNegociacao são linhas NDJSON de eventos do
+// ciclo de vida, terminadas em '\n'
+func eventoCriado(id string) string {
+	return fmt.Sprintf(`{"tipo":%q,"recebivel":{"id_recebivel":%q,"id_pagamento":"pag-%s","codigo_cliente":"CLI-A","valor_original":100,"data_vencimento":"2025-01-10"}}`+"\n", EventoRecebivelCriado, id, id)
+}
+
+func eventoCancelamento(id, idCancelamento string) string {
+	return fmt.Sprintf(`{"tipo":%q,"id_recebivel":%q,"cancelamento":{"id_cancelamento":%q,"data_cancelamento":"2025-01-05","valor_cancelado":10}}`+"\n", EventoCancelamentoRegistrado, id, idCancelamento)
+}
+
+func eventoNegociacao(id, idNegociacao string) string {
+	return fmt.Sprintf(`{"tipo":%q,"id_recebivel":%q,"negociacao":{"id_negociacao":%q,"data_negociacao":"2025-01-06","valor_negociado":5}}`+"\n", EventoNegociacaoRegistrada, id, idNegociacao)
+}
+
+// newEventIngestorTeste cria um ingestor sobre o store, com o dead-letter num diretório temporário
+func newEventIngestorTeste(t *testing.T, store ReceivableStore) *eventIngestor {
+	t.Helper()
+	return &eventIngestor{
+		lifecycle: NewReceivableLifecycle(store),
+		dl:        &deadLetter{path: filepath.Join(t.TempDir(), "dead-letter.ndjson")},
+		lote:      "teste",
+	}
+}
+
+// contagem resume os contadores do ingestor
+func (ei *eventIngestor) contagem() string {
+	return fmt.Sprintf("%d aplicados, %d duplicados, %d rejeitados", ei.aplicados, ei.duplicados, ei.rejeitados)
+}
+
+// TestConsumeFileCheckpoint confere o offset gravado no checkpoint: uma última linha sem '\n'
+// fica para a próxima leitura, a retomada começa do checkpoint e um arquivo menor que o
+// offset é recusado
+func TestConsumeFileCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "eventos.ndjson")
+	checkpoint := path + ".offset"
+	store := NewMemoryStore()
+	ei := newEventIngestorTeste(t, store)
+
+	completas := eventoCriado("rec-1") + eventoCancelamento("rec-1", "can-1")
+	parcial := eventoNegociacao("rec-1", "neg-1")
+	if err := os.WriteFile(path, []byte(completas+parcial[:20]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Arquivo em escrita (-follow): a linha incompleta não é consumida
+	if err := ei.consumeFile(ctx, path, "eventos.ndjson", 0, false, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := loadCheckpoint(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Arquivo != "eventos.ndjson" || cp.Offset != int64(len(completas)) {
+		t.Fatalf("checkpoint = %+v, esperado offset %d", cp, len(completas))
+	}
+	if got := ei.contagem(); got != "2 aplicados, 0 duplicados, 0 rejeitados" {
+		t.Fatalf("primeira leitura: %s", got)
+	}
+
+	// O produtor termina a linha: a retomada começa do checkpoint, sem reaplicar nada
+	if err := os.WriteFile(path, []byte(completas+parcial+eventoCancelamento("rec-1", "can-2")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ei.consumeFile(ctx, path, "eventos.ndjson", cp.Offset, false, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got := ei.contagem(); got != "4 aplicados, 0 duplicados, 0 rejeitados" {
+		t.Fatalf("retomada: %s", got)
+	}
+	if cp, _ = loadCheckpoint(checkpoint); cp.Offset != int64(len(completas+parcial)+len(eventoCancelamento("rec-1", "can-2"))) {
+		t.Fatalf("checkpoint após a retomada = %+v", cp)
+	}
+
+	// Sem -follow a última linha sem '\n' também é consumida
+	sem := strings.TrimSuffix(eventoNegociacao("rec-1", "neg-2"), "\n")
+	if err := os.WriteFile(path, []byte(completas+parcial+eventoCancelamento("rec-1", "can-2")+sem), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ei.consumeFile(ctx, path, "eventos.ndjson", cp.Offset, true, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got := ei.contagem(); got != "5 aplicados, 0 duplicados, 0 rejeitados" {
+		t.Fatalf("última linha sem '\\n': %s", got)
+	}
+
+	recebivel, err := store.GetReceivable(ctx, "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recebivel.Cancelamentos) != 2 || len(recebivel.Negociacoes) != 2 {
+		t.Errorf("rec-1 com %d cancelamentos e %d negociações, esperado 2 e 2", len(recebivel.Cancelamentos), len(recebivel.Negociacoes))
+	}
+
+	// Arquivo truncado: o offset do checkpoint passa do fim
+	if err := os.WriteFile(path, []byte(completas), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cp, _ = loadCheckpoint(checkpoint)
+	if err := ei.consumeFile(ctx, path, "eventos.ndjson", cp.Offset, true, checkpoint); err == nil || !strings.Contains(err.Error(), "truncado") {
+		t.Errorf("arquivo truncado: erro %v", err)
+	}
+}
+
+// TestConsumeQueueOrdem confere que a fila é consumida em ordem de nome, só nos *.ndjson, e
+// que a retomada pula os arquivos anteriores ao do checkpoint
+func TestConsumeQueueOrdem(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, ".checkpoint")
+	store := NewMemoryStore()
+	ei := newEventIngestorTeste(t, store)
+
+	// Fora de ordem de nome os eventos de rec-1 seriam rejeitados antes de ele existir
+	arquivos := map[string]string{
+		"002.ndjson":     eventoCancelamento("rec-1", "can-1"),
+		"001.ndjson":     eventoCriado("rec-1"),
+		"010.ndjson":     eventoNegociacao("rec-1", "neg-1"),
+		"003.ndjson.tmp": eventoCancelamento("rec-9", "can-9"),
+	}
+	for nome, conteudo := range arquivos {
+		if err := os.WriteFile(filepath.Join(dir, nome), []byte(conteudo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ei.consumeQueue(ctx, dir, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got := ei.contagem(); got != "3 aplicados, 0 duplicados, 0 rejeitados" {
+		t.Fatalf("fila: %s", got)
+	}
+	cp, err := loadCheckpoint(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Arquivo != "010.ndjson" || cp.Offset != int64(len(arquivos["010.ndjson"])) {
+		t.Fatalf("checkpoint = %+v, esperado o fim de 010.ndjson", cp)
+	}
+
+	// Um arquivo anterior ao do checkpoint já foi consumido; os posteriores não
+	for nome, conteudo := range map[string]string{
+		"005.ndjson": eventoCancelamento("rec-1", "can-5"),
+		"011.ndjson": eventoCancelamento("rec-1", "can-11"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, nome), []byte(conteudo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ei.consumeQueue(ctx, dir, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got := ei.contagem(); got != "4 aplicados, 0 duplicados, 0 rejeitados" {
+		t.Fatalf("retomada da fila: %s", got)
+	}
+	recebivel, err := store.GetReceivable(ctx, "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, c := range recebivel.Cancelamentos {
+		ids = append(ids, c.IDCancelamento)
+	}
+	if strings.Join(ids, ",") != "can-1,can-11" {
+		t.Errorf("cancelamentos de rec-1 = %v, esperado [can-1 can-11]", ids)
+	}
+}
+
+// TestIngestDedupe confere que o replay dos eventos não duplica nada: o RecebivelCriado pelo
+// id_recebivel e os demais pelo id_cancelamento / id_negociacao
+func TestIngestDedupe(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	ei := newEventIngestorTeste(t, store)
+
+	eventos := eventoCriado("rec-1") + eventoCancelamento("rec-1", "can-1") + eventoNegociacao("rec-1", "neg-1") +
+		eventoCancelamento("rec-2", "can-2")
+	for i := 0; i < 2; i++ {
+		if _, err := ei.consume(ctx, strings.NewReader(eventos), "teste", 0, true, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// rec-2 não existe: rejeitado nas duas passadas
+	if got := ei.contagem(); got != "3 aplicados, 3 duplicados, 2 rejeitados" {
+		t.Fatalf("replay: %s", got)
+	}
+
+	recebivel, err := store.GetReceivable(ctx, "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recebivel.Cancelamentos) != 1 || len(recebivel.Negociacoes) != 1 {
+		t.Errorf("rec-1 com %d cancelamentos e %d negociações após o replay, esperado 1 e 1", len(recebivel.Cancelamentos), len(recebivel.Negociacoes))
+	}
+	if want := domain.Reais(85); recebivel.SaldoDisponivel != want {
+		t.Errorf("saldo_disponivel = %s, esperado %s", recebivel.SaldoDisponivel, want)
+	}
+	if _, err := os.Stat(ei.dl.path); err != nil {
+		t.Errorf("dead-letter não gravado: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"data-aggregator/domain"
+	"github.com/google/uuid"
+)
+
+// ReceivableLifecycle aplica as escritas do ciclo de vida de um recebível (registro,
+// cancelamentos e negociações). Cada escrita lê o documento atual, aplica a mudança,
+// valida o resultado (inclusive saldo >= 0) e grava o documento completo, roteado
+// por id_pagamento e condicionado à versão lida, para duas escritas concorrentes não
+// perderem uma o evento da outra. Os eventos são acrescentados aos arrays, nunca substituídos.
+type ReceivableLifecycle struct {
+	store ReceivableStore
+}
+
+// NewReceivableLifecycle cria o serviço de escrita sobre o store informado
+func NewReceivableLifecycle(store ReceivableStore) *ReceivableLifecycle {
+	return &ReceivableLifecycle{store: store}
+}
+
+// Register grava um recebível novo; o _id do documento é o id_recebivel
+func (rl *ReceivableLifecycle) Register(ctx context.Context, recebivel domain.Recebivel) (*domain.Recebivel, error) {
+	recebivel.ID = ""
+	if err := validarRecebivel(&recebivel); err != nil {
+		return nil, err
+	}
+
+	if err := rl.store.CreateReceivable(ctx, &recebivel); err != nil {
+		return nil, err
+	}
+
+	recebivel.ID = recebivel.DocumentID()
+	return &recebivel, nil
+}
+
+// AddCancelamento acrescenta um cancelamento; o id é gerado se não for informado
+func (rl *ReceivableLifecycle) AddCancelamento(ctx context.Context, id, idPagamento string, versao *domain.Versao, cancelamento domain.Cancelamento) (*domain.Recebivel, error) {
+	if cancelamento.IDCancelamento == "" {
+		cancelamento.IDCancelamento = uuid.New().String()
+	}
+	return rl.update(ctx, id, idPagamento, versao, func(recebivel *domain.Recebivel) error {
+		recebivel.AddCancelamento(cancelamento)
+		return nil
+	})
+}
+
+// AddNegociacao acrescenta uma negociação; o id é gerado se não for informado
+func (rl *ReceivableLifecycle) AddNegociacao(ctx context.Context, id, idPagamento string, versao *domain.Versao, negociacao domain.Negociacao) (*domain.Recebivel, error) {
+	if negociacao.IDNegociacao == "" {
+		negociacao.IDNegociacao = uuid.New().String()
+	}
+	return rl.update(ctx, id, idPagamento, versao, func(recebivel *domain.Recebivel) error {
+		recebivel.AddNegociacao(negociacao)
+		return nil
+	})
+}
+
+// RemoveCancelamento remove um cancelamento pelo id_cancelamento
+func (rl *ReceivableLifecycle) RemoveCancelamento(ctx context.Context, id, idPagamento string, versao *domain.Versao, idCancelamento string) (*domain.Recebivel, error) {
+	return rl.update(ctx, id, idPagamento, versao, func(recebivel *domain.Recebivel) error {
+		if !recebivel.RemoveCancelamento(idCancelamento) {
+			return newAppError(ErrCodeNotFound, "cancelamento '%s' não encontrado no recebível '%s'", idCancelamento, id)
+		}
+		return nil
+	})
+}
+
+// update lê o recebível, aplica a mudança, valida e grava o documento resultante.
+// idPagamento é opcional: sem ele o store resolve o routing do documento.
+//
+// Com versao (o if_seq_no/if_primary_term do cliente) a mudança só é aplicada se o
+// documento ainda estiver nessa versão; senão o erro é CONFLICT. Sem versao a gravação é
+// condicionada à versão lida e, num conflito, a leitura e a mudança são refeitas até
+// maxConflictRetries vezes.
+func (rl *ReceivableLifecycle) update(ctx context.Context, id, idPagamento string, versao *domain.Versao, change func(*domain.Recebivel) error) (*domain.Recebivel, error) {
+	if id == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "id é obrigatório")
+	}
+
+	for tentativa := 0; ; tentativa++ {
+		recebivel, err := rl.store.GetReceivable(ctx, id, idPagamento)
+		if err != nil {
+			return nil, err
+		}
+		if versao != nil {
+			if recebivel.Versao != nil && *recebivel.Versao != *versao {
+				return nil, versionConflict(id, versao)
+			}
+			recebivel.Versao = versao
+		}
+
+		if err := change(recebivel); err != nil {
+			return nil, err
+		}
+		if err := validarRecebivel(recebivel); err != nil {
+			return nil, err
+		}
+
+		err = rl.store.ReplaceReceivable(ctx, recebivel)
+		if errorCode(err) == ErrCodeConflict && versao == nil && tentativa < maxConflictRetries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return recebivel, nil
+	}
+}
+
+// validarRecebivel valida o recebível e converte os problemas em INVALID_ARGUMENT
+func validarRecebivel(recebivel *domain.Recebivel) error {
+	err := recebivel.Validate()
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return newAppError(ErrCodeInvalidArgument, "%v", err)
+	}
+	return err
+}
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
+	"data-aggregator/domain"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/graphql-go/graphql"
@@ -26,12 +28,26 @@ type Document struct {
 // ElasticsearchClient encapsula operações do Elasticsearch
 type ElasticsearchClient struct {
 	client *elasticsearch.Client
+	index  string // índice ou alias dos recebíveis
+	routes *routingCache
+	audit  *esAuditIndex
 }
 
 // NewElasticsearchClient cria uma nova instância do cliente
-func NewElasticsearchClient(addresses []string) (*ElasticsearchClient, error) {
+func NewElasticsearchClient(config ElasticsearchConfig) (*ElasticsearchClient, error) {
 	cfg := elasticsearch.Config{
-		Addresses: addresses,
+		Addresses: config.Addresses,
+		Username:  config.Username,
+		Password:  config.Password,
+		APIKey:    config.APIKey,
+	}
+
+	if config.CACert != "" {
+		caCert, err := os.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler certificado da CA: %w", err)
+		}
+		cfg.CACert = caCert
 	}
 
 	client, err := elasticsearch.NewClient(cfg)
@@ -52,7 +68,12 @@ func NewElasticsearchClient(addresses []string) (*ElasticsearchClient, error) {
 
 	fmt.Println("✅ Conectado ao Elasticsearch com sucesso!")
 
-	return &ElasticsearchClient{client: client}, nil
+	return &ElasticsearchClient{
+		client: client,
+		index:  config.Index,
+		routes: newRoutingCache(),
+		audit:  &esAuditIndex{name: config.AuditIndex},
+	}, nil
 }
 
 // CreateIndex cria um novo índice no Elasticsearch
@@ -69,7 +90,7 @@ func (ec *ElasticsearchClient) CreateIndex(ctx context.Context, indexName string
 
 	res, err := req.Do(ctx, ec.client)
 	if err != nil {
-		return fmt.Errorf("erro ao criar índice: %w", err)
+		return transportError(err, "criar índice")
 	}
 	defer res.Body.Close()
 
@@ -79,152 +100,228 @@ func (ec *ElasticsearchClient) CreateIndex(ctx context.Context, indexName string
 			log.Printf("⚠️  Índice '%s' já existe\n", indexName)
 			return nil
 		}
-		return fmt.Errorf("erro ao criar índice: %s", res.String())
+		return decodeError(res, "criar índice")
 	}
+	audit(ctx, ec, "create_index", indexName, "", "", nil, snapshotOf(mapping))
 
 	log.Printf("✅ Índice '%s' criado com sucesso!\n", indexName)
 	return nil
 }
 
-// IndexDocument insere um documento no índice
-func (ec *ElasticsearchClient) IndexDocument(ctx context.Context, indexName string, docID string, document interface{}) error {
+// IndexDocument insere um documento no índice, com o routing informado (opcional)
+func (ec *ElasticsearchClient) IndexDocument(ctx context.Context, indexName string, docID string, routing string, document interface{}) error {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(document); err != nil {
 		return fmt.Errorf("erro ao codificar documento: %w", err)
 	}
 
+	antes, _, err := ec.snapshot(ctx, indexName, docID, routing)
+	if err != nil {
+		return err
+	}
+	depois := json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+
 	req := esapi.IndexRequest{
 		Index:      indexName,
 		DocumentID: docID,
 		Body:       &buf,
+		Routing:    routing,
 		Refresh:    "true",
 	}
 
 	res, err := req.Do(ctx, ec.client)
 	if err != nil {
-		return fmt.Errorf("erro ao indexar documento: %w", err)
+		return transportError(err, "indexar documento")
 	}
 	defer res.Body.Close()
 
-	if res.IsError() {
-		return fmt.Errorf("erro ao indexar documento: %s", res.String())
+	var indexed UpdateResponse
+	if err := decodeResponse(res, "indexar documento", &indexed); err != nil {
+		return err
+	}
+	if docID != "" {
+		ec.routes.put(indexName, docID, routing)
 	}
+	audit(ctx, ec, "index", indexName, indexed.ID, routing, antes, depois)
 
 	log.Printf("✅ Documento '%s' inserido no índice '%s'\n", docID, indexName)
 	return nil
 }
 
-// UpdateDocument atualiza um documento existente
-func (ec *ElasticsearchClient) UpdateDocument(ctx context.Context, indexName string, docID string, updates map[string]interface{}) error {
+// UpdateOptions controla a concorrência de um update parcial
+type UpdateOptions struct {
+	// Versao condiciona o update a if_seq_no/if_primary_term: se o documento mudou depois
+	// dessa versão o update falha com CONFLICT e nada é gravado
+	Versao *domain.Versao
+	// RetryOnConflict, sem Versao, é quantas vezes o documento é relido e o update
+	// reaplicado quando outra escrita o alterou entre a leitura e o update (no mínimo
+	// maxConflictRetries)
+	RetryOnConflict int
+}
+
+// UpdateDocument atualiza um documento existente; sem routing ele é resolvido (ver resolveRouting).
+// Retorna a versão gravada, para encadear o próximo update condicionado.
+func (ec *ElasticsearchClient) UpdateDocument(ctx context.Context, indexName string, docID string, routing string, updates map[string]interface{}, opts UpdateOptions) (*UpdateResponse, error) {
+	if opts.Versao != nil && opts.RetryOnConflict > 0 {
+		return nil, newAppError(ErrCodeInvalidArgument, "retry_on_conflict não pode ser combinado com if_seq_no/if_primary_term")
+	}
+	if opts.RetryOnConflict < 0 {
+		return nil, newAppError(ErrCodeInvalidArgument, "retry_on_conflict deve ser >= 0")
+	}
+
+	routing, err := ec.resolveRouting(ctx, indexName, docID, routing)
+	if err != nil {
+		return nil, err
+	}
+
 	updateDoc := map[string]interface{}{
 		"doc": updates,
 	}
+	recebiveis, err := ec.targetsReceivablesIndex(ctx, indexName)
+	if err != nil {
+		return nil, err
+	}
+	if recebiveis {
+		// No índice de recebíveis o update passa pelo script que recalcula o saldo_disponivel
+		updateDoc = receivableUpdateBody(updates)
+	}
+	// O documento resultante volta na resposta, para o registro de auditoria
+	updateDoc["_source"] = true
+	body, err := json.Marshal(updateDoc)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao codificar update: %w", err)
+	}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(updateDoc); err != nil {
-		return fmt.Errorf("erro ao codificar update: %w", err)
+	// O update é condicionado à versão do documento lido para a auditoria, para o antes
+	// ser exatamente o documento atualizado. Sem Versao do chamador o conflito vem só dessa
+	// condição, então o documento é relido e o update reaplicado, no lugar do
+	// retry_on_conflict do Elasticsearch, pelo menos maxConflictRetries vezes.
+	retries := opts.RetryOnConflict
+	if opts.Versao == nil && retries < maxConflictRetries {
+		retries = maxConflictRetries
+	}
+	for tentativa := 0; ; tentativa++ {
+		antes, versao, err := ec.snapshot(ctx, indexName, docID, routing)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Versao != nil {
+			versao = opts.Versao
+		}
+
+		updated, err := ec.updateOnce(ctx, indexName, docID, routing, body, versao)
+		if errorCode(err) == ErrCodeConflict && opts.Versao == nil && tentativa < retries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if updated.Result != "noop" {
+			audit(ctx, ec, "update", indexName, docID, routing, antes, updated.source())
+		}
+
+		log.Printf("✅ Documento '%s' atualizado no índice '%s'\n", docID, indexName)
+		return updated, nil
 	}
+}
 
+// updateOnce envia um _update, condicionado à versão se informada
+func (ec *ElasticsearchClient) updateOnce(ctx context.Context, indexName, docID, routing string, body []byte, versao *domain.Versao) (*UpdateResponse, error) {
 	req := esapi.UpdateRequest{
-		Index:      indexName,
-		DocumentID: docID,
-		Body:       &buf,
-		Refresh:    "true",
+		Index:         indexName,
+		DocumentID:    docID,
+		Body:          bytes.NewReader(body),
+		Routing:       routing,
+		Refresh:       "true",
+		IfSeqNo:       ifSeqNo(versao),
+		IfPrimaryTerm: ifPrimaryTerm(versao),
 	}
 
 	res, err := req.Do(ctx, ec.client)
 	if err != nil {
-		return fmt.Errorf("erro ao atualizar documento: %w", err)
+		return nil, transportError(err, "atualizar documento")
 	}
 	defer res.Body.Close()
 
-	if res.IsError() {
-		return fmt.Errorf("erro ao atualizar documento: %s", res.String())
+	var updated UpdateResponse
+	if err := decodeResponse(res, "atualizar documento", &updated); err != nil {
+		return nil, err
 	}
-
-	log.Printf("✅ Documento '%s' atualizado no índice '%s'\n", docID, indexName)
-	return nil
+	return &updated, nil
 }
 
-// GetDocument busca um documento por ID
-func (ec *ElasticsearchClient) GetDocument(ctx context.Context, indexName string, docID string) (map[string]interface{}, error) {
+// GetDocument busca um documento por ID; sem routing ele é resolvido (ver resolveRouting)
+func (ec *ElasticsearchClient) GetDocument(ctx context.Context, indexName string, docID string, routing string) (*GetResponse, error) {
+	routing, err := ec.resolveRouting(ctx, indexName, docID, routing)
+	if err != nil {
+		return nil, err
+	}
+
 	req := esapi.GetRequest{
 		Index:      indexName,
 		DocumentID: docID,
+		Routing:    routing,
 	}
 
 	res, err := req.Do(ctx, ec.client)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao buscar documento: %w", err)
+		return nil, transportError(err, "buscar documento")
 	}
 	defer res.Body.Close()
 
-	if res.IsError() {
-		return nil, fmt.Errorf("erro ao buscar documento: %s", res.String())
+	var result GetResponse
+	if err := decodeResponse(res, "buscar documento", &result); err != nil {
+		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	if !result.Found {
+		return nil, newAppError(ErrCodeNotFound, "documento '%s' não encontrado no índice '%s'", docID, indexName)
 	}
+	ec.routes.put(indexName, docID, result.Routing)
 
-	return result, nil
+	return &result, nil
 }
 
 // SearchDocuments busca documentos usando query
-func (ec *ElasticsearchClient) SearchDocuments(ctx context.Context, indexName string, query map[string]interface{}) ([]map[string]interface{}, error) {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, fmt.Errorf("erro ao codificar query: %w", err)
-	}
-
-	req := esapi.SearchRequest{
-		Index: []string{indexName},
-		Body:  &buf,
-	}
-
-	res, err := req.Do(ctx, ec.client)
+func (ec *ElasticsearchClient) SearchDocuments(ctx context.Context, indexName string, query map[string]interface{}) ([]SearchHit, error) {
+	result, err := ec.searchIndex(ctx, indexName, "", query)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao buscar documentos: %w", err)
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		return nil, fmt.Errorf("erro ao buscar documentos: %s", res.String())
-	}
+	return result.Hits.Hits, nil
+}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+// DeleteDocument remove um documento; sem routing ele é resolvido (ver resolveRouting)
+func (ec *ElasticsearchClient) DeleteDocument(ctx context.Context, indexName string, docID string, routing string) error {
+	routing, err := ec.resolveRouting(ctx, indexName, docID, routing)
+	if err != nil {
+		return err
 	}
 
-	// Extrair hits
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	documents := make([]map[string]interface{}, len(hits))
-	for i, hit := range hits {
-		documents[i] = hit.(map[string]interface{})
+	antes, _, err := ec.snapshot(ctx, indexName, docID, routing)
+	if err != nil {
+		return err
 	}
 
-	return documents, nil
-}
-
-// DeleteDocument remove um documento
-func (ec *ElasticsearchClient) DeleteDocument(ctx context.Context, indexName string, docID string) error {
 	req := esapi.DeleteRequest{
 		Index:      indexName,
 		DocumentID: docID,
+		Routing:    routing,
 		Refresh:    "true",
 	}
 
 	res, err := req.Do(ctx, ec.client)
 	if err != nil {
-		return fmt.Errorf("erro ao deletar documento: %w", err)
+		return transportError(err, "deletar documento")
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("erro ao deletar documento: %s", res.String())
+		return decodeError(res, "deletar documento")
 	}
+	ec.routes.remove(indexName, docID)
+	audit(ctx, ec, "delete", indexName, docID, routing, antes, nil)
 
 	log.Printf("✅ Documento '%s' deletado do índice '%s'\n", docID, indexName)
 	return nil
@@ -235,21 +332,32 @@ type QueryRequest struct {
 	Operation  string                 `json:"operation"` // create_index, index, update, get, search, delete
 	Index      string                 `json:"index"`
 	DocumentID string                 `json:"document_id,omitempty"`
+	Routing    string                 `json:"routing,omitempty"` // opcional; resolvido pelo _id quando omitido
 	Body       map[string]interface{} `json:"body,omitempty"`
+
+	// Controle de concorrência do update: if_seq_no/if_primary_term vêm do get e fazem o
+	// update falhar com CONFLICT se o documento mudou; retry_on_conflict é a alternativa
+	// sem versão, em que o documento é relido e o update parcial reaplicado
+	IfSeqNo         *int64 `json:"if_seq_no,omitempty"`
+	IfPrimaryTerm   *int64 `json:"if_primary_term,omitempty"`
+	RetryOnConflict int    `json:"retry_on_conflict,omitempty"`
 }
 
 // QueryResponse representa a resposta da API
 type QueryResponse struct {
-	Success bool                   `json:"success"`
-	Message string                 `json:"message,omitempty"`
-	Data    map[string]interface{} `json:"data,omitempty"`
-	Error   string                 `json:"error,omitempty"`
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
 }
 
 var esClient *ElasticsearchClient
 
-// initGraphQLSchema inicializa o schema GraphQL
-func initGraphQLSchema() (graphql.Schema, error) {
+// initGraphQLSchema inicializa o schema GraphQL com resolvers ligados ao store informado
+func initGraphQLSchema(store ReceivableStore, cfg ServerConfig) (graphql.Schema, error) {
+	r := NewResolvers(store, cfg)
+
 	// Query root
 	rootQuery := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Query",
@@ -263,7 +371,7 @@ func initGraphQLSchema() (graphql.Schema, error) {
 						DefaultValue: 10,
 					},
 				},
-				Resolve: getAllReceivablesResolver,
+				Resolve: r.getAllReceivablesResolver,
 			},
 			"getReceivableById": &graphql.Field{
 				Type:        receivableType,
@@ -272,8 +380,22 @@ func initGraphQLSchema() (graphql.Schema, error) {
 					"id": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.String),
 					},
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Chave de routing do documento; opcional, evita a consulta de resolução",
+					},
 				},
-				Resolve: getReceivableByIdResolver,
+				Resolve: r.getReceivableByIdResolver,
+			},
+			"receivableAuditTrail": &graphql.Field{
+				Type:        auditEntryConnectionType,
+				Description: "Paginar em ordem de escrita os registros de auditoria de um recebível",
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				}),
+				Resolve: r.receivableAuditTrailResolver,
 			},
 			"getCustomerBalance": &graphql.Field{
 				Type:        balanceType,
@@ -288,8 +410,12 @@ func initGraphQLSchema() (graphql.Schema, error) {
 					"data_fim": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.String),
 					},
+					"as_of": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Reconstrói o saldo nesta data (yyyy-MM-dd): só eventos datados até ela são descontados",
+					},
 				},
-				Resolve: getCustomerBalanceResolver,
+				Resolve: r.getCustomerBalanceResolver,
 			},
 			"getReceivablesByCustomerAndDueDate": &graphql.Field{
 				Type:        searchResultType,
@@ -313,7 +439,23 @@ func initGraphQLSchema() (graphql.Schema, error) {
 						DefaultValue: 10,
 					},
 				},
-				Resolve: getReceivablesByCustomerAndDueDateResolver,
+				Resolve: r.getReceivablesByCustomerAndDueDateResolver,
+			},
+			"getReceivablesByCustomerAndDueDateConnection": &graphql.Field{
+				Type:        receivableConnectionType,
+				Description: "Paginar por cursor os recebíveis de um cliente por data de vencimento, sem limite de profundidade",
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"codigo_cliente": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"data_inicio": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"data_fim": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+				}),
+				Resolve: r.getReceivablesByCustomerAndDueDateConnectionResolver,
 			},
 			"countReceivablesByCustomer": &graphql.Field{
 				Type:        countResultType,
@@ -323,16 +465,16 @@ func initGraphQLSchema() (graphql.Schema, error) {
 						Type: graphql.NewNonNull(graphql.String),
 					},
 				},
-				Resolve: countReceivablesByCustomerResolver,
+				Resolve: r.countReceivablesByCustomerResolver,
 			},
 			"getIndexCount": &graphql.Field{
 				Type:        countResultType,
 				Description: "Contar total de documentos no índice",
-				Resolve:     getIndexCountResolver,
+				Resolve:     r.getIndexCountResolver,
 			},
 			"countReceivablesGroupByCustomer": &graphql.Field{
 				Type:        graphql.NewList(customerStatsType),
-				Description: "Contar recebíveis agrupados por cliente",
+				Description: "Contar recebíveis agrupados por cliente, todos os clientes do período, do maior para o menor",
 				Args: graphql.FieldConfigArgument{
 					"data_inicio": &graphql.ArgumentConfig{
 						Type: graphql.String,
@@ -341,12 +483,40 @@ func initGraphQLSchema() (graphql.Schema, error) {
 						Type: graphql.String,
 					},
 				},
-				Resolve: countReceivablesGroupByCustomerResolver,
+				Resolve: r.countReceivablesGroupByCustomerResolver,
+			},
+			"balanceByCustomer": &graphql.Field{
+				Type:        customerGroupConnectionType,
+				Description: "Paginar por cursor os clientes dos recebíveis do filtro, com os totais de saldo de cada um",
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type: receivableFilterInputType,
+					},
+				}),
+				Resolve: r.balanceByCustomerResolver,
 			},
 			"getTopCustomer": &graphql.Field{
 				Type:        customerStatsType,
 				Description: "Buscar cliente com mais registros",
-				Resolve:     getTopCustomerResolver,
+				Resolve:     r.getTopCustomerResolver,
+			},
+			"topCustomers": &graphql.Field{
+				Type:        graphql.NewList(customerGroupType),
+				Description: "Os n clientes com maior valor da métrica nos recebíveis do filtro, com os totais de cada um. O filtro pode ter no máximo 10.000 clientes; acima disso a query devolve INVALID_ARGUMENT",
+				Args: graphql.FieldConfigArgument{
+					"n": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 10,
+					},
+					"metric": &graphql.ArgumentConfig{
+						Type:        topCustomerMetricEnum,
+						Description: "Métrica do ranking; padrão: COUNT",
+					},
+					"filter": &graphql.ArgumentConfig{
+						Type: receivableFilterInputType,
+					},
+				},
+				Resolve: r.topCustomersResolver,
 			},
 			"getReceivablesByBalanceAvailable": &graphql.Field{
 				Type:        searchResultType,
@@ -368,7 +538,37 @@ func initGraphQLSchema() (graphql.Schema, error) {
 						DefaultValue: 50,
 					},
 				},
-				Resolve: getReceivablesByBalanceAvailableResolver,
+				Resolve: r.getReceivablesByBalanceAvailableResolver,
+			},
+			"getReceivablesByBalanceAvailableConnection": &graphql.Field{
+				Type:        receivableConnectionType,
+				Description: "Paginar por cursor os recebíveis com saldo disponível mínimo, sem limite de profundidade",
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"codigo_cliente": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"min_balance": &graphql.ArgumentConfig{
+						Type:         graphql.Float,
+						DefaultValue: 0.0,
+					},
+				}),
+				Resolve: r.getReceivablesByBalanceAvailableConnectionResolver,
+			},
+			"searchReceivables": &graphql.Field{
+				Type:        receivableConnectionType,
+				Description: "Buscar recebíveis por qualquer combinação de filtros, com ordenação e paginação por cursor",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type: receivableFilterInputType,
+					},
+					"sort": &graphql.ArgumentConfig{
+						Type: graphql.NewList(graphql.NewNonNull(receivableSortInputType)),
+					},
+					"page": &graphql.ArgumentConfig{
+						Type: pageInputType,
+					},
+				},
+				Resolve: r.searchReceivablesResolver,
 			},
 			"getReceivableBalanceById": &graphql.Field{
 				Type:        receivableType,
@@ -377,15 +577,203 @@ func initGraphQLSchema() (graphql.Schema, error) {
 					"id": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.String),
 					},
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Chave de routing do documento; opcional, evita a consulta de resolução",
+					},
+					"as_of": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Reconstrói o saldo nesta data (yyyy-MM-dd): só eventos datados até ela são descontados",
+					},
+				},
+				Resolve: r.getReceivableBalanceByIdResolver,
+			},
+			"getPaymentById": &graphql.Field{
+				Type:        paymentType,
+				Description: "Buscar um pagamento com todos os seus recebíveis e os totais somados",
+				Args: graphql.FieldConfigArgument{
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: r.getPaymentByIdResolver,
+			},
+			"customerBalanceSeries": &graphql.Field{
+				Type:        graphql.NewList(balanceSeriesBucketType),
+				Description: "Série do saldo de um cliente por intervalo de data_vencimento, inclusive intervalos sem recebíveis",
+				Args: graphql.FieldConfigArgument{
+					"codigo_cliente": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"from": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "data_vencimento inicial (yyyy-MM-dd)",
+					},
+					"to": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "data_vencimento final (yyyy-MM-dd)",
+					},
+					"interval": &graphql.ArgumentConfig{
+						Type:        seriesIntervalEnum,
+						Description: "Largura dos intervalos; padrão: MONTH",
+					},
+				},
+				Resolve: r.customerBalanceSeriesResolver,
+			},
+			"receivableBreakdown": &graphql.Field{
+				Type:        graphql.NewList(breakdownGroupType),
+				Description: "Totais dos recebíveis agrupados por modalidade, produto, parceiro e/ou cliente",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type: receivableFilterInputType,
+					},
+					"dimensions": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(dimensionEnum))),
+						Description: "Dimensões do agrupamento; a ordem define a ordem das chaves",
+					},
+				},
+				Resolve: r.receivableBreakdownResolver,
+			},
+			"agingReport": &graphql.Field{
+				Type:        agingReportType,
+				Description: "Aging dos recebíveis de um cliente: a vencer e vencidos há 1-30, 31-60, 61-90 e mais de 90 dias",
+				Args: graphql.FieldConfigArgument{
+					"codigo_cliente": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"reference_date": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Data a partir da qual o atraso é contado (yyyy-MM-dd); padrão: hoje",
+					},
+				},
+				Resolve: r.agingReportResolver,
+			},
+			"portfolioAgingReport": &graphql.Field{
+				Type:        agingReportType,
+				Description: "Aging da carteira inteira, com as mesmas faixas de agingReport",
+				Args: graphql.FieldConfigArgument{
+					"reference_date": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Data a partir da qual o atraso é contado (yyyy-MM-dd); padrão: hoje",
+					},
+				},
+				Resolve: r.portfolioAgingReportResolver,
+			},
+			"customer": &graphql.Field{
+				Type:        customerObject(r),
+				Description: "Visão de um cliente: recebíveis, saldo, estatísticas, pagamentos e aging, cada um buscado só se selecionado",
+				Args: graphql.FieldConfigArgument{
+					"codigo_cliente": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: r.customerResolver,
+			},
+		},
+	})
+
+	receivableType.AddFieldConfig("payment", &graphql.Field{
+		Type:        paymentType,
+		Description: "Pagamento ao qual o recebível pertence",
+		Resolve:     r.receivablePaymentResolver,
+	})
+
+	// Mutation root
+	rootMutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"registerReceivable": &graphql.Field{
+				Type:        receivableType,
+				Description: "Registrar um recebível novo (roteado por id_pagamento)",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(receivableInputType),
+					},
+				},
+				Resolve: r.registerReceivableResolver,
+			},
+			"addCancelamento": &graphql.Field{
+				Type:        receivableType,
+				Description: "Acrescentar um cancelamento a um recebível",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Chave de routing do documento; opcional, evita a consulta de resolução",
+					},
+					"if_seq_no": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "seq_no lido do recebível; com if_primary_term, a mudança falha com CONFLICT se o documento mudou depois da leitura",
+					},
+					"if_primary_term": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "primary_term lido do recebível, junto com if_seq_no",
+					},
+					"cancelamento": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(cancelamentoInputType),
+					},
+				},
+				Resolve: r.addCancelamentoResolver,
+			},
+			"addNegociacao": &graphql.Field{
+				Type:        receivableType,
+				Description: "Acrescentar uma negociação a um recebível",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Chave de routing do documento; opcional, evita a consulta de resolução",
+					},
+					"if_seq_no": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "seq_no lido do recebível; com if_primary_term, a mudança falha com CONFLICT se o documento mudou depois da leitura",
+					},
+					"if_primary_term": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "primary_term lido do recebível, junto com if_seq_no",
+					},
+					"negociacao": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(negociacaoInputType),
+					},
 				},
-				Resolve: getReceivableBalanceByIdResolver,
+				Resolve: r.addNegociacaoResolver,
+			},
+			"removeCancelamento": &graphql.Field{
+				Type:        receivableType,
+				Description: "Remover um cancelamento de um recebível",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"id_pagamento": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Chave de routing do documento; opcional, evita a consulta de resolução",
+					},
+					"if_seq_no": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "seq_no lido do recebível; com if_primary_term, a mudança falha com CONFLICT se o documento mudou depois da leitura",
+					},
+					"if_primary_term": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "primary_term lido do recebível, junto com if_seq_no",
+					},
+					"id_cancelamento": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: r.removeCancelamentoResolver,
 			},
 		},
 	})
 
 	// Schema configuration
 	schemaConfig := graphql.SchemaConfig{
-		Query: rootQuery,
+		Query:    rootQuery,
+		Mutation: rootMutation,
 	}
 
 	return graphql.NewSchema(schemaConfig)
@@ -414,38 +802,70 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := r.Context()
 	var response QueryResponse
 
+	// O índice de auditoria só recebe registros novos, gravados pelo próprio serviço; nem
+	// por um alias ou padrão que o alcance
+	if req.Operation != "get" && req.Operation != "search" {
+		auditoria, err := esClient.targetsAuditIndex(ctx, req.Index)
+		if err == nil && auditoria {
+			err = newAppError(ErrCodeInvalidArgument, "'%s' alcança o índice de auditoria '%s', que é somente leitura", req.Index, esClient.audit.name)
+		}
+		if err != nil {
+			json.NewEncoder(w).Encode(QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)})
+			return
+		}
+	}
+
 	switch req.Operation {
 	case "create_index":
 		err := esClient.CreateIndex(ctx, req.Index, req.Body)
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
 			response = QueryResponse{Success: true, Message: fmt.Sprintf("Índice '%s' criado com sucesso", req.Index)}
 		}
 
 	case "index":
-		err := esClient.IndexDocument(ctx, req.Index, req.DocumentID, req.Body)
+		document, routing := req.Body, req.Routing
+		// No índice de recebíveis o routing é sempre o id_pagamento do documento, e o
+		// saldo_disponivel é calculado aqui
+		recebiveis, err := esClient.targetsReceivablesIndex(ctx, req.Index)
+		if err == nil && recebiveis {
+			routing, err = receivableRouting(document, routing)
+		}
+		if err == nil && recebiveis {
+			document, err = withSaldoDisponivel(document)
+		}
+		if err == nil {
+			err = esClient.IndexDocument(ctx, req.Index, req.DocumentID, routing, document)
+		}
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
 			response = QueryResponse{Success: true, Message: fmt.Sprintf("Documento '%s' inserido com sucesso", req.DocumentID)}
 		}
 
 	case "update":
-		err := esClient.UpdateDocument(ctx, req.Index, req.DocumentID, req.Body)
+		versao, err := expectedVersion(req.IfSeqNo, req.IfPrimaryTerm)
+		var updated *UpdateResponse
+		if err == nil {
+			updated, err = esClient.UpdateDocument(ctx, req.Index, req.DocumentID, req.Routing, req.Body, UpdateOptions{
+				Versao:          versao,
+				RetryOnConflict: req.RetryOnConflict,
+			})
+		}
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
-			response = QueryResponse{Success: true, Message: fmt.Sprintf("Documento '%s' atualizado com sucesso", req.DocumentID)}
+			response = QueryResponse{Success: true, Message: fmt.Sprintf("Documento '%s' atualizado com sucesso", req.DocumentID), Data: updated.versao()}
 		}
 
 	case "get":
-		doc, err := esClient.GetDocument(ctx, req.Index, req.DocumentID)
+		doc, err := esClient.GetDocument(ctx, req.Index, req.DocumentID, req.Routing)
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
 			response = QueryResponse{Success: true, Message: "Documento encontrado", Data: doc}
 		}
@@ -453,7 +873,7 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 	case "search":
 		results, err := esClient.SearchDocuments(ctx, req.Index, req.Body)
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
 			response = QueryResponse{
 				Success: true,
@@ -463,9 +883,9 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case "delete":
-		err := esClient.DeleteDocument(ctx, req.Index, req.DocumentID)
+		err := esClient.DeleteDocument(ctx, req.Index, req.DocumentID, req.Routing)
 		if err != nil {
-			response = QueryResponse{Success: false, Error: err.Error()}
+			response = QueryResponse{Success: false, Error: err.Error(), Code: errorCode(err)}
 		} else {
 			response = QueryResponse{Success: true, Message: fmt.Sprintf("Documento '%s' deletado com sucesso", req.DocumentID)}
 		}
@@ -477,6 +897,10 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Um update condicionado a uma versão desatualizada responde 409, como no Elasticsearch
+	if response.Code == ErrCodeConflict {
+		w.WriteHeader(http.StatusConflict)
+	}
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -490,143 +914,107 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// formatarMoeda formata um valor float64 como moeda brasileira
-func formatarMoeda(valor float64) string {
-	// Separar parte inteira e decimal
-	parteInteira := int64(valor)
-	parteDecimal := int((valor - float64(parteInteira)) * 100)
-
-	// Formatar parte inteira com separador de milhar
-	strInteira := fmt.Sprintf("%d", parteInteira)
-	var resultado strings.Builder
+// writeAppError escreve um erro JSON com o código estável e o status HTTP correspondente
+func writeAppError(w http.ResponseWriter, err error) {
+	code := errorCode(err)
+	w.WriteHeader(appErrorStatus(code))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+		"code":  code,
+	})
+}
 
-	for i, digit := range strInteira {
-		if i > 0 && (len(strInteira)-i)%3 == 0 {
-			resultado.WriteString(".")
-		}
-		resultado.WriteRune(digit)
+// appErrorStatus é o status HTTP de um código de erro
+func appErrorStatus(code string) int {
+	status := http.StatusBadGateway
+	switch code {
+	case ErrCodeInvalidArgument:
+		status = http.StatusBadRequest
+	case ErrCodeNotFound:
+		status = http.StatusNotFound
+	case ErrCodeAlreadyExists, ErrCodeConflict:
+		status = http.StatusConflict
+	case ErrCodeUnauthenticated:
+		status = http.StatusUnauthorized
+	case ErrCodeBackendUnavailable:
+		status = http.StatusServiceUnavailable
+	case ErrCodeInternal:
+		status = http.StatusInternalServerError
+	case ErrCodeUnimplemented:
+		status = http.StatusNotImplemented
 	}
-
-	return fmt.Sprintf("R$ %s,%02d", resultado.String(), parteDecimal)
+	return status
 }
 
-// saldoClienteHandler retorna o saldo formatado de um cliente
-func saldoClienteHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if r.Method != http.MethodPost {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "Método não permitido. Use POST",
-		})
-		return
-	}
+// saldoClienteHandler retorna o saldo formatado de um cliente, calculado pelo BalanceEngine
+func saldoClienteHandler(balances *BalanceEngine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "Método não permitido. Use POST",
+			})
+			return
+		}
 
-	var req struct {
-		CodigoCliente string `json:"codigo_cliente"`
-		DataInicio    string `json:"data_inicio"`
-		DataFim       string `json:"data_fim"`
-	}
+		var req BalanceQuery
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAppError(w, newAppError(ErrCodeInvalidArgument, "Erro ao decodificar JSON: %v", err))
+			return
+		}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("Erro ao decodificar JSON: %v", err),
-		})
-		return
-	}
+		report, err := balances.CustomerBalance(r.Context(), req)
+		if err != nil {
+			writeAppError(w, err)
+			return
+		}
 
-	// Construir query
-	query := map[string]interface{}{
-		"size": 0,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"codigo_cliente.keyword": req.CodigoCliente,
-						},
-					},
-					{
-						"range": map[string]interface{}{
-							"data_vencimento": map[string]interface{}{
-								"gte": req.DataInicio,
-								"lte": req.DataFim,
-							},
-						},
-					},
-				},
-			},
-		},
-		"aggs": map[string]interface{}{
-			"saldo_total": map[string]interface{}{
-				"scripted_metric": map[string]interface{}{
-					"init_script":    "state.saldo_total = 0.0",
-					"map_script":     "double saldo = doc['valor_original'].value; if (params._source.cancelamentos != null) { for (def c : params._source.cancelamentos) { saldo -= c.valor_cancelado; } } if (params._source.negociacoes != null) { for (def n : params._source.negociacoes) { saldo -= n.valor_negociado; } } state.saldo_total += saldo;",
-					"combine_script": "return state.saldo_total",
-					"reduce_script":  "double total = 0; for (s in states) { total += s; } return Math.round(total * 100.0) / 100.0",
-				},
-			},
-		},
+		json.NewEncoder(w).Encode(report)
 	}
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("Erro ao codificar query: %v", err),
-		})
-		return
+// openStore abre o store configurado: em memória no modo demo ou o Elasticsearch
+func openStore(cfg *Config) (ReceivableStore, error) {
+	if len(cfg.DemoData) > 0 {
+		// Modo demo: recebíveis carregados em memória
+		memoryStore, err := LoadMemoryStore(cfg.DemoData...)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("🧪 Modo demo: recebíveis carregados em memória de %s\n", strings.Join(cfg.DemoData, ", "))
+		return memoryStore, nil
 	}
 
-	// Executar busca
-	res, err := esClient.client.Search(
-		esClient.client.Search.WithContext(context.Background()),
-		esClient.client.Search.WithIndex("ciclo_vida_recebivel"),
-		esClient.client.Search.WithBody(&buf),
-	)
-	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("Erro ao executar busca: %v", err),
-		})
-		return
-	}
-	defer res.Body.Close()
+	// Conectar ao Elasticsearch
+	return NewElasticsearchClient(cfg.Elasticsearch)
+}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("Erro ao decodificar resposta: %v", err),
-		})
-		return
+func main() {
+	// Subcomandos (ex.: reconcile) rodam no lugar do servidor
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
-	// Extrair saldo e formatar
-	aggs := result["aggregations"].(map[string]interface{})
-	saldoTotal := aggs["saldo_total"].(map[string]interface{})["value"].(float64)
-	hits := result["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)
-
-	response := map[string]interface{}{
-		"codigo_cliente": req.CodigoCliente,
-		"periodo": map[string]string{
-			"inicio": req.DataInicio,
-			"fim":    req.DataFim,
-		},
-		"total_recebiveis": int(hits),
-		"saldo_total":      saldoTotal,
-		"saldo_formatado":  formatarMoeda(saldoTotal),
+	cfg, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	json.NewEncoder(w).Encode(response)
-}
-
-func main() {
-	// Conectar ao Elasticsearch
-	var err error
-	esClient, err = NewElasticsearchClient([]string{"http://localhost:9200"})
+	store, err := openStore(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	esClient, _ = store.(*ElasticsearchClient)
 
 	// Inicializar schema GraphQL
-	schema, err := initGraphQLSchema()
+	schema, err := initGraphQLSchema(store, cfg.Server)
 	if err != nil {
 		log.Fatalf("Erro ao criar schema GraphQL: %v", err)
 	}
@@ -635,25 +1023,58 @@ func main() {
 	graphqlHandler := handler.New(&handler.Config{
 		Schema:   &schema,
 		Pretty:   true,
-		GraphiQL: true,
+		GraphiQL: cfg.Server.GraphiQL,
 	})
 
-	// Configurar rotas HTTP
-	http.HandleFunc("/query", handleQuery)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/saldo-cliente", saldoClienteHandler)
-	http.Handle("/graphql", graphqlHandler)
+	// Autenticação de todas as rotas, exceto /health
+	auth, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auth == nil {
+		log.Println("⚠️  Autenticação desativada (auth.disabled): todas as rotas estão abertas")
+	}
+	mux := newServeMux(cfg, store, auth, graphqlHandler)
 
 	// Iniciar servidor HTTP
-	port := ":8080"
-	fmt.Printf("🚀 Servidor HTTP iniciado em http://localhost%s\n", port)
-	fmt.Printf("📝 Endpoint de query: POST http://localhost%s/query\n", port)
-	fmt.Printf("💚 Health check: GET http://localhost%s/health\n", port)
-	fmt.Printf("🔷 GraphQL endpoint: POST http://localhost%s/graphql\n", port)
-	fmt.Printf("🎨 GraphiQL playground: http://localhost%s/graphql\n", port)
+	addr := cfg.Server.ListenAddr
+	fmt.Printf("🚀 Servidor HTTP iniciado em %s\n", addr)
+	fmt.Printf("📝 Endpoint de query: POST %s/query\n", addr)
+	fmt.Printf("💚 Health check: GET %s/health\n", addr)
+	fmt.Printf("📤 Exportação: GET %s/export/receivables\n", addr)
+	fmt.Printf("📥 Carga em lote: POST %s/receivables/_bulk\n", addr)
+	fmt.Printf("🔷 GraphQL endpoint: POST %s/graphql\n", addr)
+	if cfg.Server.GraphiQL {
+		fmt.Printf("🎨 GraphiQL playground: %s/graphql\n", addr)
+	}
 	fmt.Println("\n✅ Servidor pronto para receber requisições!")
 
-	if err := http.ListenAndServe(port, nil); err != nil {
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  cfg.Server.ReadTimeout.Duration,
+		WriteTimeout: cfg.Server.WriteTimeout.Duration,
+	}
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newServeMux registra as rotas HTTP; todas, exceto /health, passam pelo authMiddleware
+func newServeMux(cfg *Config, store ReceivableStore, auth Authenticator, graphqlHandler http.Handler) *http.ServeMux {
+	protect := func(next http.Handler) http.Handler {
+		return authMiddleware(auth, next)
+	}
+
+	mux := http.NewServeMux()
+	// O /query opera direto no Elasticsearch e fica fora do modo demo
+	if _, ok := store.(*ElasticsearchClient); ok {
+		mux.Handle("/query", protect(auditMiddleware(domain.OrigemQuery, http.HandlerFunc(handleQuery))))
+	}
+	mux.Handle("/saldo-cliente", protect(saldoClienteHandler(NewBalanceEngine(store))))
+	mux.Handle("/export/receivables", protect(exportHandler(store)))
+	mux.Handle("/receivables/_bulk", protect(auditMiddleware(domain.OrigemBulk, bulkHandler(store, cfg.Server.BulkDeadLetter))))
+	mux.HandleFunc("/health", healthHandler)
+	mux.Handle("/graphql", protect(auditMiddleware(domain.OrigemGraphQL, graphqlHandler)))
+	return mux
+}
@@ -63,7 +63,7 @@ catch {
     else {
         Write-Host ""
         Write-Host "⚠️  Inicie o servidor manualmente com:" -ForegroundColor Yellow
-        Write-Host "   go run ." -ForegroundColor White
+        Write-Host "   go run . -auth-disabled" -ForegroundColor White
         Write-Host ""
         exit 1
     }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+const (
+	// maxPageSize limita o first das connections
+	maxPageSize = 1000
+	// pitKeepAlive é quanto tempo o point-in-time de uma paginação fica aberto entre páginas
+	pitKeepAlive = "2m"
+	// tiebreakerField desempata a ordenação para o search_after ser determinístico
+	tiebreakerField = "id_recebivel"
+)
+
+// pageCursor é o conteúdo do cursor opaco das connections: o point-in-time da
+// paginação (só no Elasticsearch, a partir da segunda página) e os valores de ordenação do último item
+type pageCursor struct {
+	PitID string        `json:"p,omitempty"`
+	After []interface{} `json:"a"`
+}
+
+// encodeCursor serializa o cursor em base64 para o cliente tratá-lo como opaco
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor lê um cursor gerado por encodeCursor; vazio significa primeira página
+func decodeCursor(value string) (*pageCursor, error) {
+	if value == "" {
+		return &pageCursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, newAppError(ErrCodeInvalidArgument, "cursor inválido")
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.After) == 0 {
+		return nil, newAppError(ErrCodeInvalidArgument, "cursor inválido")
+	}
+	return &cursor, nil
+}
+
+// validate verifica o tamanho da página pedida
+func (p PageRequest) validate() error {
+	if p.First <= 0 || p.First > maxPageSize {
+		return newAppError(ErrCodeInvalidArgument, "first deve estar entre 1 e %d", maxPageSize)
+	}
+	return nil
+}
+
+// withTiebreaker acrescenta o desempate por id_recebivel à ordenação, se ainda não houver
+func withTiebreaker(sort []SortField) []SortField {
+	for _, s := range sort {
+		if s.Field == tiebreakerField {
+			return sort
+		}
+	}
+	return append(append([]SortField(nil), sort...), SortField{Field: tiebreakerField})
+}
+
+// newReceivablePage monta a página a partir dos edges lidos (até First+1, para saber se há próxima)
+func newReceivablePage(total int, edges []ReceivableEdge, request PageRequest) *ReceivablePage {
+	page := &ReceivablePage{TotalCount: total, Edges: edges}
+	page.PageInfo.HasPreviousPage = request.After != ""
+	if len(edges) > request.First {
+		page.Edges = edges[:request.First]
+		page.PageInfo.HasNextPage = true
+	}
+	if len(page.Edges) > 0 {
+		page.PageInfo.StartCursor = page.Edges[0].Cursor
+		page.PageInfo.EndCursor = page.Edges[len(page.Edges)-1].Cursor
+	}
+	return page
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+
+	"data-aggregator/domain"
+)
+
+// maxRecebiveisPorPagamento limita os recebíveis lidos de um pagamento em uma única busca
+const maxRecebiveisPorPagamento = maxPageSize
+
+// Pagamento agrupa os recebíveis de um id_pagamento. Como os documentos são roteados por
+// id_pagamento, todos ficam no mesmo shard e o pagamento é lido com uma busca roteada.
+type Pagamento struct {
+	IDPagamento     string              `json:"id_pagamento"`
+	TotalRecebiveis int                 `json:"total_recebiveis"`
+	ValorOriginal   domain.Money        `json:"valor_original"`
+	TotalCancelado  domain.Money        `json:"total_cancelado"`
+	TotalNegociado  domain.Money        `json:"total_negociado"`
+	SaldoDisponivel domain.Money        `json:"saldo_disponivel"`
+	Recebiveis      []*domain.Recebivel `json:"receivables"`
+}
+
+// Payment busca os recebíveis de um pagamento e soma seus valores com a mesma fórmula de
+// saldo dos recebíveis (domain.Recebivel.Saldo)
+func (be *BalanceEngine) Payment(ctx context.Context, idPagamento string) (*Pagamento, error) {
+	if idPagamento == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "id_pagamento é obrigatório")
+	}
+
+	result, err := be.store.SearchReceivables(ctx, ReceivableQuery{
+		Filter:  ReceivableFilter{IDPagamento: idPagamento},
+		Sort:    []SortField{{Field: "id_recebivel"}},
+		Size:    maxRecebiveisPorPagamento,
+		Routing: idPagamento,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		return nil, newAppError(ErrCodeNotFound, "pagamento '%s' não encontrado", idPagamento)
+	}
+	if result.Total > len(result.Receivables) {
+		return nil, newAppError(ErrCodeInvalidArgument, "pagamento '%s' tem %d recebíveis, acima do limite de %d", idPagamento, result.Total, maxRecebiveisPorPagamento)
+	}
+
+	pagamento := &Pagamento{
+		IDPagamento:     idPagamento,
+		TotalRecebiveis: result.Total,
+		Recebiveis:      result.Receivables,
+	}
+	for _, recebivel := range result.Receivables {
+		pagamento.ValorOriginal += recebivel.ValorOriginal
+		pagamento.TotalCancelado += recebivel.TotalCancelado()
+		pagamento.TotalNegociado += recebivel.TotalNegociado()
+		pagamento.SaldoDisponivel += recebivel.Saldo()
+	}
+	return pagamento, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"data-aggregator/domain"
+)
+
+// runReconcile confere, cliente a cliente, se o saldo agregado pelo store fecha no centavo
+// com o saldo recalculado documento a documento.
+//
+//	data-aggregator reconcile [flags de configuração] [-cliente X] [-data-inicio D] [-data-fim D]
+func runReconcile(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	codigoCliente := fs.String("cliente", "", "Reconciliar apenas este codigo_cliente")
+	dataInicio := fs.String("data-inicio", "", "data_vencimento mínima (yyyy-MM-dd)")
+	dataFim := fs.String("data-fim", "", "data_vencimento máxima (yyyy-MM-dd)")
+
+	cfg, err := LoadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	divergencias, err := reconcile(context.Background(), store, *codigoCliente, *dataInicio, *dataFim)
+	if err != nil {
+		return err
+	}
+	if divergencias > 0 {
+		return fmt.Errorf("❌ %d cliente(s) com saldo divergente", divergencias)
+	}
+
+	fmt.Println("✅ Todos os saldos conferem no centavo")
+	return nil
+}
+
+// reconcile recalcula o saldo de cada cliente a partir dos documentos, compara com
+// CustomerBalance e imprime o resultado; retorna o número de clientes divergentes
+func reconcile(ctx context.Context, store ReceivableStore, codigoCliente, dataInicio, dataFim string) (int, error) {
+	query := ReceivableQuery{Filter: customerFilter(codigoCliente, dataInicio, dataFim)}
+	recalculados := map[string]*CustomerBalance{}
+	err := store.ScanReceivables(ctx, query, func(recebivel *domain.Recebivel) error {
+		balance, ok := recalculados[recebivel.CodigoCliente]
+		if !ok {
+			balance = &CustomerBalance{CodigoCliente: recebivel.CodigoCliente}
+			recalculados[recebivel.CodigoCliente] = balance
+		}
+		balance.TotalRecebiveis++
+		balance.ValorOriginal += recebivel.ValorOriginal
+		balance.TotalCancelado += recebivel.TotalCancelado()
+		balance.TotalNegociado += recebivel.TotalNegociado()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	clientes := make([]string, 0, len(recalculados))
+	for codigoCliente := range recalculados {
+		clientes = append(clientes, codigoCliente)
+	}
+	sort.Strings(clientes)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "cliente\trecebíveis\tsaldo documentos\tsaldo agregado\tdiferença (centavos)\t")
+
+	divergencias := 0
+	for _, codigoCliente := range clientes {
+		recalculado := recalculados[codigoCliente]
+		agregado, err := store.CustomerBalance(ctx, BalanceQuery{CodigoCliente: codigoCliente, DataInicio: dataInicio, DataFim: dataFim})
+		if err != nil {
+			return 0, err
+		}
+
+		diferenca := int64(agregado.Saldo() - recalculado.Saldo())
+		if diferenca != 0 || agregado.TotalRecebiveis != recalculado.TotalRecebiveis {
+			divergencias++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t\n",
+			codigoCliente, recalculado.TotalRecebiveis, recalculado.Saldo(), agregado.Saldo(), diferenca)
+	}
+
+	return divergencias, tw.Flush()
+}
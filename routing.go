@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"data-aggregator/esquery"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// routingCacheSize limita as entradas guardadas pelo routingCache
+const routingCacheSize = 100000
+
+// routingCache guarda o _routing conhecido de cada documento (índice + _id), para
+// GET/update/delete irem direto ao shard certo. Os recebíveis são roteados por
+// id_pagamento, que não muda, então uma entrada não fica desatualizada; quando o
+// limite é atingido o cache é esvaziado.
+type routingCache struct {
+	mu      sync.RWMutex
+	entries map[string]string
+}
+
+// newRoutingCache cria um cache de roteamento vazio
+func newRoutingCache() *routingCache {
+	return &routingCache{entries: make(map[string]string)}
+}
+
+func (rc *routingCache) get(indexName, docID string) (string, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	routing, ok := rc.entries[indexName+"/"+docID]
+	return routing, ok
+}
+
+func (rc *routingCache) put(indexName, docID, routing string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.entries) >= routingCacheSize {
+		rc.entries = make(map[string]string)
+	}
+	rc.entries[indexName+"/"+docID] = routing
+}
+
+func (rc *routingCache) remove(indexName, docID string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.entries, indexName+"/"+docID)
+}
+
+// resolveRouting devolve o routing a usar em operações sobre um documento: o informado,
+// o conhecido pelo cache ou, em último caso, o _routing encontrado por uma query ids,
+// que consulta todos os shards. Documentos indexados sem routing resolvem para vazio; um
+// _id encontrado com mais de um routing é ambíguo e o chamador precisa informá-lo.
+func (ec *ElasticsearchClient) resolveRouting(ctx context.Context, indexName, docID, routing string) (string, error) {
+	if routing != "" {
+		return routing, nil
+	}
+	if routing, ok := ec.routes.get(indexName, docID); ok {
+		return routing, nil
+	}
+
+	result, err := ec.searchIndex(ctx, indexName, "", map[string]interface{}{
+		"query":   esquery.Ids(docID).Map(),
+		"size":    2,
+		"_source": false,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(result.Hits.Hits) == 0 {
+		return "", newAppError(ErrCodeNotFound, "documento '%s' não encontrado no índice '%s'", docID, indexName)
+	}
+	if len(result.Hits.Hits) > 1 {
+		return "", newAppError(ErrCodeInvalidArgument, "documento '%s' encontrado com mais de um routing em '%s': informe o routing", docID, indexName)
+	}
+
+	routing = result.Hits.Hits[0].Routing
+	ec.routes.put(indexName, docID, routing)
+	return routing, nil
+}
+
+// receivableRouting devolve o routing de um recebível gravado pelo /query, que é o
+// id_pagamento do documento. Sem id_pagamento, ou com um routing diferente dele, o
+// recebível iria para um shard onde as leituras roteadas não o encontram, então é recusado.
+func receivableRouting(doc map[string]interface{}, routing string) (string, error) {
+	idPagamento, _ := doc["id_pagamento"].(string)
+	if idPagamento == "" {
+		return "", newAppError(ErrCodeInvalidArgument, "recebível sem id_pagamento, que é o routing do índice de recebíveis")
+	}
+	if routing != "" && routing != idPagamento {
+		return "", newAppError(ErrCodeInvalidArgument, "routing '%s' diferente do id_pagamento '%s' do recebível", routing, idPagamento)
+	}
+	return idPagamento, nil
+}
+
+// targetsReceivablesIndex diz se o índice de uma operação do /query alcança o índice de
+// recebíveis, direto ou por um alias, padrão ou lista (ver targetsIndex)
+func (ec *ElasticsearchClient) targetsReceivablesIndex(ctx context.Context, indexName string) (bool, error) {
+	return ec.targetsIndex(ctx, indexName, ec.index)
+}
+
+// targetsIndex diz se indexName alcança algum índice concreto de target. Os dois nomes
+// podem ser aliases, padrões com curinga ou listas, então são resolvidos pelo
+// _resolve/index; um nome que ainda não existe só alcança a si mesmo.
+func (ec *ElasticsearchClient) targetsIndex(ctx context.Context, indexName, target string) (bool, error) {
+	if indexName == target {
+		return true, nil
+	}
+
+	alcancados, err := ec.concreteIndices(ctx, indexName)
+	if err != nil {
+		return false, err
+	}
+	destino, err := ec.concreteIndices(ctx, target)
+	if err != nil {
+		return false, err
+	}
+	for name := range alcancados {
+		if destino[name] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// concreteIndices resolve um nome, padrão ou lista para os índices concretos que ele
+// alcança, incluindo os que estão por trás de aliases e data streams
+func (ec *ElasticsearchClient) concreteIndices(ctx context.Context, indexName string) (map[string]bool, error) {
+	ignorar := true
+	res, err := esapi.IndicesResolveIndexRequest{
+		Name:              []string{indexName},
+		ExpandWildcards:   "all",
+		IgnoreUnavailable: &ignorar,
+		AllowNoIndices:    &ignorar,
+	}.Do(ctx, ec.client)
+	if err != nil {
+		return nil, transportError(err, "resolver índice")
+	}
+	defer res.Body.Close()
+
+	var resolved ResolveIndexResponse
+	if err := decodeResponse(res, "resolver índice", &resolved); err != nil {
+		return nil, err
+	}
+	indices := map[string]bool{}
+	for _, index := range resolved.Indices {
+		indices[index.Name] = true
+	}
+	for _, alias := range resolved.Aliases {
+		for _, name := range alias.Indices {
+			indices[name] = true
+		}
+	}
+	for _, dataStream := range resolved.DataStreams {
+		for _, name := range dataStream.BackingIndices {
+			indices[name] = true
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+
+	"data-aggregator/domain"
+	"data-aggregator/esquery"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// saldoCalculoScript soma em centavos, na variável saldo, a mesma fórmula de
+// domain.Recebivel.Saldo sobre o _source, para não acumular erro de ponto flutuante
+const saldoCalculoScript = `
+long saldo = Math.round(((Number) ctx._source.valor_original).doubleValue() * 100);
+if (ctx._source.cancelamentos != null) {
+  for (def c : ctx._source.cancelamentos) { saldo -= Math.round(((Number) c.valor_cancelado).doubleValue() * 100); }
+}
+if (ctx._source.negociacoes != null) {
+  for (def n : ctx._source.negociacoes) { saldo -= Math.round(((Number) n.valor_negociado).doubleValue() * 100); }
+}
+`
+
+// saldoDisponivelScript recalcula o saldo_disponivel no _source com saldoCalculoScript.
+// Se params.doc vier preenchido ele é mesclado antes, como num update parcial com "doc".
+const saldoDisponivelScript = `
+if (params.containsKey('doc')) { ctx._source.putAll(params.doc); }
+` + saldoCalculoScript + `
+ctx._source.saldo_disponivel = saldo / 100.0;
+`
+
+// receivableUpdateBody monta o corpo do _update de um recebível: o doc parcial é aplicado
+// pelo script, que recalcula o saldo_disponivel na mesma operação
+func receivableUpdateBody(updates map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": saldoDisponivelScript,
+			"params": map[string]interface{}{"doc": updates},
+		},
+	}
+}
+
+// withSaldoDisponivel devolve uma cópia do documento com o saldo_disponivel recalculado.
+// Um documento que não tem o formato de recebível é recusado com INVALID_ARGUMENT.
+func withSaldoDisponivel(doc map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, wrapAppError(ErrCodeInvalidArgument, err, "documento inválido")
+	}
+	var recebivel domain.Recebivel
+	if err := json.Unmarshal(raw, &recebivel); err != nil {
+		return nil, wrapAppError(ErrCodeInvalidArgument, err, "documento não tem o formato de recebível")
+	}
+
+	copia := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		copia[k] = v
+	}
+	copia["saldo_disponivel"] = recebivel.Saldo().Float64()
+	return copia, nil
+}
+
+// BackfillSaldoDisponivel grava o saldo_disponivel nos documentos já indexados com um
+// _update_by_query. Antes garante o campo no mapping (scaled_float), para índices criados
+// antes dele existir. Com todos=false só os documentos sem o campo são atualizados.
+func (ec *ElasticsearchClient) BackfillSaldoDisponivel(ctx context.Context, todos bool) (int, error) {
+	if err := ec.putSaldoDisponivelMapping(ctx); err != nil {
+		return 0, err
+	}
+
+	var query esquery.Query = esquery.MatchAll()
+	if !todos {
+		query = esquery.Bool().MustNot(esquery.Exists("saldo_disponivel"))
+	}
+	body := map[string]interface{}{
+		"query": query.Map(),
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": saldoDisponivelScript,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return 0, wrapAppError(ErrCodeInternal, err, "erro ao codificar update_by_query")
+	}
+
+	conflicts := "proceed"
+	refresh := true
+	waitForCompletion := true
+	req := esapi.UpdateByQueryRequest{
+		Index:             []string{ec.index},
+		Body:              &buf,
+		Conflicts:         conflicts,
+		Refresh:           &refresh,
+		WaitForCompletion: &waitForCompletion,
+	}
+
+	res, err := req.Do(ctx, ec.client)
+	if err != nil {
+		return 0, transportError(err, "recalcular saldo_disponivel")
+	}
+	defer res.Body.Close()
+
+	var result UpdateByQueryResponse
+	if err := decodeResponse(res, "recalcular saldo_disponivel", &result); err != nil {
+		return 0, err
+	}
+	if len(result.Failures) > 0 {
+		return result.Updated, newAppError(ErrCodeBackendError, "%d documento(s) falharam no recálculo do saldo_disponivel", len(result.Failures))
+	}
+	return result.Updated, nil
+}
+
+// putSaldoDisponivelMapping acrescenta saldo_disponivel ao mapping do índice de recebíveis
+func (ec *ElasticsearchClient) putSaldoDisponivelMapping(ctx context.Context) error {
+	properties := domain.Properties(reflect.TypeOf(domain.Recebivel{}), domain.MoneyScaledFloat)
+	body := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"saldo_disponivel": properties["saldo_disponivel"],
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return wrapAppError(ErrCodeInternal, err, "erro ao codificar mapping")
+	}
+
+	res, err := ec.client.Indices.PutMapping([]string{ec.index}, &buf, ec.client.Indices.PutMapping.WithContext(ctx))
+	if err != nil {
+		return transportError(err, "atualizar mapping")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return decodeError(res, "atualizar mapping")
+	}
+	return nil
+}
+
+// BackfillSaldoDisponivel recalcula o saldo_disponivel dos documentos em memória. As
+// escritas já o mantêm atualizado; existe para o subcomando funcionar no modo demo.
+func (ms *MemoryStore) BackfillSaldoDisponivel(ctx context.Context, todos bool) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	atualizados := 0
+	for id, recebivel := range ms.documents {
+		if recebivel.SaldoDisponivel == recebivel.Saldo() && !todos {
+			continue
+		}
+		recebivel.UpdateSaldoDisponivel()
+		ms.documents[id] = recebivel
+		atualizados++
+	}
+	return atualizados, nil
+}
+
+// runBackfillSaldo grava o saldo_disponivel nos documentos indexados antes de o campo existir.
+//
+//	data-aggregator backfill-saldo [flags de configuração] [-todos]
+func runBackfillSaldo(args []string) error {
+	fs := flag.NewFlagSet("backfill-saldo", flag.ContinueOnError)
+	todos := fs.Bool("todos", false, "Recalcular todos os documentos, não só os que não têm saldo_disponivel")
+
+	cfg, err := LoadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	atualizados, err := store.BackfillSaldoDisponivel(context.Background(), *todos)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ saldo_disponivel gravado em %d documento(s)\n", atualizados)
+	return nil
+}
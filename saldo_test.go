@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestWithSaldoDisponivel(t *testing.T) {
+	doc := map[string]interface{}{
+		"id_recebivel":   "rec-1",
+		"id_pagamento":   "pag-1",
+		"valor_original": 1000.10,
+		"cancelamentos":  []interface{}{map[string]interface{}{"id_cancelamento": "can-1", "valor_cancelado": 0.10}},
+		"negociacoes":    []interface{}{map[string]interface{}{"id_negociacao": "neg-1", "valor_negociado": "333.33"}},
+	}
+	got, err := withSaldoDisponivel(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["saldo_disponivel"] != 666.67 {
+		t.Errorf("saldo_disponivel = %v, esperado 666.67", got["saldo_disponivel"])
+	}
+	if _, ok := doc["saldo_disponivel"]; ok {
+		t.Error("o documento original foi alterado")
+	}
+
+	for _, invalido := range []map[string]interface{}{
+		{"valor_original": "mil"},
+		{"cancelamentos": "can-1"},
+		{"id_recebivel": 42},
+	} {
+		if _, err := withSaldoDisponivel(invalido); errorCode(err) != ErrCodeInvalidArgument {
+			t.Errorf("withSaldoDisponivel(%v): erro %v, esperado %s", invalido, err, ErrCodeInvalidArgument)
+		}
+	}
+}
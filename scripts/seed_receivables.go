@@ -6,45 +6,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"os/user"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"data-aggregator/domain"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esutil"
 	"github.com/google/uuid"
 )
 
-// Recebivel representa um recebível
-type Recebivel struct {
-	IDRecebivel           string         `json:"id_recebivel"`
-	IDPagamento           string         `json:"id_pagamento"`
-	CodigoCliente         string         `json:"codigo_cliente"`
-	CodigoProduto         int            `json:"codigo_produto"`
-	CodigoProdutoParceiro int            `json:"codigo_produto_parceiro"`
-	Modalidade            int            `json:"modalidade"`
-	ValorOriginal         float64        `json:"valor_original"`
-	DataVencimento        string         `json:"data_vencimento"`
-	Cancelamentos         []Cancelamento `json:"cancelamentos,omitempty"`
-	Negociacoes           []Negociacao   `json:"negociacoes,omitempty"`
-}
-
-// Cancelamento representa um cancelamento
-type Cancelamento struct {
-	IDCancelamento   string  `json:"id_cancelamento"`
-	DataCancelamento string  `json:"data_cancelamento"`
-	ValorCancelado   float64 `json:"valor_cancelado"`
-	Motivo           string  `json:"motivo"`
-}
-
-// Negociacao representa uma negociação
-type Negociacao struct {
-	IDNegociacao   string  `json:"id_negociacao"`
-	DataNegociacao string  `json:"data_negociacao"`
-	ValorNegociado float64 `json:"valor_negociado"`
-}
-
 func main() {
 	// Inicializar seed aleatório
 	rand.Seed(time.Now().UnixNano())
@@ -72,6 +45,20 @@ func main() {
 	ctx := context.Background()
 	totalRecebiveis := 10000000
 	indexName := "ciclo_vida_recebivel"
+	auditIndexName := domain.IndiceAuditoria
+
+	// Criar o índice com o mapping do modelo de domínio, se ainda não existir
+	if err := criarIndiceSeNecessario(es, indexName, domain.IndexMapping()); err != nil {
+		panic(err.Error())
+	}
+	// Cada recebível inserido também é registrado no índice de auditoria
+	if err := criarIndiceSeNecessario(es, auditIndexName, domain.AuditMapping()); err != nil {
+		panic(err.Error())
+	}
+	ator := "cli:desconhecido"
+	if u, err := user.Current(); err == nil {
+		ator = "cli:" + u.Username
+	}
 
 	// Listas de clientes fictícios
 	clientes := []string{
@@ -123,6 +110,22 @@ func main() {
 		panic(fmt.Sprintf("Erro ao criar BulkIndexer: %s", err))
 	}
 
+	// BulkIndexer dos registros de auditoria, gravados depois de cada recebível inserido
+	auditor, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:         auditIndexName,
+		Client:        es,
+		NumWorkers:    2,
+		FlushBytes:    2e+6,
+		FlushInterval: 8 * time.Second,
+		OnError: func(ctx context.Context, err error) {
+			fmt.Printf("❌ Erro no bulk de auditoria: %s\n", err)
+		},
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Erro ao criar BulkIndexer de auditoria: %s", err))
+	}
+	var countAuditFailed uint64
+
 	// Contador atômico para sucesso e erro
 	var (
 		countSuccessful uint64
@@ -146,6 +149,12 @@ func main() {
 
 			// Gerar recebível
 			recebivel := gerarRecebivelConcorrente(clientes, pagamentosIDs, index, totalRecebiveis)
+			if err := recebivel.Validate(); err != nil {
+				atomic.AddUint64(&countFailed, 1)
+				fmt.Printf("❌ Recebível %d gerado inválido: %s\n", index+1, err)
+				return
+			}
+			recebivel.UpdateSaldoDisponivel()
 
 			// Serializar para JSON
 			body, err := json.Marshal(recebivel)
@@ -167,6 +176,8 @@ func main() {
 						Routing:    recebivel.IDPagamento, // Routing para co-localização
 						OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
 							atomic.AddUint64(&countSuccessful, 1)
+							registrarAuditoria(ctx, auditor, &countAuditFailed, domain.NovoRegistroAuditoria(
+								ator, domain.OrigemSeeder, "index", indexName, recebivel.IDRecebivel, recebivel.IDPagamento, nil, body))
 							current := atomic.LoadUint64(&countSuccessful)
 							if current%10000 == 0 {
 								fmt.Printf("✅ Inseridos %d/%d recebíveis (%.2f%%)\n", current, totalRecebiveis, float64(current)/float64(totalRecebiveis)*100)
@@ -210,6 +221,10 @@ func main() {
 	if err := bi.Close(ctx); err != nil {
 		panic(fmt.Sprintf("Erro ao fechar BulkIndexer: %s", err))
 	}
+	// O auditor só fecha depois do bi, que enfileira nele até o último OnSuccess
+	if err := auditor.Close(ctx); err != nil {
+		panic(fmt.Sprintf("Erro ao fechar BulkIndexer de auditoria: %s", err))
+	}
 
 	// Estatísticas do BulkIndexer
 	biStats := bi.Stats()
@@ -225,6 +240,8 @@ func main() {
 	fmt.Printf("   - BulkIndexer - Flushed: %d\n", biStats.NumFlushed)
 	fmt.Printf("   - BulkIndexer - Indexed: %d\n", biStats.NumIndexed)
 	fmt.Printf("   - BulkIndexer - Failed: %d\n", biStats.NumFailed)
+	fmt.Printf("   - Auditoria - Indexed: %d\n", auditor.Stats().NumIndexed)
+	fmt.Printf("   - Auditoria - Failed: %d\n", atomic.LoadUint64(&countAuditFailed))
 	fmt.Printf("   - Taxa: %.0f recebíveis/segundo\n", float64(totalRecebiveis)/duration.Seconds())
 	fmt.Println(strings.Repeat("=", 60))
 
@@ -232,8 +249,59 @@ func main() {
 	es.Indices.Refresh(es.Indices.Refresh.WithIndex(indexName))
 }
 
+// criarIndiceSeNecessario cria o índice com o mapping informado caso ele não exista
+func criarIndiceSeNecessario(es *elasticsearch.Client, indexName string, mapping map[string]interface{}) error {
+	res, err := es.Indices.Exists([]string{indexName})
+	if err != nil {
+		return fmt.Errorf("erro ao verificar índice: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		return nil
+	}
+
+	body, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("erro ao codificar mapping: %w", err)
+	}
+
+	res, err = es.Indices.Create(indexName, es.Indices.Create.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("erro ao criar índice: %s", res.String())
+	}
+
+	fmt.Printf("✅ Índice '%s' criado com o mapping do domínio\n", indexName)
+	return nil
+}
+
+// registrarAuditoria enfileira o registro de auditoria de um recebível inserido; o
+// op_type create com o id_registro como _id nunca sobrescreve outro registro
+func registrarAuditoria(ctx context.Context, auditor esutil.BulkIndexer, countFailed *uint64, registro domain.RegistroAuditoria) {
+	body, err := json.Marshal(registro)
+	if err == nil {
+		err = auditor.Add(ctx, esutil.BulkIndexerItem{
+			Action:     "create",
+			DocumentID: registro.IDRegistro,
+			Body:       bytes.NewReader(body),
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				atomic.AddUint64(countFailed, 1)
+				fmt.Printf("❌ Auditoria não gravada para %s: %s %s\n", registro.DocumentoID, res.Error.Reason, err)
+			},
+		})
+	}
+	if err != nil {
+		atomic.AddUint64(countFailed, 1)
+		fmt.Printf("❌ Auditoria não gravada para %s: %s\n", registro.DocumentoID, err)
+	}
+}
+
 // gerarRecebivelConcorrente gera um recebível com dados aleatórios (thread-safe)
-func gerarRecebivelConcorrente(clientes []string, pagamentosIDs []string, index int, total int) Recebivel {
+func gerarRecebivelConcorrente(clientes []string, pagamentosIDs []string, index int, total int) domain.Recebivel {
 	// Criar gerador de números aleatórios específico para esta goroutine
 	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(index)))
 
@@ -252,12 +320,12 @@ func gerarRecebivelConcorrente(clientes []string, pagamentosIDs []string, index
 	cliente := clientes[rng.Intn(len(clientes))]
 
 	// Valor original entre 100 e 1000
-	valorOriginal := float64(rng.Intn(901) + 100) // 100 a 1000
+	valorOriginal := domain.Reais(int64(rng.Intn(901) + 100)) // R$ 100 a R$ 1000
 
 	// Gerar data de vencimento aleatória entre 2025-01-01 e 2026-12-31
 	dataVencimento := gerarDataAleatoriaConcorrente(rng)
 
-	recebivel := Recebivel{
+	recebivel := domain.Recebivel{
 		IDRecebivel:           id,
 		IDPagamento:           idPagamento,
 		CodigoCliente:         cliente,
@@ -299,27 +367,26 @@ func gerarDataAleatoriaConcorrente(rng *rand.Rand) string {
 }
 
 // gerarCancelamentoTotalConcorrente gera cancelamentos que somam 100% do valor original (thread-safe)
-func gerarCancelamentoTotalConcorrente(valorOriginal float64, rng *rand.Rand) []Cancelamento {
+func gerarCancelamentoTotalConcorrente(valorOriginal domain.Money, rng *rand.Rand) []domain.Cancelamento {
 	numCancelamentos := rng.Intn(3) + 1 // 1 a 3 cancelamentos
-	cancelamentos := make([]Cancelamento, numCancelamentos)
+	cancelamentos := make([]domain.Cancelamento, numCancelamentos)
 
 	valorRestante := valorOriginal
 	for i := 0; i < numCancelamentos; i++ {
-		var valorCancelado float64
+		var valorCancelado domain.Money
 		if i == numCancelamentos-1 {
 			// Último cancelamento pega o valor restante
 			valorCancelado = valorRestante
 		} else {
 			// Cancelamentos intermediários pegam de 20% a 60% do restante
-			percentual := rng.Float64()*0.4 + 0.2 // 0.2 a 0.6
-			valorCancelado = valorRestante * percentual
+			valorCancelado = fracao(valorRestante, 200, 600, rng)
 			valorRestante -= valorCancelado
 		}
 
-		cancelamentos[i] = Cancelamento{
+		cancelamentos[i] = domain.Cancelamento{
 			IDCancelamento:   uuid.New().String(),
 			DataCancelamento: gerarDataAleatoriaConcorrente(rng),
-			ValorCancelado:   arredondar(valorCancelado),
+			ValorCancelado:   valorCancelado,
 			Motivo:           getMotivoAleatorioConcorrente(rng),
 		}
 	}
@@ -328,29 +395,27 @@ func gerarCancelamentoTotalConcorrente(valorOriginal float64, rng *rand.Rand) []
 }
 
 // gerarCancelamentoParcialConcorrente gera cancelamentos que somam entre 10% e 70% do valor original (thread-safe)
-func gerarCancelamentoParcialConcorrente(valorOriginal float64, rng *rand.Rand) []Cancelamento {
+func gerarCancelamentoParcialConcorrente(valorOriginal domain.Money, rng *rand.Rand) []domain.Cancelamento {
 	numCancelamentos := rng.Intn(3) + 1 // 1 a 3 cancelamentos
-	cancelamentos := make([]Cancelamento, numCancelamentos)
+	cancelamentos := make([]domain.Cancelamento, numCancelamentos)
 
 	// Total a cancelar: entre 10% e 70% do valor original
-	percentualTotal := rng.Float64()*0.6 + 0.1 // 0.1 a 0.7
-	totalACancelar := valorOriginal * percentualTotal
+	totalACancelar := fracao(valorOriginal, 100, 700, rng)
 
 	valorRestante := totalACancelar
 	for i := 0; i < numCancelamentos; i++ {
-		var valorCancelado float64
+		var valorCancelado domain.Money
 		if i == numCancelamentos-1 {
 			valorCancelado = valorRestante
 		} else {
-			percentual := rng.Float64()*0.4 + 0.2 // 0.2 a 0.6
-			valorCancelado = valorRestante * percentual
+			valorCancelado = fracao(valorRestante, 200, 600, rng)
 			valorRestante -= valorCancelado
 		}
 
-		cancelamentos[i] = Cancelamento{
+		cancelamentos[i] = domain.Cancelamento{
 			IDCancelamento:   uuid.New().String(),
 			DataCancelamento: gerarDataAleatoriaConcorrente(rng),
-			ValorCancelado:   arredondar(valorCancelado),
+			ValorCancelado:   valorCancelado,
 			Motivo:           getMotivoAleatorioConcorrente(rng),
 		}
 	}
@@ -359,25 +424,24 @@ func gerarCancelamentoParcialConcorrente(valorOriginal float64, rng *rand.Rand)
 }
 
 // gerarNegociacaoTotalConcorrente gera negociações que somam 100% do valor original (thread-safe)
-func gerarNegociacaoTotalConcorrente(valorOriginal float64, rng *rand.Rand) []Negociacao {
+func gerarNegociacaoTotalConcorrente(valorOriginal domain.Money, rng *rand.Rand) []domain.Negociacao {
 	numNegociacoes := rng.Intn(3) + 1 // 1 a 3 negociações
-	negociacoes := make([]Negociacao, numNegociacoes)
+	negociacoes := make([]domain.Negociacao, numNegociacoes)
 
 	valorRestante := valorOriginal
 	for i := 0; i < numNegociacoes; i++ {
-		var valorNegociado float64
+		var valorNegociado domain.Money
 		if i == numNegociacoes-1 {
 			valorNegociado = valorRestante
 		} else {
-			percentual := rng.Float64()*0.4 + 0.2 // 0.2 a 0.6
-			valorNegociado = valorRestante * percentual
+			valorNegociado = fracao(valorRestante, 200, 600, rng)
 			valorRestante -= valorNegociado
 		}
 
-		negociacoes[i] = Negociacao{
+		negociacoes[i] = domain.Negociacao{
 			IDNegociacao:   uuid.New().String(),
 			DataNegociacao: gerarDataAleatoriaConcorrente(rng),
-			ValorNegociado: arredondar(valorNegociado),
+			ValorNegociado: valorNegociado,
 		}
 	}
 
@@ -385,38 +449,38 @@ func gerarNegociacaoTotalConcorrente(valorOriginal float64, rng *rand.Rand) []Ne
 }
 
 // gerarNegociacaoParcialConcorrente gera negociações que somam entre 10% e 70% do valor original (thread-safe)
-func gerarNegociacaoParcialConcorrente(valorOriginal float64, rng *rand.Rand) []Negociacao {
+func gerarNegociacaoParcialConcorrente(valorOriginal domain.Money, rng *rand.Rand) []domain.Negociacao {
 	numNegociacoes := rng.Intn(3) + 1 // 1 a 3 negociações
-	negociacoes := make([]Negociacao, numNegociacoes)
+	negociacoes := make([]domain.Negociacao, numNegociacoes)
 
 	// Total a negociar: entre 10% e 70% do valor original
-	percentualTotal := rng.Float64()*0.6 + 0.1 // 0.1 a 0.7
-	totalANegociar := valorOriginal * percentualTotal
+	totalANegociar := fracao(valorOriginal, 100, 700, rng)
 
 	valorRestante := totalANegociar
 	for i := 0; i < numNegociacoes; i++ {
-		var valorNegociado float64
+		var valorNegociado domain.Money
 		if i == numNegociacoes-1 {
 			valorNegociado = valorRestante
 		} else {
-			percentual := rng.Float64()*0.4 + 0.2 // 0.2 a 0.6
-			valorNegociado = valorRestante * percentual
+			valorNegociado = fracao(valorRestante, 200, 600, rng)
 			valorRestante -= valorNegociado
 		}
 
-		negociacoes[i] = Negociacao{
+		negociacoes[i] = domain.Negociacao{
 			IDNegociacao:   uuid.New().String(),
 			DataNegociacao: gerarDataAleatoriaConcorrente(rng),
-			ValorNegociado: arredondar(valorNegociado),
+			ValorNegociado: valorNegociado,
 		}
 	}
 
 	return negociacoes
 }
 
-// arredondar arredonda para 2 casas decimais
-func arredondar(valor float64) float64 {
-	return float64(int(valor*100)) / 100
+// fracao retorna uma fração aleatória do valor entre minimo e maximo (em milésimos),
+// truncada no centavo; como o último evento pega o restante, a soma fecha exatamente
+func fracao(valor domain.Money, minimo, maximo int, rng *rand.Rand) domain.Money {
+	milesimos := domain.Money(rng.Intn(maximo-minimo+1) + minimo)
+	return valor * milesimos / 1000
 }
 
 // getMotivoAleatorioConcorrente retorna um motivo aleatório para cancelamento (thread-safe)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"data-aggregator/domain"
+)
+
+// maxSeriesBuckets limita os intervalos de uma série, para um período longo em DAY não
+// estourar o search.max_buckets do cluster
+const maxSeriesBuckets = 1000
+
+// SeriesInterval é a largura de cada bucket da série de saldo
+type SeriesInterval string
+
+const (
+	IntervalDay   SeriesInterval = "DAY"
+	IntervalWeek  SeriesInterval = "WEEK"
+	IntervalMonth SeriesInterval = "MONTH"
+)
+
+// calendarInterval retorna o calendar_interval equivalente da date_histogram
+func (i SeriesInterval) calendarInterval() string {
+	switch i {
+	case IntervalWeek:
+		return "week"
+	case IntervalMonth:
+		return "month"
+	}
+	return "day"
+}
+
+// start retorna o início do intervalo que contém a data; semanas começam na segunda-feira,
+// como na date_histogram do Elasticsearch
+func (i SeriesInterval) start(data time.Time) time.Time {
+	switch i {
+	case IntervalWeek:
+		return data.AddDate(0, 0, -((int(data.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(data.Year(), data.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return data
+}
+
+// next retorna o início do intervalo seguinte
+func (i SeriesInterval) next(inicio time.Time) time.Time {
+	switch i {
+	case IntervalWeek:
+		return inicio.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return inicio.AddDate(0, 1, 0)
+	}
+	return inicio.AddDate(0, 0, 1)
+}
+
+// BalanceSeriesQuery pede a série de saldo de um cliente entre duas datas de vencimento
+type BalanceSeriesQuery struct {
+	CodigoCliente string
+	DataInicio    string
+	DataFim       string
+	Intervalo     SeriesInterval
+}
+
+// BalanceSeriesBucket é um intervalo da série com os totais dos recebíveis que vencem nele
+type BalanceSeriesBucket struct {
+	Periodo Periodo       `json:"periodo"`
+	Totais  BalanceTotals `json:"totais"`
+}
+
+// newSeriesBuckets cria os buckets vazios que cobrem o período, na ordem cronológica.
+// Os buckets das pontas cobrem o intervalo de calendário inteiro, como na date_histogram.
+func newSeriesBuckets(q BalanceSeriesQuery) ([]BalanceSeriesBucket, error) {
+	inicio, _ := time.Parse(domain.LayoutData, q.DataInicio)
+	fim, _ := time.Parse(domain.LayoutData, q.DataFim)
+
+	var buckets []BalanceSeriesBucket
+	for atual := q.Intervalo.start(inicio); !atual.After(fim); atual = q.Intervalo.next(atual) {
+		if len(buckets) == maxSeriesBuckets {
+			return nil, newAppError(ErrCodeInvalidArgument, "o período gera mais de %d intervalos %s; use um intervalo maior", maxSeriesBuckets, q.Intervalo)
+		}
+		buckets = append(buckets, BalanceSeriesBucket{
+			Periodo: Periodo{
+				Inicio: atual.Format(domain.LayoutData),
+				Fim:    q.Intervalo.next(atual).AddDate(0, 0, -1).Format(domain.LayoutData),
+			},
+		})
+	}
+	return buckets, nil
+}
+
+// BalanceSeries monta a série de saldo do cliente: um bucket por intervalo entre as duas
+// datas, inclusive os intervalos sem recebíveis, com as somas do BalanceEngine em cada um
+func (be *BalanceEngine) BalanceSeries(ctx context.Context, q BalanceSeriesQuery) ([]BalanceSeriesBucket, error) {
+	if q.DataInicio == "" || q.DataFim == "" {
+		return nil, newAppError(ErrCodeInvalidArgument, "from e to são obrigatórios")
+	}
+	if err := (BalanceQuery{CodigoCliente: q.CodigoCliente, DataInicio: q.DataInicio, DataFim: q.DataFim}).Validate(); err != nil {
+		return nil, err
+	}
+	switch q.Intervalo {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return nil, newAppError(ErrCodeInvalidArgument, "intervalo inválido '%s': use DAY, WEEK ou MONTH", q.Intervalo)
+	}
+	return be.store.BalanceSeries(ctx, q)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+
+	"data-aggregator/domain"
+)
+
+// ReceivableStore abstrai o armazenamento de recebíveis usado pelos resolvers GraphQL.
+// O ElasticsearchClient é a implementação de produção e o MemoryStore permite rodar
+// a API sem um cluster (testes e modo demo).
+type ReceivableStore interface {
+	// GetReceivable busca um recebível pelo ID do documento. idPagamento é a chave de
+	// routing; se vazio o store a resolve (o resultado é o mesmo, com uma consulta a mais)
+	GetReceivable(ctx context.Context, id, idPagamento string) (*domain.Recebivel, error)
+	// SearchReceivables busca recebíveis que atendem aos critérios da query
+	SearchReceivables(ctx context.Context, query ReceivableQuery) (*ReceivableSearchResult, error)
+	// CountReceivables conta os recebíveis de um cliente (ou de todo o índice se vazio)
+	CountReceivables(ctx context.Context, codigoCliente string) (int, error)
+	// CustomerBalance soma valores originais, cancelamentos e negociações de um cliente no
+	// período; com AsOf só soma os eventos datados até aquela data
+	CustomerBalance(ctx context.Context, query BalanceQuery) (*CustomerBalance, error)
+	// GroupByCustomer conta recebíveis agrupados por cliente, do maior para o menor
+	GroupByCustomer(ctx context.Context, query GroupByCustomerQuery) ([]CustomerStats, error)
+	// CustomerGroups soma os recebíveis do filtro por cliente, uma página de clientes em
+	// ordem crescente de codigo_cliente depois de query.After
+	CustomerGroups(ctx context.Context, query CustomerGroupsQuery) ([]CustomerGroup, error)
+	// TopCustomers devolve os query.N clientes com maior valor da métrica, do maior para o menor
+	TopCustomers(ctx context.Context, query TopCustomersQuery) ([]CustomerGroup, error)
+	// PageReceivables pagina os recebíveis da query por cursor (search_after), sem o limite
+	// de 10.000 hits de from/size; From e Size da query são ignorados
+	PageReceivables(ctx context.Context, query ReceivableQuery, page PageRequest) (*ReceivablePage, error)
+	// CreateReceivable grava um recebível novo, roteado por id_pagamento; falha com
+	// ALREADY_EXISTS se o documento já existir
+	CreateReceivable(ctx context.Context, recebivel *domain.Recebivel) error
+	// ReplaceReceivable substitui um recebível existente, roteado por id_pagamento
+	ReplaceReceivable(ctx context.Context, recebivel *domain.Recebivel) error
+	// RecordAudit acrescenta um registro ao índice de auditoria, que nunca é sobrescrito
+	RecordAudit(ctx context.Context, registro domain.RegistroAuditoria) error
+	// AuditTrail lista os registros de auditoria de um recebível em ordem de escrita
+	AuditTrail(ctx context.Context, query AuditTrailQuery) ([]domain.RegistroAuditoria, error)
+	// NewReceivableBulk abre uma gravação em lote de recebíveis (index roteado por
+	// id_pagamento); onResult recebe o resultado de cada item adicionado
+	NewReceivableBulk(ctx context.Context, onResult func(BulkItem, BulkItemResult)) (ReceivableBulk, error)
+	// AppendCancelamento acrescenta um cancelamento ao recebível e recalcula o saldo numa só
+	// escrita; devolve false, sem alterar nada, se o id_cancelamento já existir
+	AppendCancelamento(ctx context.Context, id, idPagamento string, cancelamento domain.Cancelamento) (bool, error)
+	// AppendNegociacao é o AppendCancelamento das negociações, deduplicado por id_negociacao
+	AppendNegociacao(ctx context.Context, id, idPagamento string, negociacao domain.Negociacao) (bool, error)
+	// ScanReceivables percorre todos os recebíveis que atendem aos filtros da query, em ordem
+	// de id_recebivel, chamando fn para cada um; From, Size e Sort são ignorados
+	ScanReceivables(ctx context.Context, query ReceivableQuery, fn func(*domain.Recebivel) error) error
+	// BackfillSaldoDisponivel grava o saldo_disponivel nos documentos que não o têm (ou em
+	// todos, se todos=true) e retorna quantos foram atualizados
+	BackfillSaldoDisponivel(ctx context.Context, todos bool) (int, error)
+	// Aging soma os recebíveis por faixa de atraso do vencimento na data de referência
+	Aging(ctx context.Context, query AgingQuery) ([]AgingBucket, error)
+	// BalanceSeries soma os recebíveis do cliente por intervalo de data_vencimento no período
+	BalanceSeries(ctx context.Context, query BalanceSeriesQuery) ([]BalanceSeriesBucket, error)
+	// Breakdown soma os recebíveis do filtro por combinação de valores das dimensões
+	Breakdown(ctx context.Context, query BreakdownQuery) ([]BreakdownGroup, error)
+	// PaymentIDs lista os id_pagamento distintos dos recebíveis de um cliente, em ordem crescente
+	PaymentIDs(ctx context.Context, codigoCliente string, size int) ([]string, error)
+}
+
+// ReceivableQuery descreve uma busca de recebíveis independente do backend
+type ReceivableQuery struct {
+	Filter  ReceivableFilter
+	Sort    []SortField
+	From    int
+	Size    int
+	Routing string // opcional: restringe a busca ao shard da chave (id_pagamento); só SearchReceivables usa
+}
+
+// SortField define a ordenação por um campo do documento
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ReceivableSearchResult representa o resultado paginado de uma busca
+type ReceivableSearchResult struct {
+	Total       int                 `json:"total"`
+	Receivables []*domain.Recebivel `json:"receivables"`
+}
+
+// PageRequest pede a página de First recebíveis seguinte ao cursor After (vazio para a primeira)
+type PageRequest struct {
+	First int
+	After string
+}
+
+// ReceivablePage é uma página no formato de connection do Relay
+type ReceivablePage struct {
+	TotalCount int              `json:"totalCount"`
+	Edges      []ReceivableEdge `json:"edges"`
+	PageInfo   PageInfo         `json:"pageInfo"`
+}
+
+// ReceivableEdge liga um recebível ao cursor da sua posição na ordenação
+type ReceivableEdge struct {
+	Cursor string            `json:"cursor"`
+	Node   *domain.Recebivel `json:"node"`
+}
+
+// PageInfo informa os cursores das pontas da página e se há mais resultados
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor,omitempty"`
+	EndCursor       string `json:"endCursor,omitempty"`
+}
+
+// CustomerBalance representa as somas usadas no cálculo do saldo de um cliente
+type CustomerBalance struct {
+	CodigoCliente   string
+	TotalRecebiveis int
+	ValorOriginal   domain.Money
+	TotalCancelado  domain.Money
+	TotalNegociado  domain.Money
+}
+
+// Saldo retorna o saldo disponível: valor original - cancelamentos - negociações
+func (b *CustomerBalance) Saldo() domain.Money {
+	return b.ValorOriginal - b.TotalCancelado - b.TotalNegociado
+}
+
+// BalanceTotals reúne as somas de saldo de um grupo de recebíveis (faixa de aging, intervalo,
+// agrupamento). Saldo segue a fórmula do BalanceEngine.
+type BalanceTotals struct {
+	TotalRecebiveis int          `json:"total_recebiveis"`
+	ValorOriginal   domain.Money `json:"valor_original"`
+	TotalCancelado  domain.Money `json:"total_cancelado"`
+	TotalNegociado  domain.Money `json:"total_negociado"`
+	Saldo           domain.Money `json:"saldo"`
+}
+
+// add soma um recebível aos totais
+func (t *BalanceTotals) add(recebivel *domain.Recebivel) {
+	t.TotalRecebiveis++
+	t.ValorOriginal += recebivel.ValorOriginal
+	t.TotalCancelado += recebivel.TotalCancelado()
+	t.TotalNegociado += recebivel.TotalNegociado()
+	t.Saldo += recebivel.Saldo()
+}
+
+// AgingQuery descreve o aging dos recebíveis de um cliente (ou da carteira inteira, se vazio)
+type AgingQuery struct {
+	CodigoCliente  string
+	DataReferencia string // yyyy-MM-dd; o atraso é contado a partir desta data
+}
+
+// AgingBucket representa uma faixa de atraso com os totais dos recebíveis que caem nela
+type AgingBucket struct {
+	Faixa     string        `json:"faixa"`
+	Descricao string        `json:"descricao"`
+	DataDe    string        `json:"data_de,omitempty"`  // data_vencimento inicial, inclusiva
+	DataAte   string        `json:"data_ate,omitempty"` // data_vencimento final, inclusiva
+	Totais    BalanceTotals `json:"totais"`
+}
+
+// GroupByCustomerQuery descreve uma contagem agrupada por cliente
+type GroupByCustomerQuery struct {
+	DataInicio string
+	DataFim    string
+	Size       int
+}
+
+// CustomerStats representa a contagem de recebíveis de um cliente
+type CustomerStats struct {
+	CodigoCliente   string `json:"codigo_cliente"`
+	TotalRecebiveis int    `json:"total_recebiveis"`
+}
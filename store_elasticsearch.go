@@ -0,0 +1,967 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"data-aggregator/domain"
+	"data-aggregator/esquery"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// searchIndex executa uma busca no índice informado e decodifica a resposta tipada.
+// Buscas com point-in-time passam indexName vazio, pois o índice vem do pit; com routing
+// a busca vai só ao shard da chave informada.
+func (ec *ElasticsearchClient) searchIndex(ctx context.Context, indexName, routing string, query map[string]interface{}) (*SearchResponse, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, wrapAppError(ErrCodeInternal, err, "erro ao codificar query")
+	}
+
+	opts := []func(*esapi.SearchRequest){
+		ec.client.Search.WithContext(ctx),
+		ec.client.Search.WithBody(&buf),
+	}
+	if indexName != "" {
+		opts = append(opts, ec.client.Search.WithIndex(indexName))
+	}
+	if routing != "" {
+		opts = append(opts, ec.client.Search.WithRouting(routing))
+	}
+
+	res, err := ec.client.Search(opts...)
+	if err != nil {
+		return nil, transportError(err, "buscar documentos")
+	}
+	defer res.Body.Close()
+
+	var result SearchResponse
+	if err := decodeResponse(res, "buscar documentos", &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// search executa uma busca no índice de recebíveis
+func (ec *ElasticsearchClient) search(ctx context.Context, query map[string]interface{}) (*SearchResponse, error) {
+	return ec.searchIndex(ctx, ec.index, "", query)
+}
+
+// GetReceivable busca um recebível pelo ID do documento, roteado por id_pagamento
+func (ec *ElasticsearchClient) GetReceivable(ctx context.Context, id, idPagamento string) (*domain.Recebivel, error) {
+	doc, err := ec.GetDocument(ctx, ec.index, id, idPagamento)
+	if err != nil {
+		return nil, err
+	}
+
+	recebivel, err := decodeRecebivel(doc.Source, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	recebivel.Versao = doc.versao()
+	return recebivel, nil
+}
+
+// SearchReceivables busca recebíveis que atendem aos critérios da query
+func (ec *ElasticsearchClient) SearchReceivables(ctx context.Context, query ReceivableQuery) (*ReceivableSearchResult, error) {
+	body := map[string]interface{}{
+		"query": query.Filter.Query().Map(),
+		"from":  query.From,
+		"size":  query.Size,
+	}
+	if len(query.Sort) > 0 {
+		body["sort"] = sortDSL(query.Sort)
+	}
+
+	result, err := ec.searchIndex(ctx, ec.index, query.Routing, body)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := result.total()
+	if err != nil {
+		return nil, err
+	}
+
+	receivables := make([]*domain.Recebivel, len(result.Hits.Hits))
+	for i, hit := range result.Hits.Hits {
+		if receivables[i], err = decodeRecebivel(hit.Source, hit.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ReceivableSearchResult{
+		Total:       total,
+		Receivables: receivables,
+	}, nil
+}
+
+// CountReceivables conta os recebíveis de um cliente (ou de todo o índice se vazio)
+func (ec *ElasticsearchClient) CountReceivables(ctx context.Context, codigoCliente string) (int, error) {
+	opts := []func(*esapi.CountRequest){
+		ec.client.Count.WithContext(ctx),
+		ec.client.Count.WithIndex(ec.index),
+	}
+
+	if codigoCliente != "" {
+		query := map[string]interface{}{
+			"query": esquery.Term("codigo_cliente", codigoCliente).Map(),
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(query); err != nil {
+			return 0, wrapAppError(ErrCodeInternal, err, "erro ao codificar query")
+		}
+		opts = append(opts, ec.client.Count.WithBody(&buf))
+	}
+
+	res, err := ec.client.Count(opts...)
+	if err != nil {
+		return 0, transportError(err, "contar documentos")
+	}
+	defer res.Body.Close()
+
+	var result CountResponse
+	if err := decodeResponse(res, "contar documentos", &result); err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
+// CustomerBalance soma valores originais, cancelamentos e negociações de um cliente no
+// período; com AsOf as somas nested só consideram eventos datados até aquela data
+func (ec *ElasticsearchClient) CustomerBalance(ctx context.Context, q BalanceQuery) (*CustomerBalance, error) {
+	query := map[string]interface{}{
+		"size":  0,
+		"query": customerFilter(q.CodigoCliente, q.DataInicio, q.DataFim).Query().Map(),
+		"aggs": map[string]interface{}{
+			"resultado": map[string]interface{}{
+				"filters": map[string]interface{}{
+					"filters": map[string]interface{}{
+						"all": map[string]interface{}{
+							"match_all": map[string]interface{}{},
+						},
+					},
+				},
+				"aggs": balanceAggregationsDSL(q.AsOf),
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := result.total()
+	if err != nil {
+		return nil, err
+	}
+
+	var resultado struct {
+		Buckets struct {
+			All *balanceAggregations `json:"all"`
+		} `json:"buckets"`
+	}
+	if err := result.aggregation("resultado", &resultado); err != nil {
+		return nil, err
+	}
+	if resultado.Buckets.All == nil {
+		return nil, newAppError(ErrCodeMalformedResponse, "agregação 'resultado' sem o bucket 'all'")
+	}
+
+	balance, err := resultado.Buckets.All.toBalance()
+	if err != nil {
+		return nil, err
+	}
+	balance.CodigoCliente = q.CodigoCliente
+	balance.TotalRecebiveis = total
+	return balance, nil
+}
+
+// GroupByCustomer conta recebíveis agrupados por cliente, do maior para o menor
+func (ec *ElasticsearchClient) GroupByCustomer(ctx context.Context, query GroupByCustomerQuery) ([]CustomerStats, error) {
+	body := map[string]interface{}{
+		"size":  0,
+		"query": customerFilter("", query.DataInicio, query.DataFim).Query().Map(),
+		"aggs": map[string]interface{}{
+			"total_por_cliente": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "codigo_cliente",
+					"size":  query.Size,
+					"order": map[string]interface{}{
+						"_count": "desc",
+					},
+				},
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var porCliente TermsAggregation
+	if err := result.aggregation("total_por_cliente", &porCliente); err != nil {
+		return nil, err
+	}
+
+	stats := make([]CustomerStats, len(porCliente.Buckets))
+	for i, bucket := range porCliente.Buckets {
+		stats[i] = CustomerStats{
+			CodigoCliente:   bucket.keyString(),
+			TotalRecebiveis: bucket.DocCount,
+		}
+	}
+
+	return stats, nil
+}
+
+// Aging soma os recebíveis por faixa de atraso com uma date_range aggregation sobre
+// data_vencimento; cada faixa carrega as somas de balanceAggregationsDSL
+func (ec *ElasticsearchClient) Aging(ctx context.Context, query AgingQuery) ([]AgingBucket, error) {
+	referencia, err := time.Parse(domain.LayoutData, query.DataReferencia)
+	if err != nil {
+		return nil, newAppError(ErrCodeInvalidArgument, "data de referência inválida '%s'", query.DataReferencia)
+	}
+	buckets := newAgingBuckets(referencia)
+
+	ranges := make([]map[string]interface{}, len(buckets))
+	for i, bucket := range buckets {
+		faixa := map[string]interface{}{"key": bucket.Faixa}
+		if bucket.DataDe != "" {
+			faixa["from"] = bucket.DataDe
+		}
+		if bucket.DataAte != "" {
+			// O to da date_range é exclusivo: a faixa vai até o fim de DataAte
+			ate, _ := time.Parse(domain.LayoutData, bucket.DataAte)
+			faixa["to"] = ate.AddDate(0, 0, 1).Format(domain.LayoutData)
+		}
+		ranges[i] = faixa
+	}
+
+	body := map[string]interface{}{
+		"size":  0,
+		"query": customerFilter(query.CodigoCliente, "", "").Query().Map(),
+		"aggs": map[string]interface{}{
+			"aging": map[string]interface{}{
+				"date_range": map[string]interface{}{
+					"field":  "data_vencimento",
+					"format": "yyyy-MM-dd",
+					"keyed":  true,
+					"ranges": ranges,
+				},
+				"aggs": balanceAggregationsDSL(""),
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var aging struct {
+		Buckets map[string]*balanceBucket `json:"buckets"`
+	}
+	if err := result.aggregation("aging", &aging); err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		bucket, ok := aging.Buckets[buckets[i].Faixa]
+		if !ok {
+			return nil, newAppError(ErrCodeMalformedResponse, "agregação 'aging' sem a faixa '%s'", buckets[i].Faixa)
+		}
+		if buckets[i].Totais, err = bucket.totals(); err != nil {
+			return nil, err
+		}
+	}
+	return buckets, nil
+}
+
+// BalanceSeries soma os recebíveis por intervalo com uma date_histogram sobre data_vencimento.
+// extended_bounds e min_doc_count 0 fazem os intervalos vazios voltarem zerados.
+func (ec *ElasticsearchClient) BalanceSeries(ctx context.Context, query BalanceSeriesQuery) ([]BalanceSeriesBucket, error) {
+	buckets, err := newSeriesBuckets(query)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"size":  0,
+		"query": customerFilter(query.CodigoCliente, query.DataInicio, query.DataFim).Query().Map(),
+		"aggs": map[string]interface{}{
+			"serie": map[string]interface{}{
+				"date_histogram": map[string]interface{}{
+					"field":             "data_vencimento",
+					"calendar_interval": query.Intervalo.calendarInterval(),
+					"format":            "yyyy-MM-dd",
+					"min_doc_count":     0,
+					"extended_bounds": map[string]interface{}{
+						"min": query.DataInicio,
+						"max": query.DataFim,
+					},
+				},
+				"aggs": balanceAggregationsDSL(""),
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var serie struct {
+		Buckets []struct {
+			KeyAsString string `json:"key_as_string"`
+			balanceBucket
+		} `json:"buckets"`
+	}
+	if err := result.aggregation("serie", &serie); err != nil {
+		return nil, err
+	}
+
+	porInicio := make(map[string]int, len(buckets))
+	for i, bucket := range buckets {
+		porInicio[bucket.Periodo.Inicio] = i
+	}
+	for _, bucket := range serie.Buckets {
+		i, ok := porInicio[bucket.KeyAsString]
+		if !ok {
+			return nil, newAppError(ErrCodeMalformedResponse, "agregação 'serie' com intervalo inesperado '%s'", bucket.KeyAsString)
+		}
+		if buckets[i].Totais, err = bucket.totals(); err != nil {
+			return nil, err
+		}
+	}
+	return buckets, nil
+}
+
+// Breakdown agrupa os recebíveis por combinação das dimensões com uma composite aggregation,
+// paginada pelo after_key até trazer todos os grupos (no máximo maxBreakdownGroups)
+func (ec *ElasticsearchClient) Breakdown(ctx context.Context, query BreakdownQuery) ([]BreakdownGroup, error) {
+	var groups []BreakdownGroup
+	var after map[string]interface{}
+	for {
+		buckets, next, err := ec.compositeBalancePage(ctx, query.Filter.Query(), query.Dimensions, compositePageSize, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range buckets {
+			valores := make([]interface{}, len(query.Dimensions))
+			for i, dimension := range query.Dimensions {
+				valores[i] = bucket.Key[dimension]
+			}
+			group := newBreakdownGroup(query.Dimensions, valores)
+			if group.Totais, err = bucket.totals(); err != nil {
+				return nil, err
+			}
+			groups = append(groups, group)
+		}
+		if len(groups) > maxBreakdownGroups {
+			return nil, newAppError(ErrCodeInvalidArgument, "o breakdown gera mais de %d grupos; restrinja o filtro ou use menos dimensões", maxBreakdownGroups)
+		}
+		if next == nil {
+			return groups, nil
+		}
+		after = next
+	}
+}
+
+// CustomerGroups soma os recebíveis por cliente com uma página da composite aggregation;
+// query.After vira o after da composite (o after_key tem só o codigo_cliente)
+func (ec *ElasticsearchClient) CustomerGroups(ctx context.Context, query CustomerGroupsQuery) ([]CustomerGroup, error) {
+	var after map[string]interface{}
+	if query.After != "" {
+		after = map[string]interface{}{"codigo_cliente": query.After}
+	}
+	buckets, _, err := ec.compositeBalancePage(ctx, query.Filter.Query(), []string{"codigo_cliente"}, query.Size, after)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]CustomerGroup, len(buckets))
+	for i, bucket := range buckets {
+		groups[i].CodigoCliente = fmt.Sprint(bucket.Key["codigo_cliente"])
+		if groups[i].Totais, err = bucket.totals(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// topCustomersSort é o caminho do bucket_sort para cada métrica somada por ela mesma; o
+// saldo é o bucket_script "saldo"
+var topCustomersSort = map[TopCustomerMetric]string{
+	MetricCount:         "_count",
+	MetricOriginalValue: "soma_valores_originais",
+	MetricBalance:       "saldo",
+	MetricCancelled:     "soma_cancelamentos>total_cancelado",
+	MetricNegotiated:    "soma_negociacoes>total_negociado",
+}
+
+// TopCustomers ordena os clientes com uma terms aggregation sobre codigo_cliente. Os clientes
+// ficam espalhados entre os shards pelo routing de id_pagamento, e uma terms de size N ordenada
+// por uma soma pode errar o ranking; por isso a terms traz todos os clientes do filtro (até
+// maxTopCustomersScan) e um bucket_sort ordena e corta em N, como no teste de performance do
+// top 10. O saldo é um bucket_script calculado por cliente.
+func (ec *ElasticsearchClient) TopCustomers(ctx context.Context, query TopCustomersQuery) ([]CustomerGroup, error) {
+	aggs := balanceAggregationsDSL("")
+	aggs["saldo"] = map[string]interface{}{
+		"bucket_script": map[string]interface{}{
+			"buckets_path": map[string]interface{}{
+				"valores":       "soma_valores_originais",
+				"cancelamentos": "soma_cancelamentos>total_cancelado",
+				"negociacoes":   "soma_negociacoes>total_negociado",
+			},
+			"script": "params.valores - params.cancelamentos - params.negociacoes",
+		},
+	}
+	aggs["ranking"] = map[string]interface{}{
+		"bucket_sort": map[string]interface{}{
+			"sort": []map[string]interface{}{
+				{topCustomersSort[query.Metric]: map[string]interface{}{"order": "desc"}},
+				{"_key": map[string]interface{}{"order": "asc"}},
+			},
+			"size": query.N,
+		},
+	}
+
+	body := map[string]interface{}{
+		"size":  0,
+		"query": query.Filter.Query().Map(),
+		"aggs": map[string]interface{}{
+			"clientes": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "codigo_cliente",
+					"size":  maxTopCustomersScan,
+				},
+				"aggs": aggs,
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientes struct {
+		SumOtherDocCount int `json:"sum_other_doc_count"`
+		Buckets          []struct {
+			Key interface{} `json:"key"`
+			balanceBucket
+		} `json:"buckets"`
+	}
+	if err := result.aggregation("clientes", &clientes); err != nil {
+		return nil, err
+	}
+	if clientes.SumOtherDocCount > 0 {
+		return nil, newAppError(ErrCodeInvalidArgument, "o filtro tem mais de %d clientes; restrinja o filtro para montar o ranking", maxTopCustomersScan)
+	}
+
+	groups := make([]CustomerGroup, len(clientes.Buckets))
+	for i, bucket := range clientes.Buckets {
+		groups[i].CodigoCliente = TermsBucket{Key: bucket.Key}.keyString()
+		if groups[i].Totais, err = bucket.totals(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// compositeBalanceBucket é um bucket da composite aggregation com as somas de saldo
+type compositeBalanceBucket struct {
+	Key map[string]interface{} `json:"key"`
+	balanceBucket
+}
+
+// compositePageSize é o tamanho de página usado ao percorrer uma composite aggregation inteira
+const compositePageSize = 1000
+
+// compositeBalancePage busca uma página da composite aggregation "grupos", com uma fonte
+// terms por campo e as somas de balanceAggregationsDSL em cada bucket. Retorna o after_key
+// da página seguinte, ou nil se esta for a última.
+func (ec *ElasticsearchClient) compositeBalancePage(ctx context.Context, query esquery.Query, fields []string, size int, after map[string]interface{}) ([]compositeBalanceBucket, map[string]interface{}, error) {
+	sources := make([]map[string]interface{}, len(fields))
+	for i, field := range fields {
+		sources[i] = map[string]interface{}{
+			field: map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": field,
+				},
+			},
+		}
+	}
+	composite := map[string]interface{}{
+		"size":    size,
+		"sources": sources,
+	}
+	if after != nil {
+		composite["after"] = after
+	}
+
+	body := map[string]interface{}{
+		"size":  0,
+		"query": query.Map(),
+		"aggs": map[string]interface{}{
+			"grupos": map[string]interface{}{
+				"composite": composite,
+				"aggs":      balanceAggregationsDSL(""),
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var grupos struct {
+		AfterKey map[string]interface{}   `json:"after_key"`
+		Buckets  []compositeBalanceBucket `json:"buckets"`
+	}
+	if err := result.aggregation("grupos", &grupos); err != nil {
+		return nil, nil, err
+	}
+	// O after_key vem mesmo na última página; uma página incompleta encerra a paginação
+	if len(grupos.Buckets) < size {
+		return grupos.Buckets, nil, nil
+	}
+	return grupos.Buckets, grupos.AfterKey, nil
+}
+
+// PaymentIDs lista os id_pagamento distintos de um cliente com uma terms aggregation
+func (ec *ElasticsearchClient) PaymentIDs(ctx context.Context, codigoCliente string, size int) ([]string, error) {
+	body := map[string]interface{}{
+		"size":  0,
+		"query": customerFilter(codigoCliente, "", "").Query().Map(),
+		"aggs": map[string]interface{}{
+			"pagamentos": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "id_pagamento",
+					"size":  size,
+					"order": map[string]interface{}{
+						"_key": "asc",
+					},
+				},
+			},
+		},
+	}
+
+	result, err := ec.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var pagamentos TermsAggregation
+	if err := result.aggregation("pagamentos", &pagamentos); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(pagamentos.Buckets))
+	for i, bucket := range pagamentos.Buckets {
+		ids[i] = bucket.keyString()
+	}
+	return ids, nil
+}
+
+// CreateReceivable grava um recebível novo, roteado por id_pagamento como faz o seeder
+func (ec *ElasticsearchClient) CreateReceivable(ctx context.Context, recebivel *domain.Recebivel) error {
+	return ec.writeReceivable(ctx, recebivel, "create")
+}
+
+// ReplaceReceivable substitui o documento de um recebível, roteado por id_pagamento; com
+// recebivel.Versao a escrita leva if_seq_no/if_primary_term e falha com CONFLICT se o
+// documento mudou depois da leitura
+func (ec *ElasticsearchClient) ReplaceReceivable(ctx context.Context, recebivel *domain.Recebivel) error {
+	return ec.writeReceivable(ctx, recebivel, "index")
+}
+
+// writeReceivable indexa o documento completo do recebível com o op_type informado
+// e o saldo_disponivel recalculado. Na volta recebivel.Versao é a versão gravada.
+func (ec *ElasticsearchClient) writeReceivable(ctx context.Context, recebivel *domain.Recebivel, opType string) error {
+	recebivel.UpdateSaldoDisponivel()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(recebivel); err != nil {
+		return wrapAppError(ErrCodeInternal, err, "erro ao codificar recebível")
+	}
+
+	id := recebivel.DocumentID()
+	var antes json.RawMessage
+	if opType == "index" {
+		// Com Versao, se a escrita passar o documento lido aqui é exatamente o substituído
+		var err error
+		if antes, _, err = ec.snapshot(ctx, ec.index, id, recebivel.IDPagamento); err != nil {
+			return err
+		}
+	}
+	depois := json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+
+	req := esapi.IndexRequest{
+		Index:      ec.index,
+		DocumentID: id,
+		Body:       &buf,
+		OpType:     opType,
+		Routing:    recebivel.IDPagamento,
+		Refresh:    "true",
+	}
+	if opType == "index" {
+		req.IfSeqNo = ifSeqNo(recebivel.Versao)
+		req.IfPrimaryTerm = ifPrimaryTerm(recebivel.Versao)
+	}
+
+	res, err := req.Do(ctx, ec.client)
+	if err != nil {
+		return transportError(err, "gravar recebível")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict && opType == "create" {
+		return newAppError(ErrCodeAlreadyExists, "recebível '%s' já existe", id)
+	}
+	if res.StatusCode == http.StatusConflict && recebivel.Versao != nil {
+		return versionConflict(id, recebivel.Versao)
+	}
+
+	var written UpdateResponse
+	if err := decodeResponse(res, "gravar recebível", &written); err != nil {
+		return err
+	}
+
+	recebivel.Versao = written.versao()
+	ec.routes.put(ec.index, id, recebivel.IDPagamento)
+	audit(ctx, ec, opType, ec.index, id, recebivel.IDPagamento, antes, depois)
+	return nil
+}
+
+// PageReceivables pagina os recebíveis com search_after, sempre com desempate por
+// id_recebivel. A primeira página é uma busca simples, sem point-in-time, para um cliente
+// que lê uma página só não deixar um pit aberto; o pit só é aberto quando o cursor é usado,
+// e segue dentro dele para as páginas seguintes verem o mesmo snapshot do índice.
+func (ec *ElasticsearchClient) PageReceivables(ctx context.Context, query ReceivableQuery, page PageRequest) (*ReceivablePage, error) {
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, err
+	}
+
+	pitID := cursor.PitID
+	opened := false
+	if pitID == "" && len(cursor.After) > 0 {
+		if pitID, err = ec.openPointInTime(ctx); err != nil {
+			return nil, err
+		}
+		opened = true
+	}
+
+	total, edges, pitID, err := ec.searchReceivablePage(ctx, query, page.First, cursor.After, pitID)
+	if err != nil {
+		if opened {
+			ec.closePointInTime(context.WithoutCancel(ctx), pitID)
+		}
+		if cursor.PitID != "" && errorCode(err) == ErrCodeNotFound {
+			return nil, wrapAppError(ErrCodeInvalidArgument, err, "cursor expirado: recomece a paginação sem after")
+		}
+		return nil, err
+	}
+
+	receivablePage := newReceivablePage(total, edges, page)
+	if pitID != "" && !receivablePage.PageInfo.HasNextPage {
+		// Última página: o pit não será mais usado
+		ec.closePointInTime(ctx, pitID)
+	}
+	return receivablePage, nil
+}
+
+// searchReceivablePage busca até size+1 recebíveis depois de after (para saber se há
+// próxima página). Com pitID a busca roda no point-in-time e devolve o id atualizado dele,
+// que vai nos cursores dos edges.
+func (ec *ElasticsearchClient) searchReceivablePage(ctx context.Context, query ReceivableQuery, size int, after []interface{}, pitID string) (int, []ReceivableEdge, string, error) {
+	body := map[string]interface{}{
+		"query":            query.Filter.Query().Map(),
+		"size":             size + 1,
+		"sort":             sortDSL(withTiebreaker(query.Sort)),
+		"track_total_hits": true,
+	}
+	if len(after) > 0 {
+		body["search_after"] = after
+	}
+	indexName := ec.index
+	if pitID != "" {
+		// Com pit o índice vem do próprio pit
+		indexName = ""
+		body["pit"] = map[string]interface{}{
+			"id":         pitID,
+			"keep_alive": pitKeepAlive,
+		}
+	}
+
+	result, err := ec.searchIndex(ctx, indexName, "", body)
+	if err != nil {
+		return 0, nil, pitID, err
+	}
+	if pitID != "" && result.PitID != "" {
+		pitID = result.PitID
+	}
+
+	total, err := result.total()
+	if err != nil {
+		return 0, nil, pitID, err
+	}
+
+	edges := make([]ReceivableEdge, len(result.Hits.Hits))
+	for i, hit := range result.Hits.Hits {
+		recebivel, err := decodeRecebivel(hit.Source, hit.ID)
+		if err != nil {
+			return 0, nil, pitID, err
+		}
+		edges[i] = ReceivableEdge{
+			Cursor: encodeCursor(pageCursor{PitID: pitID, After: hit.Sort}),
+			Node:   recebivel,
+		}
+	}
+	return total, edges, pitID, nil
+}
+
+// openPointInTime abre um point-in-time no índice de recebíveis
+func (ec *ElasticsearchClient) openPointInTime(ctx context.Context) (string, error) {
+	res, err := ec.client.OpenPointInTime(
+		[]string{ec.index},
+		pitKeepAlive,
+		ec.client.OpenPointInTime.WithContext(ctx),
+	)
+	if err != nil {
+		return "", transportError(err, "abrir point-in-time")
+	}
+	defer res.Body.Close()
+
+	var result PointInTimeResponse
+	if err := decodeResponse(res, "abrir point-in-time", &result); err != nil {
+		return "", err
+	}
+	if result.ID == "" {
+		return "", newAppError(ErrCodeMalformedResponse, "resposta de point-in-time sem id")
+	}
+	return result.ID, nil
+}
+
+// closePointInTime fecha o point-in-time; falhas só são registradas, pois o pit expira sozinho
+func (ec *ElasticsearchClient) closePointInTime(ctx context.Context, pitID string) {
+	body, _ := json.Marshal(map[string]interface{}{"id": pitID})
+	res, err := ec.client.ClosePointInTime(
+		ec.client.ClosePointInTime.WithContext(ctx),
+		ec.client.ClosePointInTime.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		log.Printf("⚠️  Erro ao fechar point-in-time: %v\n", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		log.Printf("⚠️  Erro ao fechar point-in-time: %v\n", decodeError(res, "fechar point-in-time"))
+	}
+}
+
+// scanPageSize é o tamanho de cada página lida por ScanReceivables
+const scanPageSize = 1000
+
+// ScanReceivables percorre os recebíveis que atendem aos filtros página a página,
+// no mesmo point-in-time, em ordem de id_recebivel. O pit é fechado ao sair, inclusive
+// quando fn ou uma página falham.
+func (ec *ElasticsearchClient) ScanReceivables(ctx context.Context, query ReceivableQuery, fn func(*domain.Recebivel) error) error {
+	query.Sort = nil
+	pitID, err := ec.openPointInTime(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ec.closePointInTime(context.WithoutCancel(ctx), pitID)
+	}()
+
+	var after []interface{}
+	for {
+		var edges []ReceivableEdge
+		_, edges, pitID, err = ec.searchReceivablePage(ctx, query, scanPageSize, after, pitID)
+		if err != nil {
+			return err
+		}
+
+		for i, edge := range edges {
+			if i == scanPageSize {
+				break
+			}
+			if err := fn(edge.Node); err != nil {
+				return err
+			}
+		}
+
+		if len(edges) <= scanPageSize {
+			return nil
+		}
+		last, err := decodeCursor(edges[scanPageSize-1].Cursor)
+		if err != nil {
+			return err
+		}
+		after = last.After
+	}
+}
+
+// sortDSL traduz a ordenação para a cláusula sort do Elasticsearch
+func sortDSL(sortFields []SortField) []map[string]interface{} {
+	sort := make([]map[string]interface{}, len(sortFields))
+	for i, s := range sortFields {
+		order := "asc"
+		if s.Desc {
+			order = "desc"
+		}
+		sort[i] = map[string]interface{}{
+			s.Field: map[string]interface{}{
+				"order": order,
+			},
+		}
+	}
+	return sort
+}
+
+// balanceAggregationsDSL monta as sub-agregações somadas no cálculo de saldo: valor_original
+// e as somas nested de cancelamentos e negociações (decodificadas em balanceAggregations).
+// Com asOf as somas nested passam por um filter que só deixa os eventos datados até ele.
+func balanceAggregationsDSL(asOf string) map[string]interface{} {
+	return map[string]interface{}{
+		"soma_valores_originais": map[string]interface{}{
+			"sum": map[string]interface{}{
+				"field": "valor_original",
+			},
+		},
+		"soma_cancelamentos": map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path": "cancelamentos",
+			},
+			"aggs": eventSumDSL("total_cancelado", "cancelamentos.valor_cancelado", "cancelamentos.data_cancelamento", asOf),
+		},
+		"soma_negociacoes": map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path": "negociacoes",
+			},
+			"aggs": eventSumDSL("total_negociado", "negociacoes.valor_negociado", "negociacoes.data_negociacao", asOf),
+		},
+	}
+}
+
+// eventSumDSL monta a soma de um campo dos eventos nested; com asOf a soma fica dentro
+// de uma filter aggregation "ate_data" com os eventos datados até asOf (inclusive)
+func eventSumDSL(name, valueField, dateField, asOf string) map[string]interface{} {
+	sum := map[string]interface{}{
+		name: map[string]interface{}{
+			"sum": map[string]interface{}{
+				"field": valueField,
+			},
+		},
+	}
+	if asOf == "" {
+		return sum
+	}
+	return map[string]interface{}{
+		"ate_data": map[string]interface{}{
+			"filter": esquery.Range(dateField).Lte(asOf).Map(),
+			"aggs":   sum,
+		},
+	}
+}
+
+// balanceAggregations representa as somas usadas no cálculo de saldo
+type balanceAggregations struct {
+	SomaValoresOriginais *ValueAggregation `json:"soma_valores_originais"`
+	SomaCancelamentos    struct {
+		TotalCancelado *ValueAggregation `json:"total_cancelado"`
+		AteData        *struct {
+			TotalCancelado *ValueAggregation `json:"total_cancelado"`
+		} `json:"ate_data"` // presente nas somas com as_of
+	} `json:"soma_cancelamentos"`
+	SomaNegociacoes struct {
+		TotalNegociado *ValueAggregation `json:"total_negociado"`
+		AteData        *struct {
+			TotalNegociado *ValueAggregation `json:"total_negociado"`
+		} `json:"ate_data"` // presente nas somas com as_of
+	} `json:"soma_negociacoes"`
+}
+
+// toBalance valida as somas e monta o CustomerBalance correspondente
+func (ba *balanceAggregations) toBalance() (*CustomerBalance, error) {
+	valorOriginal, err := ba.SomaValoresOriginais.value("soma_valores_originais")
+	if err != nil {
+		return nil, err
+	}
+	totalCancelado := ba.SomaCancelamentos.TotalCancelado
+	if ba.SomaCancelamentos.AteData != nil {
+		totalCancelado = ba.SomaCancelamentos.AteData.TotalCancelado
+	}
+	cancelado, err := totalCancelado.value("total_cancelado")
+	if err != nil {
+		return nil, err
+	}
+	totalNegociado := ba.SomaNegociacoes.TotalNegociado
+	if ba.SomaNegociacoes.AteData != nil {
+		totalNegociado = ba.SomaNegociacoes.AteData.TotalNegociado
+	}
+	negociado, err := totalNegociado.value("total_negociado")
+	if err != nil {
+		return nil, err
+	}
+
+	// Com o mapping scaled_float as somas já são centavos exatos; o arredondamento
+	// só descarta o ruído da conversão para double na resposta
+	return &CustomerBalance{
+		ValorOriginal:  domain.MoneyFromFloat(valorOriginal),
+		TotalCancelado: domain.MoneyFromFloat(cancelado),
+		TotalNegociado: domain.MoneyFromFloat(negociado),
+	}, nil
+}
+
+// balanceBucket é um bucket de agregação com as sub-agregações de balanceAggregationsDSL
+type balanceBucket struct {
+	DocCount int `json:"doc_count"`
+	balanceAggregations
+}
+
+// totals converte as somas do bucket em BalanceTotals
+func (bb *balanceBucket) totals() (BalanceTotals, error) {
+	balance, err := bb.toBalance()
+	if err != nil {
+		return BalanceTotals{}, err
+	}
+	return BalanceTotals{
+		TotalRecebiveis: bb.DocCount,
+		ValorOriginal:   balance.ValorOriginal,
+		TotalCancelado:  balance.TotalCancelado,
+		TotalNegociado:  balance.TotalNegociado,
+		Saldo:           balance.Saldo(),
+	}, nil
+}
+
+// decodeRecebivel decodifica o _source de um documento no modelo de domínio
+func decodeRecebivel(source json.RawMessage, id string) (*domain.Recebivel, error) {
+	if len(source) == 0 {
+		return nil, newAppError(ErrCodeMalformedResponse, "documento '%s' sem _source na resposta", id)
+	}
+
+	var recebivel domain.Recebivel
+	if err := json.Unmarshal(source, &recebivel); err != nil {
+		return nil, wrapAppError(ErrCodeMalformedResponse, err, "documento '%s' fora do modelo de recebível", id)
+	}
+	recebivel.ID = id
+	return &recebivel, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"data-aggregator/domain"
+)
+
+// indiceTeste é o índice de recebíveis do Elasticsearch falso
+const indiceTeste = "recebiveis-teste"
+
+// fakeElasticsearch responde às requisições do cliente com handle e guarda "MÉTODO caminho"
+// de cada uma, na ordem em que chegaram
+type fakeElasticsearch struct {
+	mu       sync.Mutex
+	requests []string
+	handle   func(method, path string, body []byte) (int, interface{})
+}
+
+// newFakeElasticsearch sobe o Elasticsearch falso e conecta um ElasticsearchClient a ele
+func newFakeElasticsearch(t *testing.T, config ElasticsearchConfig, handle func(method, path string, body []byte) (int, interface{})) (*ElasticsearchClient, *fakeElasticsearch) {
+	t.Helper()
+	fake := &fakeElasticsearch{handle: handle}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		status, response := http.StatusOK, interface{}(map[string]interface{}{})
+		if r.URL.Path != "/" {
+			fake.mu.Lock()
+			fake.requests = append(fake.requests, r.Method+" "+r.URL.Path)
+			fake.mu.Unlock()
+			status, response = fake.handle(r.Method, r.URL.Path, body)
+		}
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	config.Addresses = []string{server.URL}
+	config.Index = indiceTeste
+	client, err := NewElasticsearchClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, fake
+}
+
+// count conta as requisições recebidas com o método e o caminho
+func (f *fakeElasticsearch) count(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+// esError é o corpo de erro do Elasticsearch
+func esError(status int, tipo string) (int, interface{}) {
+	return status, map[string]interface{}{
+		"error":  map[string]interface{}{"type": tipo, "reason": tipo},
+		"status": status,
+	}
+}
+
+// pitFake responde ao point-in-time e às buscas ordenadas por id_recebivel sobre n
+// recebíveis rec-0001..rec-n; falhar faz as buscas com pit devolverem erro
+type pitFake struct {
+	n      int
+	falhar bool
+}
+
+func (p *pitFake) handle(method, path string, body []byte) (int, interface{}) {
+	switch {
+	case method == http.MethodPost && path == "/"+indiceTeste+"/_pit":
+		return http.StatusOK, map[string]interface{}{"id": "pit-1"}
+	case method == http.MethodDelete && path == "/_pit":
+		return http.StatusOK, map[string]interface{}{"succeeded": true, "num_freed": 1}
+	case strings.HasSuffix(path, "/_search"):
+		var req struct {
+			Size        int                    `json:"size"`
+			SearchAfter []string               `json:"search_after"`
+			Pit         map[string]interface{} `json:"pit"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return esError(http.StatusBadRequest, "parsing_exception")
+		}
+		if (req.Pit != nil) != (path == "/_search") {
+			return esError(http.StatusBadRequest, "illegal_argument_exception")
+		}
+		if req.Pit != nil && p.falhar {
+			return esError(http.StatusInternalServerError, "search_phase_execution_exception")
+		}
+		var hits []map[string]interface{}
+		for i := 1; i <= p.n && len(hits) < req.Size; i++ {
+			id := fmt.Sprintf("rec-%04d", i)
+			if len(req.SearchAfter) > 0 && id <= req.SearchAfter[0] {
+				continue
+			}
+			hits = append(hits, map[string]interface{}{
+				"_index":  indiceTeste,
+				"_id":     id,
+				"_source": map[string]interface{}{"id_recebivel": id, "id_pagamento": "pag-1", "valor_original": 10},
+				"sort":    []interface{}{id},
+			})
+		}
+		response := map[string]interface{}{
+			"hits": map[string]interface{}{
+				"total": map[string]interface{}{"value": p.n, "relation": "eq"},
+				"hits":  hits,
+			},
+		}
+		if req.Pit != nil {
+			response["pit_id"] = req.Pit["id"]
+		}
+		return http.StatusOK, response
+	}
+	return esError(http.StatusNotFound, "not_found")
+}
+
+// ids devolve os id_recebivel dos edges
+func (p *ReceivablePage) ids() []string {
+	ids := make([]string, len(p.Edges))
+	for i, edge := range p.Edges {
+		ids[i] = edge.Node.IDRecebivel
+	}
+	return ids
+}
+
+// TestPageReceivablesPointInTime confere que a primeira página não abre point-in-time, que
+// o pit é aberto quando o cursor é usado e fechado na última página ou quando a busca falha
+func TestPageReceivablesPointInTime(t *testing.T) {
+	ctx := context.Background()
+	fake := &pitFake{n: 5}
+	client, es := newFakeElasticsearch(t, ElasticsearchConfig{}, fake.handle)
+
+	first, err := client.PageReceivables(ctx, ReceivableQuery{}, PageRequest{First: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := first.ids(); !reflect.DeepEqual(got, []string{"rec-0001", "rec-0002"}) || !first.PageInfo.HasNextPage || first.TotalCount != 5 {
+		t.Fatalf("primeira página = %v (próxima %v, total %d)", got, first.PageInfo.HasNextPage, first.TotalCount)
+	}
+	if n := es.count("POST /" + indiceTeste + "/_pit"); n != 0 {
+		t.Fatalf("primeira página abriu %d point-in-time", n)
+	}
+
+	second, err := client.PageReceivables(ctx, ReceivableQuery{}, PageRequest{First: 2, After: first.PageInfo.EndCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.ids(); !reflect.DeepEqual(got, []string{"rec-0003", "rec-0004"}) || !second.PageInfo.HasNextPage {
+		t.Fatalf("segunda página = %v (próxima %v)", got, second.PageInfo.HasNextPage)
+	}
+	if es.count("POST /"+indiceTeste+"/_pit") != 1 || es.count("DELETE /_pit") != 0 {
+		t.Fatalf("segunda página: requisições %v, esperado um pit aberto", es.requests)
+	}
+
+	last, err := client.PageReceivables(ctx, ReceivableQuery{}, PageRequest{First: 2, After: second.PageInfo.EndCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := last.ids(); !reflect.DeepEqual(got, []string{"rec-0005"}) || last.PageInfo.HasNextPage {
+		t.Fatalf("última página = %v (próxima %v)", got, last.PageInfo.HasNextPage)
+	}
+	if es.count("POST /"+indiceTeste+"/_pit") != 1 || es.count("DELETE /_pit") != 1 {
+		t.Fatalf("última página: requisições %v, esperado o pit reaproveitado e fechado", es.requests)
+	}
+
+	// A busca falha logo depois de abrir o pit: ele é fechado
+	fake.falhar = true
+	if _, err := client.PageReceivables(ctx, ReceivableQuery{}, PageRequest{First: 2, After: first.PageInfo.EndCursor}); err == nil {
+		t.Fatal("esperado erro da busca")
+	}
+	if es.count("POST /"+indiceTeste+"/_pit") != 2 || es.count("DELETE /_pit") != 2 {
+		t.Fatalf("busca com erro: requisições %v, esperado o pit novo fechado", es.requests)
+	}
+}
+
+// TestScanReceivablesFechaPointInTime confere que o pit do scan é fechado ao terminar e
+// quando a função interrompe a leitura
+func TestScanReceivablesFechaPointInTime(t *testing.T) {
+	ctx := context.Background()
+	fake := &pitFake{n: scanPageSize + 2}
+	client, es := newFakeElasticsearch(t, ElasticsearchConfig{}, fake.handle)
+
+	lidos := 0
+	err := client.ScanReceivables(ctx, ReceivableQuery{}, func(*domain.Recebivel) error {
+		lidos++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lidos != fake.n || es.count("POST /"+indiceTeste+"/_pit") != 1 || es.count("DELETE /_pit") != 1 {
+		t.Fatalf("scan leu %d de %d; requisições %v", lidos, fake.n, es.requests)
+	}
+
+	parar := errors.New("parar")
+	err = client.ScanReceivables(ctx, ReceivableQuery{}, func(*domain.Recebivel) error {
+		return parar
+	})
+	if !errors.Is(err, parar) {
+		t.Fatalf("erro = %v, esperado o erro da função", err)
+	}
+	if es.count("POST /"+indiceTeste+"/_pit") != 2 || es.count("DELETE /_pit") != 2 {
+		t.Fatalf("scan interrompido: requisições %v, esperado o pit fechado", es.requests)
+	}
+}
+
+// TestResolveRouting confere o routing encontrado pela query ids, o cache e a recusa de um
+// _id encontrado com mais de um routing
+func TestResolveRouting(t *testing.T) {
+	ctx := context.Background()
+	client, es := newFakeElasticsearch(t, ElasticsearchConfig{}, func(method, path string, body []byte) (int, interface{}) {
+		var req struct {
+			Query struct {
+				Ids struct {
+					Values []string `json:"values"`
+				} `json:"ids"`
+			} `json:"query"`
+		}
+		json.Unmarshal(body, &req)
+		var hits []map[string]interface{}
+		for _, routing := range map[string][]string{"rec-1": {"pag-1"}, "rec-2": {"pag-1", "pag-2"}}[req.Query.Ids.Values[0]] {
+			hits = append(hits, map[string]interface{}{"_index": indiceTeste, "_id": req.Query.Ids.Values[0], "_routing": routing})
+		}
+		return http.StatusOK, map[string]interface{}{"hits": map[string]interface{}{"hits": hits}}
+	})
+
+	for i := 0; i < 2; i++ {
+		routing, err := client.resolveRouting(ctx, indiceTeste, "rec-1", "")
+		if err != nil || routing != "pag-1" {
+			t.Fatalf("resolveRouting(rec-1) = %q, %v; esperado pag-1", routing, err)
+		}
+	}
+	if n := es.count("POST /" + indiceTeste + "/_search"); n != 1 {
+		t.Errorf("%d buscas para rec-1, esperado 1 (a segunda vem do cache)", n)
+	}
+	if _, err := client.resolveRouting(ctx, indiceTeste, "rec-2", ""); errorCode(err) != ErrCodeInvalidArgument {
+		t.Errorf("rec-2 com dois routings: erro %v, esperado %s", err, ErrCodeInvalidArgument)
+	}
+	if _, err := client.resolveRouting(ctx, indiceTeste, "rec-3", ""); errorCode(err) != ErrCodeNotFound {
+		t.Errorf("rec-3 inexistente: erro %v, esperado %s", err, ErrCodeNotFound)
+	}
+}
+
+func TestReceivableRouting(t *testing.T) {
+	tests := []struct {
+		nome    string
+		doc     map[string]interface{}
+		routing string
+		want    string
+		erro    bool
+	}{
+		{nome: "sem routing", doc: map[string]interface{}{"id_pagamento": "pag-1"}, want: "pag-1"},
+		{nome: "routing igual", doc: map[string]interface{}{"id_pagamento": "pag-1"}, routing: "pag-1", want: "pag-1"},
+		{nome: "routing diferente", doc: map[string]interface{}{"id_pagamento": "pag-1"}, routing: "pag-2", erro: true},
+		{nome: "sem id_pagamento", doc: map[string]interface{}{"id_recebivel": "rec-1"}, routing: "pag-1", erro: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nome, func(t *testing.T) {
+			got, err := receivableRouting(tt.doc, tt.routing)
+			if tt.erro {
+				if errorCode(err) != ErrCodeInvalidArgument {
+					t.Fatalf("erro = %v, esperado %s", err, ErrCodeInvalidArgument)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("receivableRouting = %q, %v; esperado %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestTargetsIndex confere que um alias e um padrão alcançam o índice por trás deles
+func TestTargetsIndex(t *testing.T) {
+	ctx := context.Background()
+	resolved := map[string]interface{}{
+		"recebiveis": map[string]interface{}{
+			"aliases": []interface{}{map[string]interface{}{"name": "recebiveis", "indices": []string{indiceTeste}}},
+		},
+		indiceTeste: map[string]interface{}{
+			"indices": []interface{}{map[string]interface{}{"name": indiceTeste}},
+		},
+		"receb*": map[string]interface{}{
+			"indices": []interface{}{map[string]interface{}{"name": indiceTeste}},
+			"aliases": []interface{}{map[string]interface{}{"name": "recebiveis", "indices": []string{indiceTeste}}},
+		},
+		"outro": map[string]interface{}{
+			"indices": []interface{}{map[string]interface{}{"name": "outro"}},
+		},
+	}
+	client, _ := newFakeElasticsearch(t, ElasticsearchConfig{}, func(method, path string, body []byte) (int, interface{}) {
+		if response, ok := resolved[strings.TrimPrefix(path, "/_resolve/index/")]; ok {
+			return http.StatusOK, response
+		}
+		return http.StatusOK, map[string]interface{}{}
+	})
+
+	for name, want := range map[string]bool{indiceTeste: true, "recebiveis": true, "receb*": true, "outro": false, "novo": false} {
+		got, err := client.targetsReceivablesIndex(ctx, name)
+		if err != nil || got != want {
+			t.Errorf("targetsReceivablesIndex(%q) = %v, %v; esperado %v", name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"data-aggregator/domain"
+)
+
+// MemoryStore é um ReceivableStore em memória, usado em testes e no modo demo
+type MemoryStore struct {
+	mu        sync.RWMutex
+	documents map[string]domain.Recebivel
+	order     []string
+	seqNo     int64 // _seq_no da última escrita, como o de um shard único
+	audit     []domain.RegistroAuditoria
+}
+
+// NewMemoryStore cria um store em memória vazio
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{documents: make(map[string]domain.Recebivel)}
+}
+
+// LoadMemoryStore cria um store em memória com os recebíveis dos arquivos informados.
+// Cada arquivo pode conter um objeto JSON, um array de objetos ou NDJSON.
+func LoadMemoryStore(paths ...string) (*MemoryStore, error) {
+	store := NewMemoryStore()
+	for _, path := range paths {
+		if err := store.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// loadFile lê os recebíveis de um arquivo
+func (ms *MemoryStore) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir arquivo '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("erro ao decodificar arquivo '%s': %w", path, err)
+		}
+
+		var recebiveis []domain.Recebivel
+		if len(value) > 0 && value[0] == '[' {
+			err = json.Unmarshal(value, &recebiveis)
+		} else {
+			var recebivel domain.Recebivel
+			err = json.Unmarshal(value, &recebivel)
+			recebiveis = append(recebiveis, recebivel)
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao decodificar arquivo '%s': %w", path, err)
+		}
+
+		for _, recebivel := range recebiveis {
+			if err := ms.Put(recebivel); err != nil {
+				return fmt.Errorf("arquivo '%s': %w", path, err)
+			}
+		}
+	}
+}
+
+// memoryAuditIndex é o índice que os registros de auditoria do modo demo apontam
+var memoryAuditIndex = DefaultConfig().Elasticsearch.Index
+
+// Put valida e insere ou substitui um recebível; as cargas do modo demo não são auditadas
+func (ms *MemoryStore) Put(recebivel domain.Recebivel) error {
+	_, err := ms.put(&recebivel, nil)
+	return err
+}
+
+// CreateReceivable grava um recebível novo; falha se o documento já existir
+func (ms *MemoryStore) CreateReceivable(ctx context.Context, recebivel *domain.Recebivel) error {
+	_, err := ms.put(recebivel, func(id string, exists bool) error {
+		if exists {
+			return newAppError(ErrCodeAlreadyExists, "recebível '%s' já existe", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	audit(ctx, ms, "create", memoryAuditIndex, recebivel.ID, recebivel.IDPagamento, nil, snapshotOf(recebivel))
+	return nil
+}
+
+// ReplaceReceivable substitui um recebível existente
+func (ms *MemoryStore) ReplaceReceivable(ctx context.Context, recebivel *domain.Recebivel) error {
+	antes, err := ms.put(recebivel, func(id string, exists bool) error {
+		if !exists {
+			return newAppError(ErrCodeNotFound, "recebível '%s' não encontrado", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	audit(ctx, ms, "index", memoryAuditIndex, recebivel.ID, recebivel.IDPagamento, antes, snapshotOf(recebivel))
+	return nil
+}
+
+// put valida e grava o recebível; check, se informado, decide sob o lock se a escrita é
+// permitida. Com recebivel.Versao a escrita falha com CONFLICT se o documento armazenado
+// estiver em outra versão; na volta recebivel.Versao é a versão gravada. Retorna o
+// documento substituído, para a auditoria, ou nil se o recebível é novo.
+func (ms *MemoryStore) put(recebivel *domain.Recebivel, check func(id string, exists bool) error) (json.RawMessage, error) {
+	if err := recebivel.Validate(); err != nil {
+		return nil, err
+	}
+
+	id := recebivel.DocumentID()
+	recebivel.ID = id
+	recebivel.UpdateSaldoDisponivel()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	atual, exists := ms.documents[id]
+	if check != nil {
+		if err := check(id, exists); err != nil {
+			return nil, err
+		}
+	}
+	if recebivel.Versao != nil && (!exists || atual.Versao == nil || *atual.Versao != *recebivel.Versao) {
+		return nil, versionConflict(id, recebivel.Versao)
+	}
+	var antes json.RawMessage
+	if exists {
+		antes = snapshotOf(atual)
+	} else {
+		ms.order = append(ms.order, id)
+	}
+	recebivel.Versao = ms.nextVersao()
+	ms.documents[id] = copiarRecebivel(*recebivel)
+	return antes, nil
+}
+
+// nextVersao avança o _seq_no do store; o chamador segura o lock de escrita
+func (ms *MemoryStore) nextVersao() *domain.Versao {
+	ms.seqNo++
+	return &domain.Versao{SeqNo: ms.seqNo, PrimaryTerm: 1}
+}
+
+// GetReceivable busca um recebível pelo ID do documento. Como no Elasticsearch, um
+// idPagamento diferente do documento não o encontra (seria outro shard).
+func (ms *MemoryStore) GetReceivable(ctx context.Context, id, idPagamento string) (*domain.Recebivel, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	recebivel, ok := ms.documents[id]
+	if !ok || (idPagamento != "" && recebivel.IDPagamento != idPagamento) {
+		return nil, newAppError(ErrCodeNotFound, "recebível '%s' não encontrado", id)
+	}
+
+	copia := copiarRecebivel(recebivel)
+	return &copia, nil
+}
+
+// SearchReceivables busca recebíveis que atendem aos critérios da query
+func (ms *MemoryStore) SearchReceivables(ctx context.Context, query ReceivableQuery) (*ReceivableSearchResult, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	matches := []*domain.Recebivel{}
+	for _, id := range ms.order {
+		recebivel := ms.documents[id]
+		if query.Filter.Match(&recebivel) {
+			copia := copiarRecebivel(recebivel)
+			matches = append(matches, &copia)
+		}
+	}
+
+	if len(query.Sort) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return compararOrdenacao(valoresOrdenacao(matches[i], query.Sort), valoresOrdenacao(matches[j], query.Sort), query.Sort) < 0
+		})
+	}
+
+	total := len(matches)
+	start := query.From
+	if start > total {
+		start = total
+	}
+	end := start + query.Size
+	if end > total {
+		end = total
+	}
+
+	return &ReceivableSearchResult{
+		Total:       total,
+		Receivables: matches[start:end],
+	}, nil
+}
+
+// PageReceivables pagina os recebíveis da query pelo cursor, com desempate por id_recebivel
+func (ms *MemoryStore) PageReceivables(ctx context.Context, query ReceivableQuery, page PageRequest) (*ReceivablePage, error) {
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(page.After)
+	if err != nil {
+		return nil, err
+	}
+	sortFields := withTiebreaker(query.Sort)
+
+	ms.mu.RLock()
+	matches := []*domain.Recebivel{}
+	for _, id := range ms.order {
+		recebivel := ms.documents[id]
+		if query.Filter.Match(&recebivel) {
+			copia := copiarRecebivel(recebivel)
+			matches = append(matches, &copia)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return compararOrdenacao(valoresOrdenacao(matches[i], sortFields), valoresOrdenacao(matches[j], sortFields), sortFields) < 0
+	})
+
+	edges := []ReceivableEdge{}
+	for _, recebivel := range matches {
+		valores := valoresOrdenacao(recebivel, sortFields)
+		if len(cursor.After) > 0 && compararOrdenacao(valores, cursor.After, sortFields) <= 0 {
+			continue
+		}
+		edges = append(edges, ReceivableEdge{
+			Cursor: encodeCursor(pageCursor{After: valores}),
+			Node:   recebivel,
+		})
+		if len(edges) > page.First {
+			break
+		}
+	}
+
+	return newReceivablePage(len(matches), edges, page), nil
+}
+
+// CountReceivables conta os recebíveis de um cliente (ou de todo o índice se vazio)
+func (ms *MemoryStore) CountReceivables(ctx context.Context, codigoCliente string) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	count := 0
+	for _, recebivel := range ms.documents {
+		if customerFilter(codigoCliente, "", "").Match(&recebivel) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CustomerBalance soma valores originais, cancelamentos e negociações de um cliente no
+// período; com AsOf só os eventos datados até aquela data
+func (ms *MemoryStore) CustomerBalance(ctx context.Context, query BalanceQuery) (*CustomerBalance, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	balance := &CustomerBalance{CodigoCliente: query.CodigoCliente}
+	filter := customerFilter(query.CodigoCliente, query.DataInicio, query.DataFim)
+	for _, documento := range ms.documents {
+		if !filter.Match(&documento) {
+			continue
+		}
+		recebivel := documento.AsOf(query.AsOf)
+		balance.TotalRecebiveis++
+		balance.ValorOriginal += recebivel.ValorOriginal
+		balance.TotalCancelado += recebivel.TotalCancelado()
+		balance.TotalNegociado += recebivel.TotalNegociado()
+	}
+	return balance, nil
+}
+
+// ScanReceivables percorre os recebíveis que atendem aos filtros, em ordem de id_recebivel
+func (ms *MemoryStore) ScanReceivables(ctx context.Context, query ReceivableQuery, fn func(*domain.Recebivel) error) error {
+	ms.mu.RLock()
+	matches := []*domain.Recebivel{}
+	for _, recebivel := range ms.documents {
+		if query.Filter.Match(&recebivel) {
+			copia := copiarRecebivel(recebivel)
+			matches = append(matches, &copia)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].IDRecebivel < matches[j].IDRecebivel
+	})
+
+	for _, recebivel := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(recebivel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aging soma os recebíveis por faixa de atraso na data de referência
+func (ms *MemoryStore) Aging(ctx context.Context, query AgingQuery) ([]AgingBucket, error) {
+	referencia, err := time.Parse(domain.LayoutData, query.DataReferencia)
+	if err != nil {
+		return nil, newAppError(ErrCodeInvalidArgument, "data de referência inválida '%s'", query.DataReferencia)
+	}
+	buckets := newAgingBuckets(referencia)
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	filter := customerFilter(query.CodigoCliente, "", "")
+	for _, recebivel := range ms.documents {
+		if !filter.Match(&recebivel) {
+			continue
+		}
+		for i := range buckets {
+			faixa := DateRange{Gte: buckets[i].DataDe, Lte: buckets[i].DataAte}
+			if faixa.contains(recebivel.DataVencimento) {
+				buckets[i].Totais.add(&recebivel)
+				break
+			}
+		}
+	}
+	return buckets, nil
+}
+
+// BalanceSeries soma os recebíveis do cliente por intervalo de data_vencimento no período
+func (ms *MemoryStore) BalanceSeries(ctx context.Context, query BalanceSeriesQuery) ([]BalanceSeriesBucket, error) {
+	buckets, err := newSeriesBuckets(query)
+	if err != nil {
+		return nil, err
+	}
+	porInicio := make(map[string]int, len(buckets))
+	for i, bucket := range buckets {
+		porInicio[bucket.Periodo.Inicio] = i
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	filter := customerFilter(query.CodigoCliente, query.DataInicio, query.DataFim)
+	for _, recebivel := range ms.documents {
+		if !filter.Match(&recebivel) {
+			continue
+		}
+		vencimento, err := time.Parse(domain.LayoutData, recebivel.DataVencimento)
+		if err != nil {
+			continue
+		}
+		if i, ok := porInicio[query.Intervalo.start(vencimento).Format(domain.LayoutData)]; ok {
+			buckets[i].Totais.add(&recebivel)
+		}
+	}
+	return buckets, nil
+}
+
+// Breakdown soma os recebíveis do filtro por combinação de valores das dimensões, na ordem
+// crescente das chaves como a composite aggregation
+func (ms *MemoryStore) Breakdown(ctx context.Context, query BreakdownQuery) ([]BreakdownGroup, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	type grupo struct {
+		valores []interface{}
+		totais  BalanceTotals
+	}
+	grupos := map[string]*grupo{}
+	for _, recebivel := range ms.documents {
+		if !query.Filter.Match(&recebivel) {
+			continue
+		}
+		valores := make([]interface{}, len(query.Dimensions))
+		for i, dimension := range query.Dimensions {
+			valores[i] = valorOrdenacao(&recebivel, dimension)
+		}
+		chave := fmt.Sprintf("%#v", valores)
+		g, ok := grupos[chave]
+		if !ok {
+			g = &grupo{valores: valores}
+			grupos[chave] = g
+		}
+		g.totais.add(&recebivel)
+	}
+
+	ordenados := make([]*grupo, 0, len(grupos))
+	for _, g := range grupos {
+		ordenados = append(ordenados, g)
+	}
+	sort.Slice(ordenados, func(i, j int) bool {
+		for k := range query.Dimensions {
+			if c := compararValores(ordenados[i].valores[k], ordenados[j].valores[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	if len(ordenados) > maxBreakdownGroups {
+		return nil, newAppError(ErrCodeInvalidArgument, "o breakdown gera mais de %d grupos; restrinja o filtro ou use menos dimensões", maxBreakdownGroups)
+	}
+	groups := make([]BreakdownGroup, len(ordenados))
+	for i, g := range ordenados {
+		groups[i] = newBreakdownGroup(query.Dimensions, g.valores)
+		groups[i].Totais = g.totais
+	}
+	return groups, nil
+}
+
+// PaymentIDs lista os id_pagamento distintos de um cliente, em ordem crescente
+func (ms *MemoryStore) PaymentIDs(ctx context.Context, codigoCliente string, size int) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	vistos := map[string]bool{}
+	ids := []string{}
+	filter := customerFilter(codigoCliente, "", "")
+	for _, recebivel := range ms.documents {
+		if filter.Match(&recebivel) && !vistos[recebivel.IDPagamento] {
+			vistos[recebivel.IDPagamento] = true
+			ids = append(ids, recebivel.IDPagamento)
+		}
+	}
+
+	sort.Strings(ids)
+	if size > 0 && len(ids) > size {
+		ids = ids[:size]
+	}
+	return ids, nil
+}
+
+// CustomerGroups soma os recebíveis do filtro por cliente, em ordem de codigo_cliente
+func (ms *MemoryStore) CustomerGroups(ctx context.Context, query CustomerGroupsQuery) ([]CustomerGroup, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	totais := map[string]*BalanceTotals{}
+	for _, recebivel := range ms.documents {
+		if !query.Filter.Match(&recebivel) || query.After != "" && recebivel.CodigoCliente <= query.After {
+			continue
+		}
+		t, ok := totais[recebivel.CodigoCliente]
+		if !ok {
+			t = &BalanceTotals{}
+			totais[recebivel.CodigoCliente] = t
+		}
+		t.add(&recebivel)
+	}
+
+	groups := make([]CustomerGroup, 0, len(totais))
+	for codigoCliente, t := range totais {
+		groups = append(groups, CustomerGroup{CodigoCliente: codigoCliente, Totais: *t})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].CodigoCliente < groups[j].CodigoCliente
+	})
+
+	if query.Size > 0 && len(groups) > query.Size {
+		groups = groups[:query.Size]
+	}
+	return groups, nil
+}
+
+// TopCustomers soma os recebíveis do filtro por cliente e ordena pela métrica
+func (ms *MemoryStore) TopCustomers(ctx context.Context, query TopCustomersQuery) ([]CustomerGroup, error) {
+	groups, err := ms.CustomerGroups(ctx, CustomerGroupsQuery{Filter: query.Filter})
+	if err != nil {
+		return nil, err
+	}
+	return sortTopCustomers(groups, query.Metric, query.N), nil
+}
+
+// GroupByCustomer conta recebíveis agrupados por cliente, do maior para o menor
+func (ms *MemoryStore) GroupByCustomer(ctx context.Context, query GroupByCustomerQuery) ([]CustomerStats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	counts := map[string]int{}
+	filter := customerFilter("", query.DataInicio, query.DataFim)
+	for _, recebivel := range ms.documents {
+		if filter.Match(&recebivel) {
+			counts[recebivel.CodigoCliente]++
+		}
+	}
+
+	stats := make([]CustomerStats, 0, len(counts))
+	for codigoCliente, count := range counts {
+		stats = append(stats, CustomerStats{CodigoCliente: codigoCliente, TotalRecebiveis: count})
+	}
+	// Mesma ordem da terms aggregation: contagem desc e chave asc no empate
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalRecebiveis != stats[j].TotalRecebiveis {
+			return stats[i].TotalRecebiveis > stats[j].TotalRecebiveis
+		}
+		return stats[i].CodigoCliente < stats[j].CodigoCliente
+	})
+
+	if query.Size > 0 && len(stats) > query.Size {
+		stats = stats[:query.Size]
+	}
+	return stats, nil
+}
+
+// valorOrdenacao retorna o valor de um campo do documento usado na ordenação
+func valorOrdenacao(recebivel *domain.Recebivel, field string) interface{} {
+	switch field {
+	case "id_recebivel":
+		return recebivel.IDRecebivel
+	case "id_pagamento":
+		return recebivel.IDPagamento
+	case "codigo_cliente":
+		return recebivel.CodigoCliente
+	case "codigo_produto":
+		return float64(recebivel.CodigoProduto)
+	case "codigo_produto_parceiro":
+		return float64(recebivel.CodigoProdutoParceiro)
+	case "modalidade":
+		return float64(recebivel.Modalidade)
+	case "valor_original":
+		return float64(recebivel.ValorOriginal)
+	case "saldo_disponivel":
+		return float64(recebivel.SaldoDisponivel)
+	case "data_vencimento":
+		return recebivel.DataVencimento
+	}
+	return nil
+}
+
+// valoresOrdenacao retorna os valores dos campos de ordenação, como o sort de um hit
+func valoresOrdenacao(recebivel *domain.Recebivel, sortFields []SortField) []interface{} {
+	valores := make([]interface{}, len(sortFields))
+	for i, s := range sortFields {
+		valores[i] = valorOrdenacao(recebivel, s.Field)
+	}
+	return valores
+}
+
+// compararOrdenacao compara dois conjuntos de valores de ordenação respeitando asc/desc
+func compararOrdenacao(a, b []interface{}, sortFields []SortField) int {
+	for i, s := range sortFields {
+		if i >= len(a) || i >= len(b) {
+			break
+		}
+		cmp := compararValores(a[i], b[i])
+		if cmp == 0 {
+			continue
+		}
+		if s.Desc {
+			return -cmp
+		}
+		return cmp
+	}
+	return 0
+}
+
+// compararValores compara dois valores de campos do documento (números ou textos)
+func compararValores(a, b interface{}) int {
+	switch va := a.(type) {
+	case float64:
+		vb, _ := b.(float64)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	case string:
+		vb, _ := b.(string)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	}
+	return 0
+}
+
+// copiarRecebivel copia o recebível sem compartilhar os slices de eventos
+func copiarRecebivel(recebivel domain.Recebivel) domain.Recebivel {
+	recebivel.Cancelamentos = append([]domain.Cancelamento(nil), recebivel.Cancelamentos...)
+	recebivel.Negociacoes = append([]domain.Negociacao(nil), recebivel.Negociacoes...)
+	return recebivel
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"data-aggregator/domain"
+)
+
+// recebiveisTeste são os documentos carregados por newMemoryStoreTeste
+var recebiveisTeste = []domain.Recebivel{
+	{
+		IDRecebivel: "rec-1", IDPagamento: "pag-1", CodigoCliente: "CLI-A",
+		ValorOriginal: domain.Reais(1000), DataVencimento: "2025-01-10",
+		Cancelamentos: []domain.Cancelamento{
+			{IDCancelamento: "can-1", DataCancelamento: "2025-01-05", ValorCancelado: domain.Reais(100)},
+		},
+	},
+	{
+		IDRecebivel: "rec-2", IDPagamento: "pag-1", CodigoCliente: "CLI-A",
+		ValorOriginal: domain.Reais(500), DataVencimento: "2025-02-10",
+		Negociacoes: []domain.Negociacao{
+			{IDNegociacao: "neg-1", DataNegociacao: "2025-02-01", ValorNegociado: domain.Centavos(2550)},
+		},
+	},
+	{
+		IDRecebivel: "rec-3", IDPagamento: "pag-2", CodigoCliente: "CLI-B",
+		ValorOriginal: domain.Reais(300), DataVencimento: "2025-03-10",
+	},
+}
+
+// newMemoryStoreTeste cria um store em memória com os recebiveisTeste
+func newMemoryStoreTeste(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	for _, recebivel := range recebiveisTeste {
+		if err := store.Put(recebivel); err != nil {
+			t.Fatalf("Put(%s): %v", recebivel.IDRecebivel, err)
+		}
+	}
+	return store
+}
+
+// idsRecebiveis retorna os id_recebivel na ordem do resultado
+func idsRecebiveis(recebiveis []*domain.Recebivel) []string {
+	ids := []string{}
+	for _, recebivel := range recebiveis {
+		ids = append(ids, recebivel.IDRecebivel)
+	}
+	return ids
+}
+
+func TestMemoryStoreGetReceivable(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	tests := []struct {
+		name        string
+		id          string
+		idPagamento string
+		wantSaldo   domain.Money
+		wantCode    string
+	}{
+		{name: "sem routing", id: "rec-1", wantSaldo: domain.Reais(900)},
+		{name: "com o id_pagamento do documento", id: "rec-2", idPagamento: "pag-1", wantSaldo: domain.Centavos(47450)},
+		{name: "id_pagamento de outro documento", id: "rec-3", idPagamento: "pag-1", wantCode: ErrCodeNotFound},
+		{name: "inexistente", id: "rec-9", wantCode: ErrCodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recebivel, err := store.GetReceivable(context.Background(), tt.id, tt.idPagamento)
+			if tt.wantCode != "" {
+				if code := errorCode(err); code != tt.wantCode {
+					t.Fatalf("erro = %v (código %q), esperado código %q", err, code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if recebivel.ID != tt.id || recebivel.SaldoDisponivel != tt.wantSaldo {
+				t.Errorf("recebível %s com saldo %s, esperado %s com saldo %s", recebivel.ID, recebivel.SaldoDisponivel, tt.id, tt.wantSaldo)
+			}
+			if recebivel.Versao == nil {
+				t.Errorf("recebível sem versão")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreGetReceivableRetornaCopia(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	recebivel, err := store.GetReceivable(context.Background(), "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recebivel.Cancelamentos[0].ValorCancelado = domain.Reais(999)
+
+	armazenado, err := store.GetReceivable(context.Background(), "rec-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if armazenado.Cancelamentos[0].ValorCancelado != domain.Reais(100) {
+		t.Errorf("alterar o recebível retornado mudou o armazenado: %s", armazenado.Cancelamentos[0].ValorCancelado)
+	}
+}
+
+func TestMemoryStoreSearchReceivables(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+	saldoMinimo := domain.Reais(400)
+
+	tests := []struct {
+		name      string
+		query     ReceivableQuery
+		wantTotal int
+		wantIDs   []string
+	}{
+		{
+			name:      "todos na ordem de inserção",
+			query:     ReceivableQuery{Size: 10},
+			wantTotal: 3,
+			wantIDs:   []string{"rec-1", "rec-2", "rec-3"},
+		},
+		{
+			name:      "por cliente",
+			query:     ReceivableQuery{Filter: customerFilter("CLI-A", "", ""), Size: 10},
+			wantTotal: 2,
+			wantIDs:   []string{"rec-1", "rec-2"},
+		},
+		{
+			name:      "por período de vencimento",
+			query:     ReceivableQuery{Filter: customerFilter("", "2025-02-01", "2025-03-31"), Size: 10},
+			wantTotal: 2,
+			wantIDs:   []string{"rec-2", "rec-3"},
+		},
+		{
+			name:      "por saldo disponível",
+			query:     ReceivableQuery{Filter: ReceivableFilter{SaldoDisponivel: MoneyRange{Gte: &saldoMinimo}}, Size: 10},
+			wantTotal: 2,
+			wantIDs:   []string{"rec-1", "rec-2"},
+		},
+		{
+			name:      "ordenado por valor asc",
+			query:     ReceivableQuery{Sort: []SortField{{Field: "valor_original"}}, Size: 10},
+			wantTotal: 3,
+			wantIDs:   []string{"rec-3", "rec-2", "rec-1"},
+		},
+		{
+			name:      "ordenado por vencimento desc",
+			query:     ReceivableQuery{Sort: []SortField{{Field: "data_vencimento", Desc: true}}, Size: 10},
+			wantTotal: 3,
+			wantIDs:   []string{"rec-3", "rec-2", "rec-1"},
+		},
+		{
+			name:      "paginado com from e size",
+			query:     ReceivableQuery{From: 1, Size: 1},
+			wantTotal: 3,
+			wantIDs:   []string{"rec-2"},
+		},
+		{
+			name:      "from além do total",
+			query:     ReceivableQuery{From: 5, Size: 10},
+			wantTotal: 3,
+			wantIDs:   []string{},
+		},
+		{
+			name:      "cliente inexistente",
+			query:     ReceivableQuery{Filter: customerFilter("CLI-Z", "", ""), Size: 10},
+			wantTotal: 0,
+			wantIDs:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.SearchReceivables(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if result.Total != tt.wantTotal {
+				t.Errorf("total = %d, esperado %d", result.Total, tt.wantTotal)
+			}
+			if ids := idsRecebiveis(result.Receivables); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, esperado %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCountReceivables(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	tests := []struct {
+		codigoCliente string
+		want          int
+	}{
+		{codigoCliente: "", want: 3},
+		{codigoCliente: "CLI-A", want: 2},
+		{codigoCliente: "CLI-B", want: 1},
+		{codigoCliente: "CLI-Z", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codigoCliente, func(t *testing.T) {
+			count, err := store.CountReceivables(context.Background(), tt.codigoCliente)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if count != tt.want {
+				t.Errorf("count = %d, esperado %d", count, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCustomerBalance(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	tests := []struct {
+		name  string
+		query BalanceQuery
+		want  CustomerBalance
+	}{
+		{
+			name:  "cliente com cancelamento e negociação",
+			query: BalanceQuery{CodigoCliente: "CLI-A"},
+			want: CustomerBalance{
+				CodigoCliente: "CLI-A", TotalRecebiveis: 2, ValorOriginal: domain.Reais(1500),
+				TotalCancelado: domain.Reais(100), TotalNegociado: domain.Centavos(2550),
+			},
+		},
+		{
+			name:  "período de vencimento",
+			query: BalanceQuery{CodigoCliente: "CLI-A", DataInicio: "2025-02-01", DataFim: "2025-02-28"},
+			want: CustomerBalance{
+				CodigoCliente: "CLI-A", TotalRecebiveis: 1, ValorOriginal: domain.Reais(500),
+				TotalNegociado: domain.Centavos(2550),
+			},
+		},
+		{
+			name:  "as_of anterior à negociação",
+			query: BalanceQuery{CodigoCliente: "CLI-A", AsOf: "2025-01-31"},
+			want: CustomerBalance{
+				CodigoCliente: "CLI-A", TotalRecebiveis: 2, ValorOriginal: domain.Reais(1500),
+				TotalCancelado: domain.Reais(100),
+			},
+		},
+		{
+			name:  "cliente sem recebíveis",
+			query: BalanceQuery{CodigoCliente: "CLI-Z"},
+			want:  CustomerBalance{CodigoCliente: "CLI-Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance, err := store.CustomerBalance(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if *balance != tt.want {
+				t.Errorf("balance = %+v, esperado %+v", *balance, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreGroupByCustomer(t *testing.T) {
+	store := newMemoryStoreTeste(t)
+
+	tests := []struct {
+		name  string
+		query GroupByCustomerQuery
+		want  []CustomerStats
+	}{
+		{
+			name:  "todos os clientes, maior contagem primeiro",
+			query: GroupByCustomerQuery{},
+			want:  []CustomerStats{{CodigoCliente: "CLI-A", TotalRecebiveis: 2}, {CodigoCliente: "CLI-B", TotalRecebiveis: 1}},
+		},
+		{
+			name:  "limitado por size",
+			query: GroupByCustomerQuery{Size: 1},
+			want:  []CustomerStats{{CodigoCliente: "CLI-A", TotalRecebiveis: 2}},
+		},
+		{
+			name:  "empate ordenado pela chave",
+			query: GroupByCustomerQuery{DataInicio: "2025-02-01"},
+			want:  []CustomerStats{{CodigoCliente: "CLI-A", TotalRecebiveis: 1}, {CodigoCliente: "CLI-B", TotalRecebiveis: 1}},
+		},
+		{
+			name:  "período sem recebíveis",
+			query: GroupByCustomerQuery{DataInicio: "2030-01-01"},
+			want:  []CustomerStats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := store.GroupByCustomer(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if !reflect.DeepEqual(stats, tt.want) {
+				t.Errorf("stats = %+v, esperado %+v", stats, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenStoreModoDemo(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DemoData = []string{"payloads/ciclo_vida_recebivel.json"}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	if _, ok := store.(*MemoryStore); !ok {
+		t.Fatalf("store = %T, esperado *MemoryStore", store)
+	}
+
+	ctx := context.Background()
+	recebivel, err := store.GetReceivable(ctx, "a7f3c8e2-9b4d-4f6a-8c2e-5d7b9f1a3e6c", "")
+	if err != nil {
+		t.Fatalf("GetReceivable: %v", err)
+	}
+	if len(recebivel.Cancelamentos) != 2 || len(recebivel.Negociacoes) != 1 {
+		t.Errorf("ciclo de vida com %d cancelamentos e %d negociações, esperado 2 e 1", len(recebivel.Cancelamentos), len(recebivel.Negociacoes))
+	}
+	// 10000.00 - (1000.00 + 500.00) - 500.00
+	if recebivel.SaldoDisponivel != domain.Reais(8000) {
+		t.Errorf("saldo_disponivel = %s, esperado 8000.00", recebivel.SaldoDisponivel)
+	}
+
+	count, err := store.CountReceivables(ctx, "CLI-10001")
+	if err != nil || count != 1 {
+		t.Errorf("CountReceivables = %d, %v; esperado 1", count, err)
+	}
+}
+
+func TestLoadMemoryStoreArquivoInexistente(t *testing.T) {
+	if _, err := LoadMemoryStore("payloads/nao_existe.json"); err == nil {
+		t.Fatal("esperado erro para arquivo inexistente")
+	}
+}
@@ -18,7 +18,7 @@ try {
     Write-Host ""
 } catch {
     Write-Host "❌ Servidor não está rodando!" -ForegroundColor Red
-    Write-Host "   Execute 'go run .' primeiro" -ForegroundColor Yellow
+    Write-Host "   Execute 'go run . -auth-disabled' primeiro" -ForegroundColor Yellow
     exit 1
 }
 
@@ -48,7 +48,7 @@ try {
 }
 catch {
     Write-Host "❌ Servidor não está rodando!" -ForegroundColor Red
-    Write-Host "   Execute 'go run .' no diretório do projeto primeiro." -ForegroundColor Yellow
+    Write-Host "   Execute 'go run . -auth-disabled' no diretório do projeto primeiro." -ForegroundColor Yellow
     Write-Host ""
     exit 1
 }
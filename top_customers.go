@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+const (
+	// maxTopCustomers limita o n de topCustomers
+	maxTopCustomers = 1000
+	// maxTopCustomersScan é o size da terms aggregation do ranking: o bucket_sort só ordena
+	// os buckets que a terms devolveu, então ela precisa trazer todos os clientes do filtro
+	maxTopCustomersScan = 10000
+)
+
+// TopCustomerMetric é a métrica que ordena o ranking de clientes
+type TopCustomerMetric string
+
+const (
+	MetricCount         TopCustomerMetric = "COUNT"
+	MetricOriginalValue TopCustomerMetric = "ORIGINAL_VALUE"
+	MetricBalance       TopCustomerMetric = "BALANCE"
+	MetricCancelled     TopCustomerMetric = "CANCELLED"
+	MetricNegotiated    TopCustomerMetric = "NEGOTIATED"
+)
+
+// value retorna o valor da métrica nos totais de um cliente
+func (m TopCustomerMetric) value(t BalanceTotals) int64 {
+	switch m {
+	case MetricOriginalValue:
+		return int64(t.ValorOriginal)
+	case MetricBalance:
+		return int64(t.Saldo)
+	case MetricCancelled:
+		return int64(t.TotalCancelado)
+	case MetricNegotiated:
+		return int64(t.TotalNegociado)
+	}
+	return int64(t.TotalRecebiveis)
+}
+
+// TopCustomersQuery pede os N clientes com maior valor da métrica nos recebíveis do filtro
+type TopCustomersQuery struct {
+	Filter ReceivableFilter
+	Metric TopCustomerMetric
+	N      int
+}
+
+// sortTopCustomers ordena os grupos pela métrica, do maior para o menor, com codigo_cliente
+// crescente no empate, e corta em n
+func sortTopCustomers(groups []CustomerGroup, metric TopCustomerMetric, n int) []CustomerGroup {
+	sort.Slice(groups, func(i, j int) bool {
+		vi, vj := metric.value(groups[i].Totais), metric.value(groups[j].Totais)
+		if vi != vj {
+			return vi > vj
+		}
+		return groups[i].CodigoCliente < groups[j].CodigoCliente
+	})
+	if len(groups) > n {
+		groups = groups[:n]
+	}
+	return groups
+}
+
+// TopCustomers monta o ranking dos clientes pela métrica; cada cliente traz os totais
+// completos, não só a métrica do ranking
+func (be *BalanceEngine) TopCustomers(ctx context.Context, q TopCustomersQuery) ([]CustomerGroup, error) {
+	if q.N < 1 || q.N > maxTopCustomers {
+		return nil, newAppError(ErrCodeInvalidArgument, "n deve estar entre 1 e %d", maxTopCustomers)
+	}
+	switch q.Metric {
+	case MetricCount, MetricOriginalValue, MetricBalance, MetricCancelled, MetricNegotiated:
+	default:
+		return nil, newAppError(ErrCodeInvalidArgument, "métrica inválida '%s': use COUNT, ORIGINAL_VALUE, BALANCE, CANCELLED ou NEGOTIATED", q.Metric)
+	}
+	if err := q.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	return be.store.TopCustomers(ctx, q)
+}